@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/config"
+	"rkata-ai/trade-radar/internal/output"
+	"rkata-ai/trade-radar/internal/retry"
+	"rkata-ai/trade-radar/internal/source"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// analyzeFlags — флаги, общие для команд analyze и serve.
+type analyzeFlags struct {
+	configPath  *string
+	outputTo    string
+	outputFile  string
+	resume      bool
+	fsyncEvery  int
+	debug       bool
+	reanalyze   bool
+	dryRun      bool
+	diff        bool
+	diffReport  string
+	inputFile   string
+	inputFormat string
+	selection   selectionFlags
+}
+
+func (f *analyzeFlags) register(fs *flag.FlagSet) {
+	f.configPath = configFlag(fs)
+	fs.StringVar(&f.outputTo, "output-to", "console", "Comma-separated outputs: console, file (jsonl), json, csv, db; file outputs accept kind=path")
+	fs.StringVar(&f.outputFile, "output-file", "analysis_results.jsonl", "Default path of the JSONL output file")
+	fs.BoolVar(&f.resume, "resume", false, "Append to the JSONL output file and continue after the last written message")
+	fs.IntVar(&f.fsyncEvery, "fsync-every", 10, "Fsync the JSONL output file after this many records")
+	fs.BoolVar(&f.debug, "debug", false, "Enable debug logging, including raw Ollama responses")
+	fs.BoolVar(&f.reanalyze, "reanalyze", false, "Analyze messages that already have results; new results in the database supersede the previous ones")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "Run the pipeline without writing anything: only console output, no failure records")
+	fs.BoolVar(&f.diff, "diff", false, "With -dry-run: analyze messages that have predictions and print how the new results differ from the stored ones")
+	fs.StringVar(&f.diffReport, "diff-report", "", "With -diff: also save the diff report as JSON to this path")
+	fs.StringVar(&f.inputFile, "input-file", "", "Read messages from this file instead of the database; - reads one message from stdin")
+	fs.StringVar(&f.inputFormat, "input-format", "", "Format of -input-file: lines, jsonl or message (default: jsonl for *.jsonl and *.ndjson, message for stdin, lines otherwise)")
+	f.selection.register(fs)
+}
+
+// analyzer связывает клиента модели, хранилище и приемники результатов.
+type analyzer struct {
+	cfg      *config.Config
+	client   *ai.OllamaClient
+	storage  storage.Storage // nil для сообщений из файла: анализ выполняется без базы данных
+	sink     output.Sink
+	jsonl    *output.JSONLSink    // JSONL-приемник, если выбран: по нему продолжается прерванный анализ
+	run      *storage.AnalysisRun // Запуск анализа, если результаты сохраняются в базу
+	policy   retry.Policy         // Политика повторов неудачных сообщений
+	index    ai.EmbeddingIndex    // Векторы сообщений для поиска повторов, если он включен
+	dryRun   bool                 // Ничего не записывать, в том числе неудачи
+	fromFile bool                 // Сообщения читаются не из базы: неудачи не записываются, повторять их нечего
+	logger   *log.Logger
+}
+
+// newAnalyzer загружает конфигурацию, подключается к базе данных и открывает приемники. Сообщения из файла
+// анализируются без базы данных: тикеры не сопоставляются со справочником, повторы ищутся только среди сообщений
+// файла, а приемник db и сравнение с сохраненными прогнозами недоступны.
+func newAnalyzer(ctx context.Context, flags analyzeFlags, targets []outputTarget, logger *log.Logger) (*analyzer, error) {
+	fromFile := flags.inputFile != ""
+	if fromFile {
+		cfg, err := loadConfigWithoutDatabase(logger, *flags.configPath)
+		if err != nil {
+			return nil, err
+		}
+		logConfig(logger, cfg)
+		return newFileAnalyzer(cfg, flags, targets, logger)
+	}
+
+	cfg, err := loadConfig(logger, *flags.configPath)
+	if err != nil {
+		return nil, err
+	}
+	logConfig(logger, cfg)
+
+	client, err := newAIClient(cfg.AI, flags.debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI client: %w", err)
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	client.SetTickerResolver(&stockResolver{storage: dbStorage})
+	var index ai.EmbeddingIndex
+	if cfg.AI.Embeddings.Enabled {
+		if index, err = newEmbeddingIndex(ctx, cfg.AI.Embeddings, dbStorage, hasOutput(targets, "db")); err != nil {
+			dbStorage.Close()
+			return nil, fmt.Errorf("failed to create embedding index: %w", err)
+		}
+		client.SetEmbeddingIndex(index)
+	}
+
+	// Результаты, сохраняемые в базу, относятся к отдельному запуску анализа, чтобы повторный анализ не смешивал
+	// их с прежними и прежние результаты оставались доступны по номеру запуска
+	var run *storage.AnalysisRun
+	var runID int64
+	if hasOutput(targets, "db") {
+		if run, err = newAnalysisRun(cfg, flags.reanalyze); err != nil {
+			dbStorage.Close()
+			return nil, fmt.Errorf("failed to describe analysis run: %w", err)
+		}
+		if err := dbStorage.CreateAnalysisRun(ctx, run); err != nil {
+			dbStorage.Close()
+			return nil, fmt.Errorf("failed to create analysis run: %w", err)
+		}
+		runID = run.ID
+		logger.Printf("Saving results to the database as analysis run %d (model %s, prompt %s, version %s)",
+			runID, run.Model.String, run.PromptVersion.String, run.BinaryVersion.String)
+	}
+
+	var sink output.Sink
+	var jsonl *output.JSONLSink
+	if flags.diff {
+		// В режиме сравнения результаты не выводятся приемниками, а сравниваются с сохраненными прогнозами
+		sink = output.NewDiffSink(dbStorage, postgresOptions(cfg), os.Stdout, flags.diffReport)
+	} else if sink, jsonl, err = openSinks(targets, cfg, dbStorage, flags, runID, logger); err != nil {
+		dbStorage.Close()
+		return nil, fmt.Errorf("failed to open output: %w", err)
+	}
+
+	policy := retry.Policy{MaxAttempts: cfg.Retry.MaxAttempts, BaseDelay: cfg.Retry.BaseDelay, MaxDelay: cfg.Retry.MaxDelay}
+	return &analyzer{cfg: cfg, client: client, storage: dbStorage, sink: sink, jsonl: jsonl, run: run, policy: policy,
+		index: index, dryRun: flags.dryRun, logger: logger}, nil
+}
+
+// newFileAnalyzer создает анализатор сообщений из файла, который не подключается к базе данных.
+func newFileAnalyzer(cfg *config.Config, flags analyzeFlags, targets []outputTarget, logger *log.Logger) (*analyzer, error) {
+	client, err := newAIClient(cfg.AI, flags.debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AI client: %w", err)
+	}
+	var index ai.EmbeddingIndex
+	if cfg.AI.Embeddings.Enabled {
+		// Векторы не загружаются из базы и не сохраняются в нее: повторы ищутся среди сообщений файла
+		index = ai.NewMemoryEmbeddingIndex(nil)
+		client.SetEmbeddingIndex(index)
+	}
+
+	sink, jsonl, err := openSinks(targets, cfg, nil, flags, 0, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output: %w", err)
+	}
+	return &analyzer{cfg: cfg, client: client, sink: sink, jsonl: jsonl, index: index, dryRun: flags.dryRun, fromFile: true, logger: logger}, nil
+}
+
+// Close закрывает приемники, записывает итоги запуска анализа и закрывает соединение с базой данных.
+func (a *analyzer) Close() error {
+	var runErr error
+	if a.run != nil {
+		a.run.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		runErr = a.storage.FinishAnalysisRun(context.Background(), a.run)
+	}
+	var storageErr error
+	if a.storage != nil {
+		storageErr = a.storage.Close()
+	}
+	return errors.Join(a.sink.Close(), runErr, storageErr)
+}
+
+// analyzeMessages анализирует сообщения по очереди и передает результаты приемникам. Возвращает число сообщений,
+// которые не удалось проанализировать или записать; они записываются в analysis_failures для повторной попытки.
+// При отмене ctx обработка прекращается после текущего сообщения.
+func (a *analyzer) analyzeMessages(ctx context.Context, messages []storage.Message) int {
+	failed := 0
+	for idx, message := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+
+		a.logger.Printf("Analyzing message %d/%d (ID: %d): %s", idx+1, len(messages), message.TelegramID, message.Text.String)
+		if stage, err := a.analyzeMessage(ctx, message); err != nil {
+			failed++
+			a.recordFailure(ctx, message, stage, err, 0)
+		}
+	}
+
+	if err := a.sink.Flush(); err != nil {
+		a.logger.Printf("Failed to flush output: %v", err)
+		failed++
+	}
+	return failed
+}
+
+// analyzeMessage анализирует одно сообщение и передает результат приемникам. При ошибке возвращает этап,
+// на котором она произошла: storage.FailureStageAnalyze или storage.FailureStageSave.
+func (a *analyzer) analyzeMessage(ctx context.Context, message storage.Message) (string, error) {
+	opts := []ai.AnalyzeOption{ai.WithSentAt(message.SentAt)}
+	if a.cfg.AI.ReplyContext.Enabled && a.storage != nil {
+		if parents := replyContext(ctx, a.storage, message, a.cfg.AI.ReplyContext.MaxDepth); len(parents) > 0 {
+			a.logger.Printf("Message %d replies to %d earlier message(s), adding them as context", message.TelegramID, len(parents))
+			opts = append(opts, ai.WithReplyContext(parents...))
+		}
+	}
+	analysis, err := a.client.AnalyzeMessage(ctx, message.Text.String, fmt.Sprintf("%d", message.ChannelID), message.TelegramID, opts...)
+	if errors.Is(err, ai.ErrNoPredictions) {
+		// Сообщение без прогнозов проанализировано успешно: оно записывается как пропущенное и не повторяется
+		analysis, err = &ai.MessageAnalysis{Skipped: true, SkipReason: ai.SkipReasonNoPredictions}, nil
+	}
+	if err != nil {
+		a.logger.Printf("Failed to analyze message %d: %v", message.TelegramID, err)
+		if a.run != nil {
+			a.run.MessagesTotal++
+			a.run.MessagesFailed++
+		}
+		return storage.FailureStageAnalyze, err
+	}
+
+	if analysis.Skipped {
+		a.logger.Printf("Message %d skipped: %s", message.TelegramID, analysis.SkipReason)
+		if analysis.DuplicateOf != nil {
+			a.logger.Printf("Message %d is a repost of message %d from channel %s (similarity %.3f)",
+				message.TelegramID, analysis.DuplicateOf.MessageID, analysis.DuplicateOf.Channel, analysis.DuplicateOf.Similarity)
+		}
+	}
+
+	if a.run != nil {
+		countResult(a.run, analysis)
+	}
+	if err := a.sink.Write(ctx, output.Result{Message: message, Analysis: analysis}); err != nil {
+		a.logger.Printf("Failed to write analysis result for message %d: %v", message.TelegramID, err)
+		if a.run != nil {
+			a.run.MessagesFailed++
+		}
+		return storage.FailureStageSave, err
+	}
+	return "", nil
+}
+
+// recordFailure записывает неудачный анализ сообщения в analysis_failures и планирует следующую попытку
+// по политике повторов. previousAttempts — число неудачных попыток до этой. Прерывание анализа по сигналу
+// неудачей не считается; при -dry-run и для сообщений из файла неудачи не записываются.
+func (a *analyzer) recordFailure(ctx context.Context, message storage.Message, stage string, err error, previousAttempts int) {
+	if ctx.Err() != nil || a.dryRun || a.fromFile {
+		return
+	}
+
+	class := retry.ClassStorage
+	if stage == storage.FailureStageAnalyze {
+		class = retry.Classify(err)
+	}
+	now := time.Now()
+	failure := storage.AnalysisFailure{
+		ChannelID:    message.ChannelID,
+		MessageID:    message.TelegramID,
+		Stage:        stage,
+		ErrorClass:   class,
+		ErrorMessage: err.Error(),
+		Attempts:     previousAttempts + 1,
+		LastFailedAt: now,
+	}
+	if a.run != nil {
+		failure.RunID = sql.NullInt64{Int64: a.run.ID, Valid: true}
+	}
+	if next, ok := a.policy.NextAttempt(failure.Attempts, now); ok && retry.Retryable(class) {
+		failure.NextRetryAt = sql.NullTime{Time: next, Valid: true}
+	}
+
+	if err := a.storage.SaveAnalysisFailure(ctx, &failure); err != nil {
+		a.logger.Printf("Failed to record failure of message %d: %v", message.TelegramID, err)
+		return
+	}
+	if failure.NextRetryAt.Valid {
+		a.logger.Printf("Message %d failed (%s, attempt %d), next retry at %s", message.TelegramID, class, failure.Attempts, failure.NextRetryAt.Time.Format(time.RFC3339))
+	} else {
+		a.logger.Printf("Message %d failed (%s) after %d attempts, giving up", message.TelegramID, class, failure.Attempts)
+	}
+}
+
+// retryFailures повторяет анализ сообщений из analysis_failures, время попытки которых наступило, но не больше limit.
+// Успешно повторенные записи отмечаются решенными, неудачные — получают следующую попытку или исчерпывают их.
+// Возвращает число повторенных и успешно повторенных сообщений.
+func (a *analyzer) retryFailures(ctx context.Context, limit int) (int, int, error) {
+	failures, err := a.storage.GetDueFailures(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get failures to retry: %w", err)
+	}
+
+	retried, resolved := 0, 0
+	for _, failure := range failures {
+		if ctx.Err() != nil {
+			break
+		}
+
+		message, err := a.storage.GetMessage(ctx, failure.ChannelID, failure.MessageID)
+		if err != nil {
+			a.logger.Printf("Cannot retry message %d from channel %d: %v", failure.MessageID, failure.ChannelID, err)
+			continue
+		}
+
+		a.logger.Printf("Retrying message %d (attempt %d, last error: %s)", message.TelegramID, failure.Attempts+1, failure.ErrorClass)
+		retried++
+		if stage, err := a.analyzeMessage(ctx, *message); err != nil {
+			a.recordFailure(ctx, *message, stage, err, failure.Attempts)
+			continue
+		}
+		if err := a.storage.ResolveFailure(ctx, failure.ChannelID, failure.MessageID, time.Now()); err != nil {
+			a.logger.Printf("Failed to resolve failure of message %d: %v", message.TelegramID, err)
+			continue
+		}
+		resolved++
+	}
+
+	if err := a.sink.Flush(); err != nil {
+		return retried, resolved, fmt.Errorf("failed to flush output: %w", err)
+	}
+	return retried, resolved, nil
+}
+
+// pruneEmbeddings удаляет из индекса повторов векторы сообщений, отправленных раньше окна поиска исходного сообщения,
+// чтобы индекс serve не рос без ограничений.
+func (a *analyzer) pruneEmbeddings() {
+	window := time.Duration(a.cfg.AI.Embeddings.WindowHours) * time.Hour
+	if a.index == nil || window <= 0 {
+		return
+	}
+	if pruned := a.index.Prune(time.Now().Add(-window)); pruned > 0 {
+		a.logger.Printf("Dropped %d message embeddings older than %s from the duplicate index", pruned, window)
+	}
+}
+
+// resumeCursor возвращает позицию последнего сообщения, записанного в JSONL-файл, чтобы продолжить выборку после него.
+// Если файла нет или сообщение не найдено в базе, выборка начинается сначала.
+func (a *analyzer) resumeCursor(ctx context.Context) *storage.MessageCursor {
+	if a.jsonl == nil {
+		return nil
+	}
+	last, ok := a.jsonl.Last()
+	if !ok {
+		return nil
+	}
+	channelID, err := strconv.ParseInt(last.Channel, 10, 64)
+	if err == nil {
+		var message *storage.Message
+		if message, err = a.storage.GetMessage(ctx, channelID, last.MessageID); err == nil {
+			a.logger.Printf("Resuming after message %d from channel %s", last.MessageID, last.Channel)
+			return message.Cursor()
+		}
+	}
+	a.logger.Printf("Cannot resume after message %d from channel %s, starting from the beginning: %v", last.MessageID, last.Channel, err)
+	return nil
+}
+
+// runAnalyze выполняет команду analyze: постранично анализирует выбранные сообщения без прогнозов и завершается.
+// С -reanalyze анализируются и уже обработанные сообщения, а с -dry-run -diff новые результаты только сравниваются
+// с сохраненными прогнозами. С -input-file или аргументом - сообщения читаются из файла или stdin вместо базы.
+// Код завершения 1 означает, что часть сообщений не удалось проанализировать или записать.
+func runAnalyze(args []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	var flags analyzeFlags
+	flags.register(fs)
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *flags.configPath == "" || fs.NArg() > 1 || (fs.NArg() == 1 && flags.inputFile != "") {
+		logger.Printf("Usage: ./bin/trading.exe analyze -config <path_to_config> [-output-to console,file,json,csv,db] [-output-file <path>] [-channels <ids>] [-ids <ids>] [-since <date>] [-until <date>] [-type <type>] [-newest-first] [-limit <n>] [-reanalyze] [-dry-run [-diff] [-diff-report <path>]] [-input-file <path> [-input-format lines|jsonl|message] | -]")
+		return exitUsage
+	}
+	if fs.NArg() == 1 {
+		flags.inputFile = fs.Arg(0)
+	}
+	if set := setFlags(fs, dbSelectionFlags...); flags.inputFile != "" && len(set) > 0 {
+		logger.Printf("%s select messages from the database and cannot be combined with file input", strings.Join(set, ", "))
+		return exitUsage
+	}
+	filter, err := flags.selection.filter()
+	if err != nil {
+		logger.Printf("Invalid message selection: %v", err)
+		return exitUsage
+	}
+	// При повторном анализе выбираются и сообщения, у которых уже есть результаты, а при сравнении — только они
+	filter.WithoutPredictions = !flags.reanalyze && !flags.diff
+	filter.WithPredictions = flags.diff
+	targets, err := parseOutputTargets(flags.outputTo, flags.outputFile)
+	if err != nil {
+		logger.Printf("Invalid output-to option: %v", err)
+		return exitUsage
+	}
+	// У сообщений из файла нет записей в messages, а их идентификаторы могут быть условными (канал 0, номер строки):
+	// результаты таких сообщений в базу не сохраняются
+	if flags.inputFile != "" && hasOutput(targets, "db") {
+		logger.Print("-output-to db cannot be combined with file input")
+		return exitUsage
+	}
+	if flags.diff && !flags.dryRun {
+		logger.Print("-diff requires -dry-run")
+		return exitUsage
+	}
+	if flags.dryRun && (len(targets) != 1 || targets[0].kind != "console") {
+		logger.Print("-dry-run writes nothing: use -output-to console")
+		return exitUsage
+	}
+	if flags.dryRun && flags.reanalyze {
+		logger.Print("-dry-run cannot be combined with -reanalyze")
+		return exitUsage
+	}
+
+	// SIGINT/SIGTERM прерывают анализ после текущего сообщения; записанные результаты сохраняются
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var input *source.File
+	if flags.inputFile != "" {
+		if input, err = source.Open(flags.inputFile, flags.inputFormat, flags.selection.limit); err != nil {
+			logger.Print(err)
+			return exitUsage
+		}
+		defer input.Close()
+	}
+
+	logger.Print("Starting R&D research for trading channel rating system")
+	a, err := newAnalyzer(ctx, flags, targets, logger)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+	defer func() {
+		if err := a.Close(); err != nil {
+			logger.Printf("Failed to close output: %v", err)
+		}
+	}()
+
+	var src source.Source = input
+	if input == nil {
+		filter.After = a.resumeCursor(ctx)
+		src = source.NewPostgres(a.storage, filter, flags.selection.limit, flags.selection.pageSize)
+	}
+	total, failed, err := a.walkMessages(ctx, src)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+	if total == 0 && ctx.Err() == nil {
+		logger.Print("No new messages to analyze.")
+		return exitOK
+	}
+	if ctx.Err() != nil {
+		logger.Print("Interrupted, results written so far are saved")
+		return exitInterrupted
+	}
+	if failed > 0 {
+		logger.Printf("Processing of %d messages completed with %d failed message(s)", total, failed)
+		return exitFailure
+	}
+	logger.Printf("=== Processing of %d messages completed successfully! ===", total)
+	return exitOK
+}
+
+// runServe выполняет команду serve: периодически выбирает новые сообщения без прогнозов и анализирует их,
+// пока процесс не получит SIGINT/SIGTERM. Каждый опрос продолжает выборку с последнего обработанного сообщения
+// и повторяет неудачные сообщения, время попытки которых наступило.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var flags analyzeFlags
+	flags.register(fs)
+	interval := fs.Duration("interval", time.Minute, "Delay between polls for new messages")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *flags.configPath == "" || *interval <= 0 || flags.selection.newestFirst || flags.reanalyze || flags.dryRun || flags.diff ||
+		flags.inputFile != "" || fs.NArg() > 0 {
+		logger.Printf("Usage: ./bin/trading.exe serve -config <path_to_config> [-interval 1m] [-output-to console,file,json,csv,db] [-channels <ids>] [-type <type>] [-limit <n>]")
+		return exitUsage
+	}
+	filter, err := flags.selection.filter()
+	if err != nil {
+		logger.Printf("Invalid message selection: %v", err)
+		return exitUsage
+	}
+	targets, err := parseOutputTargets(flags.outputTo, flags.outputFile)
+	if err != nil {
+		logger.Printf("Invalid output-to option: %v", err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newAnalyzer(ctx, flags, targets, logger)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+	defer func() {
+		if err := a.Close(); err != nil {
+			logger.Printf("Failed to close output: %v", err)
+		}
+	}()
+
+	filter.After = a.resumeCursor(ctx)
+	logger.Printf("Polling for new messages every %s, press Ctrl+C to stop", *interval)
+	for {
+		src := source.NewPostgres(a.storage, filter, flags.selection.limit, flags.selection.pageSize)
+		total, failed, err := a.walkMessages(ctx, src)
+		if err != nil && ctx.Err() == nil {
+			logger.Print(err)
+		}
+		filter.After = src.Cursor()
+		if total > 0 {
+			logger.Printf("Analyzed %d new messages, %d failed", total, failed)
+		}
+
+		// Повторы неудачных сообщений выполняются в том же цикле, по мере наступления их времени
+		retried, resolved, err := a.retryFailures(ctx, flags.selection.pageSize)
+		if err != nil && ctx.Err() == nil {
+			logger.Print(err)
+		}
+		if retried > 0 {
+			logger.Printf("Retried %d failed messages, %d succeeded", retried, resolved)
+		}
+		a.pruneEmbeddings()
+
+		select {
+		case <-ctx.Done():
+			logger.Print("Shutting down...")
+			return exitOK
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// runReanalyze выполняет команду reanalyze: то же, что analyze -reanalyze.
+func runReanalyze(args []string) int {
+	return runAnalyze(append([]string{"-reanalyze"}, args...))
+}
+
+// logConfig выводит загруженные параметры конфигурации.
+func logConfig(logger *log.Logger, cfg *config.Config) {
+	logger.Printf("Config loaded successfully:")
+	logger.Printf("  AI.OllamaBaseURL: %s", cfg.AI.OllamaBaseURL)
+	logger.Printf("  AI.OllamaModel: %s", cfg.AI.OllamaModel)
+	logger.Printf("  AI.Debug: %t", cfg.AI.Debug)
+	logger.Printf("  AI.Temperature: %.2f", cfg.AI.Temperature)
+	logger.Printf("  AI.TopP: %.2f", cfg.AI.TopP)
+	logger.Printf("  AI.MaxTokens: %d", cfg.AI.MaxTokens)
+	logger.Printf("  AI.Stop: %v", cfg.AI.Stop)
+	logger.Printf("  AI.PromptVersion: %s", cfg.AI.PromptVersion)
+	logger.Printf("  AI.NumCtx: %d", cfg.AI.NumCtx)
+	logger.Printf("  AI.Evidence.Enabled: %t", cfg.AI.Evidence.Enabled)
+	logger.Printf("  AI.Evidence.MinQuoteScore: %.2f", cfg.AI.Evidence.MinQuoteScore)
+	logger.Printf("  AI.Evidence.RejectUnverified: %t", cfg.AI.Evidence.RejectUnverified)
+	logger.Printf("  AI.PreFilter.Enabled: %t", cfg.AI.PreFilter.Enabled)
+	logger.Printf("  AI.PreFilter.UseLLM: %t", cfg.AI.PreFilter.UseLLM)
+	logger.Printf("  AI.Rules.MinCoverage: %.2f", cfg.AI.Rules.MinCoverage)
+	logger.Printf("  AI.Rules.Templates: %d", len(cfg.AI.Rules.Templates))
+	logger.Printf("  AI.Ensemble.Enabled: %t", cfg.AI.Ensemble.Enabled)
+	logger.Printf("  AI.Ensemble.Models: %v", cfg.AI.Ensemble.Models)
+	logger.Printf("  AI.Ensemble.Samples: %d", cfg.AI.Ensemble.Samples)
+	logger.Printf("  AI.Ensemble.MinAgreement: %.2f", cfg.AI.Ensemble.MinAgreement)
+	logger.Printf("  AI.Confidence.Enabled: %t", cfg.AI.Confidence.Enabled)
+	logger.Printf("  AI.Confidence.MinConfidence: %.2f", cfg.AI.Confidence.MinConfidence)
+	logger.Printf("  AI.FewShot.Enabled: %t", cfg.AI.FewShot.Enabled)
+	logger.Printf("  AI.FewShot.Path: %s", cfg.AI.FewShot.Path)
+	logger.Printf("  AI.Embeddings.Enabled: %t", cfg.AI.Embeddings.Enabled)
+	logger.Printf("  AI.Embeddings.Model: %s", cfg.AI.Embeddings.Model)
+	logger.Printf("  AI.Embeddings.DuplicateThreshold: %.2f", cfg.AI.Embeddings.DuplicateThreshold)
+	logger.Printf("  AI.ReplyContext.Enabled: %t", cfg.AI.ReplyContext.Enabled)
+	logger.Printf("  AI.ReplyContext.MaxDepth: %d", cfg.AI.ReplyContext.MaxDepth)
+	logger.Printf("  AI.Chunking.Enabled: %t", cfg.AI.Chunking.Enabled)
+	logger.Printf("  AI.Chunking.MaxMessageTokens: %d", cfg.AI.Chunking.MaxMessageTokens)
+	logger.Printf("  Retry.MaxAttempts: %d", cfg.Retry.MaxAttempts)
+	logger.Printf("  Retry.BaseDelay: %s", cfg.Retry.BaseDelay)
+	logger.Printf("  Retry.MaxDelay: %s", cfg.Retry.MaxDelay)
+	logger.Printf("  Database.Host: %s", cfg.Database.Host)
+	logger.Printf("  Database.Port: %d", cfg.Database.Port)
+	logger.Printf("  Database.User: %s", cfg.Database.User)
+	logger.Printf("  Database.DBName: %s", cfg.Database.DBName)
+	logger.Printf("  Database.SSLMode: %s", cfg.Database.SSLMode)
+	logger.Printf("  Database.ConnectionString: %s", cfg.Database.ConnectionString)
+}
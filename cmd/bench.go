@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/eval"
+)
+
+// runBench выполняет команду bench: прогоняет один набор сообщений через несколько моделей и версий промта
+// и печатает сравнительную таблицу качества, скорости и согласия между вариантами.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	configPath := configFlag(fs)
+	datasetPath := fs.String("dataset", "", "Path to JSONL message set; 'expected' labels are optional (required)")
+	modelsFlag := fs.String("models", "", "Comma-separated Ollama models (default: ai.ollama_model from config)")
+	promptsFlag := fs.String("prompts", "", "Comma-separated prompt versions or template files (default: ai.prompt_version from config)")
+	reportPath := fs.String("report", "bench_report.json", "Path to the machine-readable JSON report")
+	debugFlag := fs.Bool("debug", false, "Enable debug logging, including raw Ollama responses")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || *datasetPath == "" {
+		logger.Printf("Usage: ./bin/trading.exe bench -config <path_to_config> -dataset <path_to_jsonl> [-models m1,m2] [-prompts v1,v2]")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	examples, err := eval.LoadDataset(*datasetPath)
+	if err != nil {
+		logger.Printf("Failed to load dataset: %v", err)
+		return exitFailure
+	}
+
+	models := splitList(*modelsFlag)
+	if len(models) == 0 {
+		models = []string{cfg.AI.OllamaModel}
+	}
+	prompts := splitList(*promptsFlag)
+	if len(prompts) == 0 {
+		prompts = []string{cfg.AI.PromptVersion}
+	}
+
+	var variants []eval.Variant
+	for _, model := range models {
+		for _, prompt := range prompts {
+			promptVersion, _, err := ai.ResolvePrompt(prompt)
+			if err != nil {
+				logger.Printf("Invalid prompt %q: %v", prompt, err)
+				return exitUsage
+			}
+
+			aiCfg := cfg.AI
+			aiCfg.OllamaModel = model
+			aiCfg.PromptVersion = prompt
+			aiClient, err := newAIClient(aiCfg, *debugFlag)
+			if err != nil {
+				logger.Printf("Failed to create AI client for %s: %v", model, err)
+				return exitFailure
+			}
+
+			variants = append(variants, eval.Variant{Model: model, PromptVersion: promptVersion, Analyzer: aiClient})
+		}
+	}
+
+	bench := eval.Bench(context.Background(), variants, examples)
+	bench.Dataset = *datasetPath
+
+	printBench(bench)
+
+	if err := eval.WriteReport(bench, *reportPath); err != nil {
+		logger.Printf("Failed to write report: %v", err)
+		return exitFailure
+	}
+	logger.Printf("Report written to %s", *reportPath)
+
+	return exitOK
+}
+
+// printBench печатает сравнительную таблицу вариантов и матрицу согласия.
+func printBench(bench *eval.BenchReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tPROMPT\tTICKER F1\tDIRECTION F1\tRECOMMENDATION F1\tTARGET F1\tEXACT\tFAILURES\tTOK/S\tP50 MS\tP90 MS\tP99 MS")
+	for _, report := range bench.Variants {
+		quality := func(field string) string {
+			if report.Labeled == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.3f", report.Fields[field].F1)
+		}
+		exact := "-"
+		if report.Labeled > 0 {
+			exact = fmt.Sprintf("%.3f", report.ExactMatchRate)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.3f\t%.1f\t%.0f\t%.0f\t%.0f\n",
+			report.Model, report.PromptVersion,
+			quality(eval.FieldTicker), quality(eval.FieldDirection), quality(eval.FieldRecommendation), quality(eval.FieldTarget),
+			exact, report.FailureRate, report.TokensPerSecond,
+			report.Latency.P50Ms, report.Latency.P90Ms, report.Latency.P99Ms)
+	}
+	w.Flush()
+
+	if len(bench.Agreement) == 0 {
+		return
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VARIANT A\tVARIANT B\tAGREEMENT")
+	for _, agreement := range bench.Agreement {
+		fmt.Fprintf(w, "%s\t%s\t%.3f\n", agreement.A, agreement.B, agreement.Score)
+	}
+	w.Flush()
+}
+
+// splitList разбирает список значений через запятую, отбрасывая пустые элементы.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
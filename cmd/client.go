@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/config"
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+// newAIClient создает клиент Ollama и собирает для него конвейер анализа из конфигурации.
+func newAIClient(cfg config.AIConfig, debug bool) (*ai.OllamaClient, error) {
+	aiClient := ai.NewOllamaClient(
+		cfg.OllamaBaseURL,
+		cfg.OllamaModel,
+		debug,
+		cfg.Temperature,
+		cfg.TopP,
+		cfg.MaxTokens,
+		cfg.Stop,
+	)
+	aiClient.SetContextWindow(cfg.NumCtx)
+
+	steps, err := ai.BuildPipeline(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build analysis pipeline: %w", err)
+	}
+	aiClient.SetPipeline(steps...)
+
+	return aiClient, nil
+}
+
+// stockResolver оценивает сопоставление тикеров по справочнику акций в базе данных.
+type stockResolver struct {
+	storage storage.Storage
+}
+
+// ResolveTicker возвращает 1 для точного совпадения тикера, 0.8 — для совпадения после нормализации
+// (без префиксов # и $, в верхнем регистре) и 0, если акция не найдена.
+func (r *stockResolver) ResolveTicker(ctx context.Context, ticker string) (float64, error) {
+	if _, err := r.storage.GetStock(ctx, ticker); err == nil {
+		return 1, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	normalized := ai.NormalizeTicker(ticker)
+	if normalized == ticker {
+		return 0, nil
+	}
+	if _, err := r.storage.GetStock(ctx, normalized); err == nil {
+		return 0.8, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// dbEmbeddingIndex — индекс векторов сообщений в памяти, который дополнительно сохраняет векторы в message_embeddings.
+type dbEmbeddingIndex struct {
+	*ai.MemoryEmbeddingIndex
+	storage storage.Storage
+	model   string
+}
+
+// newEmbeddingIndex загружает векторы сообщений, отправленных за последние cfg.WindowHours часов.
+// Если persist не установлен, новые векторы хранятся только в памяти.
+func newEmbeddingIndex(ctx context.Context, cfg config.EmbeddingsConfig, dbStorage storage.Storage, persist bool) (ai.EmbeddingIndex, error) {
+	since := time.Now().Add(-time.Duration(cfg.WindowHours) * time.Hour)
+	stored, err := dbStorage.GetRecentEmbeddings(ctx, cfg.Model, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load message embeddings: %w", err)
+	}
+
+	entries := make([]ai.EmbeddingEntry, 0, len(stored))
+	for _, embedding := range stored {
+		entries = append(entries, ai.EmbeddingEntry{
+			Channel:   strconv.FormatInt(embedding.ChannelID, 10),
+			MessageID: embedding.MessageID,
+			SentAt:    embedding.SentAt.Time,
+			Vector:    embedding.Embedding,
+		})
+	}
+	memory := ai.NewMemoryEmbeddingIndex(entries)
+	if !persist {
+		return memory, nil
+	}
+	return &dbEmbeddingIndex{MemoryEmbeddingIndex: memory, storage: dbStorage, model: cfg.Model}, nil
+}
+
+// Add сохраняет вектор сообщения в базу данных и добавляет его в индекс в памяти.
+func (i *dbEmbeddingIndex) Add(ctx context.Context, entry ai.EmbeddingEntry) error {
+	channelID, err := strconv.ParseInt(entry.Channel, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid channel id %q: %w", entry.Channel, err)
+	}
+
+	embedding := storage.MessageEmbedding{
+		ChannelID: channelID,
+		MessageID: entry.MessageID,
+		Model:     i.model,
+		Embedding: pq.Float64Array(entry.Vector),
+		SentAt:    sql.NullTime{Time: entry.SentAt, Valid: !entry.SentAt.IsZero()},
+		CreatedAt: time.Now(),
+	}
+	if entry.DuplicateOf != nil {
+		originalChannelID, err := strconv.ParseInt(entry.DuplicateOf.Channel, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid channel id %q: %w", entry.DuplicateOf.Channel, err)
+		}
+		embedding.DuplicateOfChannelID = sql.NullInt64{Int64: originalChannelID, Valid: true}
+		embedding.DuplicateOfMessageID = sql.NullInt64{Int64: entry.DuplicateOf.MessageID, Valid: true}
+		embedding.Similarity = sql.NullFloat64{Float64: entry.DuplicateOf.Similarity, Valid: true}
+	}
+
+	if err := i.storage.SaveMessageEmbedding(ctx, &embedding); err != nil {
+		return err
+	}
+	return i.MemoryEmbeddingIndex.Add(ctx, entry)
+}
+
+// replyContext загружает цепочку сообщений, на которые отвечает message, не глубже maxDepth, от самого раннего к последнему.
+// Сообщения, которых нет в базе, обрывают цепочку.
+func replyContext(ctx context.Context, dbStorage storage.Storage, message storage.Message, maxDepth int) []ai.ContextMessage {
+	var parents []ai.ContextMessage
+	current := message
+	for depth := 0; depth < maxDepth; depth++ {
+		parentID, ok := current.ReplyToID()
+		if !ok {
+			break
+		}
+		parent, err := dbStorage.GetMessage(ctx, current.ChannelID, parentID)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Failed to load reply parent %d of message %d: %v", parentID, current.TelegramID, err)
+			}
+			break
+		}
+		if parent.Text.Valid && strings.TrimSpace(parent.Text.String) != "" {
+			parents = append([]ai.ContextMessage{{MessageID: parent.TelegramID, Text: parent.Text.String}}, parents...)
+		}
+		current = *parent
+	}
+	return parents
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"rkata-ai/trade-radar/internal/config"
+)
+
+// Коды завершения, общие для всех команд
+const (
+	exitOK      = 0 // Команда выполнена
+	exitFailure = 1 // Ошибка выполнения: конфигурация, база данных, модель, файлы
+	exitUsage   = 2 // Неверные аргументы командной строки
+
+	exitInterrupted = 130 // Остановка по SIGINT/SIGTERM до окончания работы
+)
+
+// command — подкоманда CLI. run получает аргументы после имени команды и возвращает код завершения.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands возвращает таблицу подкоманд в порядке вывода в справке.
+func commands() []command {
+	return []command{
+		{"analyze", "Analyze messages without predictions and write results to the selected outputs", runAnalyze},
+		{"reanalyze", "Analyze already processed messages again; new results supersede the previous ones", runReanalyze},
+		{"serve", "Keep analyzing new messages as they arrive and retrying failed ones until interrupted", runServe},
+		{"retry", "Retry failed message analyses that are due, with backoff", runRetry},
+		{"failures", "Summarize failed message analyses by error class", runFailures},
+		{"migrate", "Apply embedded database migrations", runMigrate},
+		{"import", "Import data into the database (results)", runImport},
+		{"export", "Export data from the database (dataset)", runExport},
+		{"eval", "Evaluate extraction quality on a labeled dataset", runEval},
+		{"bench", "Compare models and prompt versions on one message set", runBench},
+		{"review", "Review raw predictions in the terminal", runReview},
+		{"ratings", "Rate channels by original, forwarded and copied signals", runRatings},
+	}
+}
+
+// commandAliases — прежние имена команд, оставленные для совместимости.
+var commandAliases = map[string]string{
+	"export-dataset": "export dataset",
+}
+
+// findCommand возвращает подкоманду по имени.
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// printUsage печатает список подкоманд.
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ./bin/trading.exe <command> [flags]")
+	printCommands(w, commands())
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run './bin/trading.exe <command> -h' for command flags.")
+}
+
+func printCommands(w io.Writer, cmds []command) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range cmds {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+}
+
+// runSubcommand выбирает вложенную команду (например, "import results") по первому аргументу.
+func runSubcommand(parent string, subcommands []command, args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "Usage: ./bin/trading.exe %s <command> [flags]\n", parent)
+		printCommands(os.Stderr, subcommands)
+		return exitUsage
+	}
+	for _, cmd := range subcommands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown %s command %q\n", parent, args[0])
+	printCommands(os.Stderr, subcommands)
+	return exitUsage
+}
+
+// configFlag регистрирует флаг -config. Если флаг не задан, путь берется из TRADING_AI_CONFIG.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", os.Getenv("TRADING_AI_CONFIG"), "Path to configuration file (required; default: $TRADING_AI_CONFIG)")
+}
+
+// loadConfig загружает конфигурацию для команды.
+func loadConfig(logger *log.Logger, path string) (*config.Config, error) {
+	logger.Printf("Using config file: %s", path)
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadConfigWithoutDatabase загружает конфигурацию для команды, которая не подключается к базе данных.
+func loadConfigWithoutDatabase(logger *log.Logger, path string) (*config.Config, error) {
+	logger.Printf("Using config file: %s", path)
+	cfg, err := config.LoadWithoutDatabase(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"rkata-ai/trade-radar/internal/eval"
+)
+
+// runEval выполняет команду eval: прогоняет размеченный набор сообщений через AI-пайплайн
+// и печатает метрики качества извлечения.
+func runEval(args []string) int {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	configPath := configFlag(fs)
+	datasetPath := fs.String("dataset", "", "Path to labeled JSONL dataset (required)")
+	reportPath := fs.String("report", "eval_report.json", "Path to the machine-readable JSON report")
+	debugFlag := fs.Bool("debug", false, "Enable debug logging, including raw Ollama responses")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || *datasetPath == "" {
+		logger.Printf("Usage: ./bin/trading.exe eval -config <path_to_config> -dataset <path_to_jsonl> [-report <path>]")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	examples, err := eval.LoadDataset(*datasetPath)
+	if err != nil {
+		logger.Printf("Failed to load dataset: %v", err)
+		return exitFailure
+	}
+	logger.Printf("Loaded %d labeled examples from %s", len(examples), *datasetPath)
+
+	aiClient, err := newAIClient(cfg.AI, *debugFlag)
+	if err != nil {
+		logger.Printf("Failed to create AI client: %v", err)
+		return exitFailure
+	}
+
+	report := eval.Run(context.Background(), aiClient, examples)
+	report.Dataset = *datasetPath
+	report.Model = cfg.AI.OllamaModel
+	report.PromptVersion = cfg.AI.PromptVersion
+
+	logger.Print("\n=== Evaluation results ===")
+	logger.Printf("Examples: %d", report.Examples)
+	logger.Printf("Exact match rate: %.3f", report.ExactMatchRate)
+	logger.Printf("Parse failure rate: %.3f (%d)", report.ParseFailureRate, report.ParseFailures)
+	logger.Printf("Other errors: %d", report.Errors)
+	logger.Printf("Skipped by pre-filter: %d (with gold predictions: %d)", report.Skipped, report.SkippedWithGold)
+	for _, field := range eval.Fields {
+		score := report.Fields[field]
+		logger.Printf("  %-15s precision %.3f  recall %.3f  F1 %.3f", field, score.Precision, score.Recall, score.F1)
+	}
+	logger.Printf("Latency: mean %.0fms, p50 %.0fms, p90 %.0fms, p99 %.0fms, max %.0fms",
+		report.Latency.MeanMs, report.Latency.P50Ms, report.Latency.P90Ms, report.Latency.P99Ms, report.Latency.MaxMs)
+
+	if err := eval.WriteReport(report, *reportPath); err != nil {
+		logger.Printf("Failed to write report: %v", err)
+		return exitFailure
+	}
+	logger.Printf("Report written to %s", *reportPath)
+
+	return exitOK
+}
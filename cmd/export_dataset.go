@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/dataset"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// runExportDataset выполняет команду export dataset: выгружает проверенные прогнозы и сообщения без сигналов
+// в JSONL чат-формата для дообучения локальных моделей.
+func runExportDataset(args []string) int {
+	fs := flag.NewFlagSet("export dataset", flag.ExitOnError)
+	configPath := configFlag(fs)
+	outDir := fs.String("out-dir", "dataset", "Directory for train.jsonl and validation.jsonl")
+	validation := fs.Float64("validation", 0.1, "Share of samples per channel put into the validation split")
+	perChannel := fs.Int("per-channel", 0, "Maximum number of samples per channel, 0 for no limit")
+	seed := fs.Int64("seed", 42, "Random seed for sampling and splitting")
+	prompt := fs.String("prompt", "", "Prompt version or template file for the system prompt (default: ai.prompt_version from config)")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || *validation < 0 || *validation >= 1 {
+		logger.Printf("Usage: ./bin/trading.exe export dataset -config <path_to_config> [-out-dir <dir>] [-validation 0.1] [-per-channel <n>] [-seed <n>]")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	if *prompt == "" {
+		*prompt = cfg.AI.PromptVersion
+	}
+	promptVersion, promptTemplate, err := ai.ResolvePrompt(*prompt)
+	if err != nil {
+		logger.Printf("Failed to resolve prompt: %v", err)
+		return exitFailure
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	reviewed, err := dbStorage.GetReviewedMessages(context.Background())
+	if err != nil {
+		logger.Printf("Failed to load reviewed messages: %v", err)
+		return exitFailure
+	}
+
+	samples := dataset.FromReviewed(reviewed)
+	total := len(samples)
+	samples = dataset.Dedup(samples)
+	duplicates := total - len(samples)
+	samples = dataset.Stratify(samples, *perChannel, *seed)
+	train, validationSamples := dataset.Split(samples, *validation, *seed)
+
+	noSignal := 0
+	for _, sample := range samples {
+		if len(sample.Answer) == 0 {
+			noSignal++
+		}
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		logger.Printf("Failed to create output directory: %v", err)
+		return exitFailure
+	}
+	systemPrompt := ai.PromptInstructions(promptTemplate)
+	trainPath := filepath.Join(*outDir, "train.jsonl")
+	validationPath := filepath.Join(*outDir, "validation.jsonl")
+	if err := dataset.WriteJSONL(trainPath, systemPrompt, train); err != nil {
+		logger.Printf("Failed to write train split: %v", err)
+		return exitFailure
+	}
+	if err := dataset.WriteJSONL(validationPath, systemPrompt, validationSamples); err != nil {
+		logger.Printf("Failed to write validation split: %v", err)
+		return exitFailure
+	}
+
+	logger.Printf("Exported %d samples (%d without signals, %d duplicates removed) using prompt %s", len(samples), noSignal, duplicates, promptVersion)
+	logger.Printf("Train: %d samples -> %s", len(train), trainPath)
+	logger.Printf("Validation: %d samples -> %s", len(validationSamples), validationPath)
+
+	return exitOK
+}
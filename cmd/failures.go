@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// runRetry выполняет команду retry: повторяет анализ сообщений из analysis_failures, время попытки которых
+// наступило. Повторный анализ заменяет результаты, частично сохраненные неудачной попыткой.
+func runRetry(args []string) int {
+	fs := flag.NewFlagSet("retry", flag.ExitOnError)
+	flags := analyzeFlags{reanalyze: true}
+	flags.configPath = configFlag(fs)
+	fs.StringVar(&flags.outputTo, "output-to", "db", "Comma-separated outputs: console, file (jsonl), json, csv, db; file outputs accept kind=path")
+	fs.StringVar(&flags.outputFile, "output-file", "analysis_results.jsonl", "Default path of the JSONL output file")
+	fs.BoolVar(&flags.debug, "debug", false, "Enable debug logging, including raw Ollama responses")
+	limit := fs.Int("limit", 100, "Maximum number of failed messages to retry")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *flags.configPath == "" || *limit <= 0 {
+		logger.Printf("Usage: ./bin/trading.exe retry -config <path_to_config> [-output-to db] [-limit 100]")
+		return exitUsage
+	}
+	targets, err := parseOutputTargets(flags.outputTo, flags.outputFile)
+	if err != nil {
+		logger.Printf("Invalid output-to option: %v", err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a, err := newAnalyzer(ctx, flags, targets, logger)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+	defer func() {
+		if err := a.Close(); err != nil {
+			logger.Printf("Failed to close output: %v", err)
+		}
+	}()
+
+	retried, resolved, err := a.retryFailures(ctx, *limit)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+	if ctx.Err() != nil {
+		logger.Print("Interrupted, results written so far are saved")
+		return exitInterrupted
+	}
+	if retried == 0 {
+		logger.Print("No failed messages are due for retry.")
+		return exitOK
+	}
+	logger.Printf("Retried %d failed messages, %d succeeded", retried, resolved)
+	if resolved < retried {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runFailures выполняет команду failures: выводит сводку неудачных анализов по классам ошибок.
+func runFailures(args []string) int {
+	fs := flag.NewFlagSet("failures", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" {
+		logger.Printf("Usage: ./bin/trading.exe failures -config <path_to_config>")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	summary, err := dbStorage.GetFailureSummary(context.Background())
+	if err != nil {
+		logger.Printf("Failed to load failures: %v", err)
+		return exitFailure
+	}
+	if len(summary) == 0 {
+		logger.Print("No failed analyses recorded.")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLASS\tPENDING\tEXHAUSTED\tRESOLVED\tMAX ATTEMPTS\tLAST FAILED\tLAST ERROR")
+	for _, item := range summary {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			item.ErrorClass, item.Pending, item.Exhausted, item.Resolved, item.MaxAttempts,
+			item.LastFailedAt.Local().Format(time.DateTime), truncateError(item.LastError, 80))
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+// truncateError сокращает текст ошибки до limit символов для вывода в таблицу.
+func truncateError(message string, limit int) string {
+	runes := []rune(message)
+	if len(runes) <= limit {
+		return message
+	}
+	return string(runes[:limit-1]) + "…"
+}
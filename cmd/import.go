@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"rkata-ai/trade-radar/internal/output"
+	"rkata-ai/trade-radar/internal/storage"
+	"rkata-ai/trade-radar/internal/telegram"
+)
+
+// runImport выполняет команду import: загружает внешние данные в базу данных.
+func runImport(args []string) int {
+	return runSubcommand("import", []command{
+		{"results", "Save analysis results from a JSONL file written by 'analyze -output-to file' to the database", runImportResults},
+		{"telegram-export", "Add channel messages from a Telegram Desktop export (result.json) to the messages table", runImportTelegramExport},
+	}, args)
+}
+
+// runImportResults выполняет команду import results: сохраняет в базу результаты, записанные ранее в JSONL-файл,
+// так же, как их сохранил бы приемник db. Позволяет проанализировать сообщения без записи в базу, проверить файл
+// и только потом загрузить его.
+func runImportResults(args []string) int {
+	fs := flag.NewFlagSet("import results", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || fs.NArg() != 1 {
+		logger.Printf("Usage: ./bin/trading.exe import results -config <path_to_config> <results.jsonl|->")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	var input io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			logger.Printf("Failed to open results file: %v", err)
+			return exitFailure
+		}
+		defer file.Close()
+		input = file
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	ctx := context.Background()
+	// Модель и промт, которыми получены результаты файла, неизвестны, поэтому у запуска записывается только версия сборки
+	run := storage.AnalysisRun{
+		BinaryVersion: sql.NullString{String: binaryVersion(), Valid: true},
+		StartedAt:     time.Now(),
+	}
+	if err := dbStorage.CreateAnalysisRun(ctx, &run); err != nil {
+		logger.Printf("Failed to create analysis run: %v", err)
+		return exitFailure
+	}
+	opts := postgresOptions(cfg)
+	opts.RunID = run.ID
+	sink := output.NewPostgresSink(dbStorage, opts, logger)
+
+	imported, failed := 0, 0
+	err = output.ReadJSONL(input, func(record output.Record) error {
+		result, err := record.Result()
+		if err != nil {
+			return err
+		}
+		countResult(&run, result.Analysis)
+		if err := sink.Write(ctx, result); err != nil {
+			logger.Printf("Failed to import result for message %d: %v", record.MessageID, err)
+			run.MessagesFailed++
+			failed++
+			return nil
+		}
+		imported++
+		return nil
+	})
+	if err != nil {
+		logger.Printf("Failed to read results: %v", err)
+		return exitFailure
+	}
+
+	run.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	if err := dbStorage.FinishAnalysisRun(ctx, &run); err != nil {
+		logger.Printf("Failed to finish analysis run: %v", err)
+	}
+
+	logger.Printf("Imported %d results as analysis run %d, %d failed", imported, run.ID, failed)
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runImportTelegramExport выполняет команду import telegram-export: добавляет в messages сообщения из экспорта
+// Telegram Desktop. Экспорт одного чата импортируется целиком, из полного экспорта аккаунта — только каналы.
+// Уже сохраненные сообщения не изменяются, поэтому экспорт можно импортировать повторно.
+func runImportTelegramExport(args []string) int {
+	fs := flag.NewFlagSet("import telegram-export", flag.ExitOnError)
+	configPath := configFlag(fs)
+	channelID := fs.Int64("channel-id", 0, "Channel ID to store messages under (default: the chat ID from the export); only when importing a single chat")
+	batchSize := fs.Int("batch-size", 500, "Number of messages inserted per transaction")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || fs.NArg() != 1 || *batchSize <= 0 {
+		logger.Printf("Usage: ./bin/trading.exe import telegram-export -config <path_to_config> [-channel-id <id>] [-batch-size 500] <result.json|->")
+		return exitUsage
+	}
+
+	var input io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			logger.Printf("Failed to open export file: %v", err)
+			return exitFailure
+		}
+		defer file.Close()
+		input = file
+	}
+	chats, err := telegram.Parse(input)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+	if len(chats) > 1 {
+		// В полном экспорте аккаунта есть и личные переписки: импортируются только каналы
+		channels := chats[:0]
+		for _, chat := range chats {
+			if chat.IsChannel() {
+				channels = append(channels, chat)
+			}
+		}
+		logger.Printf("Account export has %d chats, importing %d channels", len(chats), len(channels))
+		chats = channels
+	}
+	if *channelID != 0 && len(chats) != 1 {
+		logger.Print("-channel-id can only be used when importing a single chat")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	ctx := context.Background()
+	importedAt := time.Now()
+	total, inserted := 0, 0
+	for _, chat := range chats {
+		id := chat.ID
+		if *channelID != 0 {
+			id = *channelID
+		}
+		messages, skipped, err := chat.Convert(id, importedAt)
+		if err != nil {
+			logger.Printf("Failed to convert chat %q: %v", chat.Name, err)
+			return exitFailure
+		}
+
+		chatInserted := 0
+		for start := 0; start < len(messages); start += *batchSize {
+			batch := messages[start:min(start+*batchSize, len(messages))]
+			n, err := dbStorage.SaveMessages(ctx, batch)
+			if err != nil {
+				logger.Printf("Failed to import chat %q: %v", chat.Name, err)
+				return exitFailure
+			}
+			chatInserted += n
+		}
+		logger.Printf("Chat %q (channel %d): %d new messages, %d already present, %d service messages skipped",
+			chat.Name, id, chatInserted, len(messages)-chatInserted, skipped)
+		total += len(messages)
+		inserted += chatInserted
+	}
+
+	logger.Printf("Imported %d new messages from %d chats, %d already present", inserted, len(chats), total-inserted)
+	return exitOK
+}
+
+// runExport выполняет команду export: выгружает данные из базы.
+func runExport(args []string) int {
+	return runSubcommand("export", []command{
+		{"dataset", "Export reviewed predictions as a chat-format fine-tuning dataset", runExportDataset},
+	}, args)
+}
@@ -1,239 +1,45 @@
 package main
 
 import (
-	"context"
-	"database/sql"
-	"encoding/json"
-	"flag"
 	"fmt"
-	"log"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
-	"time"
-
-	"errors"
-	"rkata-ai/trade-radar/internal/ai"
-	"rkata-ai/trade-radar/internal/config"
-	"rkata-ai/trade-radar/internal/storage"
 )
 
 func main() {
-	// Парсинг флагов командной строки
-	var configPath string
-	var outputTo string
-	var outputFilePath string
-	var debugFlag bool
-
-	flag.StringVar(&configPath, "config", "", "Path to configuration file (required)")
-	flag.StringVar(&outputTo, "output-to", "console", "Output results to 'console' or 'file' or 'db'")
-	flag.StringVar(&outputFilePath, "output-file", "analysis_results.json", "Path to the output JSON file if output-to is 'file'")
-	flag.BoolVar(&debugFlag, "debug", false, "Enable debug logging, including raw Ollama responses")
-	flag.Parse()
-
-	outputTo = strings.TrimSpace(outputTo) // Очищаем значение outputTo от пробельных символов
-
-	// Инициализация логгера
-	logger := log.Default()
-
-	// Проверяем, что флаг config передан
-	if configPath == "" {
-		logger.Printf("Usage: ./bin/trading.exe -config <path_to_config> [--output-to <console|file|db>] [--output-file <path>]")
-		os.Exit(1)
-	}
-
-	logger.Print("Starting R&D research for trading channel rating system")
-	logger.Printf("Using config file: %s", configPath)
-
-	// Загрузка конфигурации
-	cfg, err := config.Load(configPath)
-	if err != nil {
-		logger.Fatalf("Failed to load config: %v", err)
-	}
-
-	// Логируем загруженные параметры конфигурации
-	logger.Printf("Config loaded successfully:")
-	logger.Printf("  AI.OllamaBaseURL: %s", cfg.AI.OllamaBaseURL)
-	logger.Printf("  AI.OllamaModel: %s", cfg.AI.OllamaModel)
-	logger.Printf("  AI.Debug: %t", cfg.AI.Debug)
-	logger.Printf("  AI.Temperature: %.2f", cfg.AI.Temperature)
-	logger.Printf("  AI.TopP: %.2f", cfg.AI.TopP)
-	logger.Printf("  AI.MaxTokens: %d", cfg.AI.MaxTokens)
-	logger.Printf("  AI.Stop: %v", cfg.AI.Stop)
-	logger.Printf("  Database.Host: %s", cfg.Database.Host)
-	logger.Printf("  Database.Port: %d", cfg.Database.Port)
-	logger.Printf("  Database.User: %s", cfg.Database.User)
-	logger.Printf("  Database.DBName: %s", cfg.Database.DBName)
-	logger.Printf("  Database.SSLMode: %s", cfg.Database.SSLMode)
-	logger.Printf("  Database.ConnectionString: %s", cfg.Database.ConnectionString)
-
-	// Инициализация AI клиента
-	aiClient := ai.NewOllamaClient(
-		cfg.AI.OllamaBaseURL,
-		cfg.AI.OllamaModel,
-		debugFlag,
-		cfg.AI.Temperature,
-		cfg.AI.TopP,
-		cfg.AI.MaxTokens,
-		cfg.AI.Stop,
-	)
+	os.Exit(run(os.Args[1:]))
+}
 
-	// Инициализация хранилища базы данных
-	var dbStorage storage.Storage
-	dbStorage, err = storage.NewPostgresStorage(&cfg.Database)
-	if err != nil {
-		logger.Fatalf("Failed to connect to database: %v", err)
+// run выбирает подкоманду по первому аргументу и возвращает ее код завершения.
+func run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
 	}
-	defer dbStorage.Close()
 
-	var messages []storage.Message
-	messages, err = dbStorage.GetMessagesWithoutPredictions(context.Background(), 1000) // Ограничиваем до 100 сообщений за раз
-	if err != nil {
-		logger.Fatalf("Failed to get messages from database: %v", err)
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return exitOK
 	}
 
-	if len(messages) == 0 {
-		logger.Print("No new messages to analyze.")
-		return
+	// Запуск без команды, только с флагами (./bin/trading.exe -config ...), по-прежнему означает analyze
+	if strings.HasPrefix(name, "-") {
+		return runAnalyze(args)
 	}
-	logger.Printf("Read %d messages from database", len(messages))
-
-	var allAnalyses []*ai.MessageAnalysis
-	for idx, message := range messages {
-		logger.Printf("Analyzing message %d/%d (ID: %d): %s", idx+1, len(messages), message.TelegramID, message.Text.String)
-		analysis, err := aiClient.AnalyzeMessage(context.Background(), message.Text.String, fmt.Sprintf("%d", message.ChannelID), message.TelegramID)
-		if err != nil {
-			logger.Printf("Failed to analyze message %d (ID: %d): %v", idx+1, message.TelegramID, err)
-			continue
-		}
-		allAnalyses = append(allAnalyses, analysis)
-
-		if outputTo == "db" {
-			// Сохраняем прогнозы в базу данных
-			for _, pred := range analysis.Predictions {
-				// Проверяем, что Ticker не пустой и PredictionType не 'Неопределенный' перед сохранением
-				if pred.Ticker == "" || pred.PredictionType == "Неопределенный" {
-					logger.Printf("Prediction for message %d with ticker '%s' and type '%s' ignored (empty ticker or 'Неопределенный' type). Skipping.", message.TelegramID, pred.Ticker, pred.PredictionType)
-					continue
-				}
-
-				stock, err := dbStorage.GetStock(context.Background(), pred.Ticker)
-				if err != nil {
-					if errors.Is(err, sql.ErrNoRows) {
-						logger.Printf("Stock with ticker '%s' not found. Saving as raw prediction.", pred.Ticker)
-						rawPrediction := storage.RawPrediction{
-							MessageID:           message.TelegramID,
-							RawTicker:           sql.NullString{String: pred.Ticker, Valid: true},
-							PredictionType:      sql.NullString{String: pred.PredictionType, Valid: pred.PredictionType != ""},
-							TargetPrice:         sql.NullFloat64{Float64: pred.TargetPrice.FloatValue, Valid: !pred.TargetPrice.IsNull && !pred.TargetPrice.IsString},
-							TargetChangePercent: sql.NullFloat64{Float64: pred.TargetChangePercent.FloatValue, Valid: !pred.TargetChangePercent.IsNull && !pred.TargetChangePercent.IsString},
-							Period:              sql.NullString{String: pred.Period, Valid: pred.Period != ""},
-							Recommendation:      sql.NullString{String: pred.Recommendation, Valid: pred.Recommendation != ""},
-							Direction:           sql.NullString{String: pred.Direction, Valid: pred.Direction != ""},
-							JustificationText:   sql.NullString{String: pred.JustificationText, Valid: pred.JustificationText != ""},
-							PredictedAt:         time.Now(),
-						}
-						logger.Printf("Attempting to save raw prediction for message %d with ticker '%s'", message.TelegramID, pred.Ticker)
-						err = dbStorage.SaveRawPrediction(context.Background(), &rawPrediction)
-						if err != nil {
-							logger.Printf("Failed to save raw prediction for message %d: %v", message.TelegramID, err)
-						} else {
-							logger.Printf("Raw prediction for message %d saved to DB.", message.TelegramID)
-						}
-						continue
-					}
-					logger.Printf("Failed to get stock for ticker %s: %v", pred.Ticker, err)
-					continue
-				}
 
-				dbPrediction := storage.Prediction{
-					MessageID:           message.TelegramID,
-					StockID:             stock.ID,
-					PredictionType:      sql.NullString{String: pred.PredictionType, Valid: pred.PredictionType != ""},
-					TargetPrice:         sql.NullFloat64{Float64: pred.TargetPrice.FloatValue, Valid: !pred.TargetPrice.IsNull && !pred.TargetPrice.IsString},
-					TargetChangePercent: sql.NullFloat64{Float64: pred.TargetChangePercent.FloatValue, Valid: !pred.TargetChangePercent.IsNull && !pred.TargetChangePercent.IsString},
-					Period:              sql.NullString{String: pred.Period, Valid: pred.Period != ""},
-					Recommendation:      sql.NullString{String: pred.Recommendation, Valid: pred.Recommendation != ""},
-					Direction:           sql.NullString{String: pred.Direction, Valid: pred.Direction != ""},
-					JustificationText:   sql.NullString{String: pred.JustificationText, Valid: pred.JustificationText != ""},
-					PredictedAt:         time.Now(),
-				}
-
-				err = dbStorage.SavePrediction(context.Background(), &dbPrediction)
-				if err != nil {
-					logger.Printf("Failed to save prediction for message %d: %v", message.TelegramID, err)
-				} else {
-					logger.Printf("Prediction for message %d saved to DB.", message.TelegramID)
-				}
-			}
-		} else {
-			switch outputTo {
-			case "console":
-				logger.Printf("\n### Message %d ###\n", idx+1)
-				if len(analysis.Predictions) > 0 {
-					for i, prediction := range analysis.Predictions {
-						// Проверяем, что Ticker не пустой и PredictionType не 'Неопределенный' перед выводом в консоль
-						if prediction.Ticker == "" || prediction.PredictionType == "Неопределенный" {
-							logger.Printf("Prediction for message %d with ticker '%s' and type '%s' ignored (empty ticker or 'Неопределенный' type). Skipping console output.", prediction.MessageID, prediction.Ticker, prediction.PredictionType)
-							continue
-						}
-						logger.Printf("--- Prediction %d ---", i+1)
-						logger.Printf("  Message ID: %d", prediction.MessageID)
-						logger.Printf("  Prediction Type: %s", prediction.PredictionType)
-						logger.Printf("  Ticker: %s", prediction.Ticker)
-						logger.Printf("  Target Price: %s", prediction.TargetPrice.String())
-						logger.Printf("  Target Change Percent: %s", prediction.TargetChangePercent.String())
-						logger.Printf("  Period: %s", prediction.Period)
-						logger.Printf("  Recommendation: %s", prediction.Recommendation)
-						logger.Printf("  Direction: %s", prediction.Direction)
-						logger.Printf("  Justification Text: %s\n", prediction.JustificationText)
-					}
-				} else {
-					logger.Printf("  No financial predictions available for message %d.\n", idx+1)
-				}
-			case "file":
-				var filteredPredictions []ai.FinancialPrediction
-				for _, pred := range analysis.Predictions {
-					if pred.Ticker != "" && pred.PredictionType != "Неопределенный" {
-						filteredPredictions = append(filteredPredictions, pred)
-					}
-				}
-				// Если есть валидные предсказания, создаем новый MessageAnalysis и добавляем его в allAnalyses
-				if len(filteredPredictions) > 0 {
-					filteredAnalysis := &ai.MessageAnalysis{
-						Predictions: filteredPredictions,
-					}
-					allAnalyses = append(allAnalyses, filteredAnalysis)
-				}
-
-				outputJSON, marshalErr := json.MarshalIndent(allAnalyses, "", "  ")
-				if marshalErr != nil {
-					logger.Printf("Failed to marshal analysis results to JSON for message %d: %v", idx+1, marshalErr)
-				} else {
-					err := os.WriteFile(outputFilePath, outputJSON, 0644)
-					if err != nil {
-						logger.Printf("Failed to write analysis results to file %s for message %d: %v", outputFilePath, idx+1, err)
-					} else {
-						logger.Printf("Analysis results for message %d written to %s", idx+1, outputFilePath)
-					}
-				}
-			}
-		}
+	if alias, ok := commandAliases[name]; ok {
+		parts := strings.Fields(alias)
+		name = parts[0]
+		args = append(parts, args[1:]...)
 	}
 
-	// Обработка случая, если outputTo не является ни 'console', ни 'file', ни 'db'
-	if outputTo != "console" && outputTo != "file" && outputTo != "db" {
-		logger.Fatalf("Invalid output-to option: %s. Use 'console', 'file' or 'db'.", outputTo)
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return exitUsage
 	}
-
-	// Обработка сигналов для graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Ожидание сигнала завершения
-	logger.Print("\n=== Processing completed successfully! ===")
-	logger.Print("Press Ctrl+C to exit...")
-	<-sigChan
-	logger.Print("Shutting down...")
+	return cmd.run(args[1:])
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// runMigrate выполняет команду migrate: применяет встроенные в бинарник SQL-миграции, которых еще нет в schema_migrations.
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := configFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "List pending migrations without applying them")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" {
+		logger.Printf("Usage: ./bin/trading.exe migrate -config <path_to_config> [-dry-run]")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	versions, err := dbStorage.Migrate(context.Background(), *dryRun)
+	for _, version := range versions {
+		if *dryRun {
+			logger.Printf("Pending migration %s", version)
+		} else {
+			logger.Printf("Applied migration %s", version)
+		}
+	}
+	if err != nil {
+		logger.Printf("Migration failed: %v", err)
+		return exitFailure
+	}
+	if len(versions) == 0 {
+		logger.Print("Database schema is up to date")
+	}
+	return exitOK
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/config"
+	"rkata-ai/trade-radar/internal/output"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// version — версия сборки, задается при сборке: go build -ldflags "-X main.version=v1.2.3".
+// Если не задана, берется из сведений о сборке Go (версия модуля и ревизия VCS).
+var version string
+
+// providerOllama — поставщик модели в analysis_runs.
+const providerOllama = "ollama"
+
+// runSampling — параметры генерации, сохраняемые в analysis_runs.sampling.
+type runSampling struct {
+	ai.OllamaOptions
+	EnsembleModels  []string `json:"ensemble_models,omitempty"`
+	EnsembleSamples int      `json:"ensemble_samples,omitempty"`
+}
+
+// newAnalysisRun описывает запуск анализа с текущей конфигурацией: модель, промт и его хеш, параметры генерации
+// и версию сборки.
+func newAnalysisRun(cfg *config.Config, reanalysis bool) (*storage.AnalysisRun, error) {
+	promptVersion, template, err := ai.ResolvePrompt(cfg.AI.PromptVersion)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(template))
+
+	sampling := runSampling{OllamaOptions: ai.OllamaOptions{
+		NumCtx:      cfg.AI.NumCtx,
+		Temperature: cfg.AI.Temperature,
+		TopP:        cfg.AI.TopP,
+		MaxTokens:   cfg.AI.MaxTokens,
+		Stop:        cfg.AI.Stop,
+	}}
+	if cfg.AI.Ensemble.Enabled {
+		sampling.EnsembleModels = cfg.AI.Ensemble.Models
+		sampling.EnsembleSamples = cfg.AI.Ensemble.Samples
+	}
+	samplingJSON, err := json.Marshal(sampling)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sampling options: %w", err)
+	}
+
+	return &storage.AnalysisRun{
+		Reanalysis:    reanalysis,
+		Model:         sql.NullString{String: cfg.AI.OllamaModel, Valid: true},
+		Provider:      sql.NullString{String: providerOllama, Valid: true},
+		PromptVersion: sql.NullString{String: promptVersion, Valid: true},
+		PromptHash:    sql.NullString{String: hex.EncodeToString(hash[:]), Valid: true},
+		Sampling:      samplingJSON,
+		BinaryVersion: sql.NullString{String: binaryVersion(), Valid: true},
+		StartedAt:     time.Now(),
+	}, nil
+}
+
+// binaryVersion возвращает версию сборки: main.version, версию модуля или ревизию VCS с пометкой о незакоммиченных
+// изменениях, либо "unknown".
+func binaryVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			if setting.Value == "true" {
+				modified = "-dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	return revision + modified
+}
+
+// countResult учитывает результат анализа сообщения в счетчиках запуска.
+func countResult(run *storage.AnalysisRun, analysis *ai.MessageAnalysis) {
+	run.MessagesTotal++
+	if analysis.Skipped {
+		run.MessagesSkipped++
+	}
+	for _, prediction := range analysis.Predictions {
+		if output.Reportable(prediction) {
+			run.PredictionsTotal++
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"rkata-ai/trade-radar/internal/attribution"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// runRatings выполняет команду ratings: определяет первоисточники сигналов по пересылкам и копиям
+// и выводит сводку по каналам с пометкой копирующих каналов и их типичной задержкой.
+func runRatings(args []string) int {
+	fs := flag.NewFlagSet("ratings", flag.ExitOnError)
+	configPath := configFlag(fs)
+	days := fs.Int("days", 30, "Number of days of signals to rate")
+	window := fs.Duration("copy-window", 6*time.Hour, "Maximum delay after the first publication for a signal to count as a copy")
+	runID := fs.Int64("run", storage.LatestRun, "Rate predictions of this analysis run; 0 for the current results")
+	copierShare := fs.Float64("copier-share", 0.5, "Share of forwarded and copied signals at which a channel is flagged as a copier")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || *days <= 0 || *window <= 0 || *copierShare <= 0 || *copierShare > 1 || *runID < 0 {
+		logger.Printf("Usage: ./bin/trading.exe ratings -config <path_to_config> [-days 30] [-copy-window 6h] [-copier-share 0.5] [-run <id>]")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	rows, err := dbStorage.GetSignals(context.Background(), time.Now().AddDate(0, 0, -*days), *runID)
+	if err != nil {
+		logger.Printf("Failed to load signals: %v", err)
+		return exitFailure
+	}
+
+	signals := make([]attribution.Signal, 0, len(rows))
+	for _, row := range rows {
+		signal, err := attribution.NewSignal(row)
+		if err != nil {
+			logger.Printf("Message %d in channel %d: %v", row.Message.TelegramID, row.Message.ChannelID, err)
+		}
+		signals = append(signals, signal)
+	}
+
+	attributions := attribution.Attribute(signals, *window)
+	ratings := attribution.Rate(attributions, *copierShare)
+	logger.Printf("Rated %d signals from the last %d days across %d channels", len(signals), *days, len(ratings))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANNEL\tSIGNALS\tORIGINAL\tFORWARDED\tCOPIED\tCREDITED\tCOPY SHARE\tMEDIAN LAG\tCOPIER")
+	for _, rating := range ratings {
+		copier := ""
+		if rating.Copier {
+			copier = "yes"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%.2f\t%s\t%s\n",
+			rating.ChannelID, rating.Signals, rating.Original, rating.Forwarded, rating.Copied,
+			rating.Credited, rating.CopyShare, rating.MedianLag.Round(time.Minute), copier)
+	}
+	w.Flush()
+
+	return exitOK
+}
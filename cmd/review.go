@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/review"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+const reviewHelp = `Commands:
+  a                  accept (promote into predictions)
+  e <field> <value>  edit a field; "null" clears it
+  m <TICKER>         map to a stock from the stocks table
+  r                  reject
+  n                  reject: the message has no signal at all
+  s                  skip for now
+  q                  quit`
+
+// runReview выполняет команду review: терминальная очередь ручной проверки прогнозов из raw_predictions.
+// Проверяющий принимает, исправляет, сопоставляет с акцией или отклоняет прогноз; решение сохраняется в prediction_reviews.
+func runReview(args []string) int {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	configPath := configFlag(fs)
+	reviewer := fs.String("reviewer", os.Getenv("USER"), "Reviewer name stored with every decision")
+	limit := fs.Int("limit", 50, "Maximum number of pending predictions to load")
+	fs.Parse(args)
+
+	logger := log.Default()
+
+	if *configPath == "" || *reviewer == "" {
+		logger.Printf("Usage: ./bin/trading.exe review -config <path_to_config> [-reviewer <name>] [-limit <n>]")
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(logger, *configPath)
+	if err != nil {
+		logger.Print(err)
+		return exitFailure
+	}
+
+	dbStorage, err := storage.NewPostgresStorage(&cfg.Database)
+	if err != nil {
+		logger.Printf("Failed to connect to database: %v", err)
+		return exitFailure
+	}
+	defer dbStorage.Close()
+
+	ctx := context.Background()
+	items, err := dbStorage.GetPendingReviews(ctx, *limit)
+	if err != nil {
+		logger.Printf("Failed to load review queue: %v", err)
+		return exitFailure
+	}
+	if len(items) == 0 {
+		fmt.Println("Review queue is empty.")
+		return exitOK
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	out := os.Stdout
+	var accepted, rejected, noSignal, skipped int
+
+items:
+	for idx, item := range items {
+		draft := review.NewDraft(item)
+		if stock, err := dbStorage.GetStock(ctx, item.RawPrediction.RawTicker.String); err == nil {
+			draft.Stock = stock
+		}
+
+		printReviewItem(out, idx+1, len(items), draft)
+		for {
+			fmt.Fprint(out, "> ")
+			if !in.Scan() {
+				break items
+			}
+			command, rest, _ := strings.Cut(strings.TrimSpace(in.Text()), " ")
+
+			switch command {
+			case "a":
+				decision, prediction, err := draft.Accept(*reviewer, time.Now())
+				if errors.Is(err, review.ErrNoStock) {
+					fmt.Fprintf(out, "Ticker %q is not in stocks, map it first with: m <TICKER>\n", draft.Prediction.RawTicker.String)
+					continue
+				} else if err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					continue
+				}
+				if err := dbStorage.SaveReview(ctx, decision, prediction); err != nil {
+					logger.Printf("Failed to save review for raw prediction %d: %v", item.RawPrediction.ID, err)
+					return exitFailure
+				}
+				fmt.Fprintf(out, "Accepted (%s) as prediction %d.\n", decision.Decision, prediction.ID)
+				accepted++
+				continue items
+			case "e":
+				field, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+				if err := draft.Set(field, value); err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					continue
+				}
+				printDraft(out, draft)
+			case "m":
+				ticker := ai.NormalizeTicker(rest)
+				stock, err := dbStorage.GetStock(ctx, ticker)
+				if errors.Is(err, sql.ErrNoRows) {
+					fmt.Fprintf(out, "Stock %q not found.\n", ticker)
+					continue
+				} else if err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					continue
+				}
+				draft.MapStock(stock)
+				fmt.Fprintf(out, "Mapped to %s (stock %d).\n", stock.Ticker, stock.ID)
+			case "r":
+				decision, err := draft.Reject(*reviewer, time.Now())
+				if err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					continue
+				}
+				if err := dbStorage.SaveReview(ctx, decision, nil); err != nil {
+					logger.Printf("Failed to save review for raw prediction %d: %v", item.RawPrediction.ID, err)
+					return exitFailure
+				}
+				fmt.Fprintln(out, "Rejected.")
+				rejected++
+				continue items
+			case "n":
+				decision, err := draft.NoSignal(*reviewer, time.Now())
+				if err != nil {
+					fmt.Fprintf(out, "Error: %v\n", err)
+					continue
+				}
+				if err := dbStorage.SaveReview(ctx, decision, nil); err != nil {
+					logger.Printf("Failed to save review for raw prediction %d: %v", item.RawPrediction.ID, err)
+					return exitFailure
+				}
+				fmt.Fprintln(out, "Rejected, no signal in the message.")
+				noSignal++
+				continue items
+			case "s":
+				skipped++
+				continue items
+			case "q":
+				break items
+			default:
+				fmt.Fprintln(out, reviewHelp)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\nReviewed: %d accepted, %d rejected, %d without signal, %d skipped.\n", accepted, rejected, noSignal, skipped)
+	return exitOK
+}
+
+// printReviewItem выводит исходное сообщение и прогноз, ожидающий проверки.
+func printReviewItem(out io.Writer, position, total int, draft *review.Draft) {
+	item := draft.Item
+	raw := item.RawPrediction
+
+	fmt.Fprintf(out, "\n=== Review %d/%d: raw prediction %d ===\n", position, total, raw.ID)
+	fmt.Fprintf(out, "Reason: %s", nullString(raw.ReviewReason))
+	if raw.Confidence.Valid {
+		fmt.Fprintf(out, ", confidence %.2f", raw.Confidence.Float64)
+	}
+	if raw.Agreement.Valid {
+		fmt.Fprintf(out, ", agreement %.2f", raw.Agreement.Float64)
+	}
+	if raw.EvidenceStatus.Valid {
+		fmt.Fprintf(out, ", evidence %s", raw.EvidenceStatus.String)
+	}
+	fmt.Fprintln(out)
+	if item.ChannelID.Valid {
+		fmt.Fprintf(out, "Channel %d, message %d, sent %s\n", item.ChannelID.Int64, raw.MessageID, item.SentAt.Time.Format(time.DateTime))
+	} else {
+		fmt.Fprintf(out, "Message %d (not found in messages)\n", raw.MessageID)
+	}
+	fmt.Fprintln(out, "--- Message ---")
+	fmt.Fprintln(out, nullString(item.MessageText))
+	printDraft(out, draft)
+	fmt.Fprintln(out, reviewHelp)
+}
+
+// printDraft выводит текущие значения полей прогноза с отметкой исправленных.
+func printDraft(out io.Writer, draft *review.Draft) {
+	p := draft.Prediction
+	values := map[string]string{
+		review.FieldTicker:              nullString(p.RawTicker),
+		review.FieldPredictionType:      nullString(p.PredictionType),
+		review.FieldPeriod:              nullString(p.Period),
+		review.FieldRecommendation:      nullString(p.Recommendation),
+		review.FieldDirection:           nullString(p.Direction),
+		review.FieldTargetPrice:         nullFloat(p.TargetPrice),
+		review.FieldTargetChangePercent: nullFloat(p.TargetChangePercent),
+		review.FieldEntryPrice:          nullFloat(p.EntryPriceMin) + "-" + nullFloat(p.EntryPriceMax),
+		review.FieldStopLoss:            nullFloat(p.StopLoss),
+		review.FieldTakeProfitLevels:    fmt.Sprint([]float64(p.TakeProfitLevels)),
+		review.FieldJustificationText:   nullString(p.JustificationText),
+	}
+
+	fmt.Fprintln(out, "--- Prediction ---")
+	for _, field := range review.Fields {
+		marker := " "
+		if _, ok := draft.Changes[field]; ok {
+			marker = "*"
+		}
+		fmt.Fprintf(out, "%s %-22s %s\n", marker, field, values[field])
+	}
+	if draft.Stock != nil {
+		fmt.Fprintf(out, "  %-22s %s (id %d)\n", "stock", draft.Stock.Ticker, draft.Stock.ID)
+	} else {
+		fmt.Fprintf(out, "  %-22s not mapped\n", "stock")
+	}
+}
+
+func nullString(value sql.NullString) string {
+	if !value.Valid {
+		return "null"
+	}
+	return value.String
+}
+
+func nullFloat(value sql.NullFloat64) string {
+	if !value.Valid {
+		return "null"
+	}
+	return fmt.Sprintf("%.2f", value.Float64)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/source"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// selectionFlags — флаги выборки сообщений для analyze и serve.
+type selectionFlags struct {
+	channels    string
+	ids         string
+	since       string
+	until       string
+	messageType string
+	newestFirst bool
+	limit       int
+	pageSize    int
+}
+
+func (f *selectionFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.channels, "channels", "", "Comma-separated channel IDs to analyze (default: all channels)")
+	fs.StringVar(&f.ids, "ids", "", "Comma-separated Telegram message IDs to analyze")
+	fs.StringVar(&f.since, "since", "", "Analyze messages sent at or after this date (YYYY-MM-DD or RFC 3339)")
+	fs.StringVar(&f.until, "until", "", "Analyze messages sent before this time; a date includes the whole day (YYYY-MM-DD or RFC 3339)")
+	fs.StringVar(&f.messageType, "type", "", "Analyze only messages of this message_type")
+	fs.BoolVar(&f.newestFirst, "newest-first", false, "Analyze the newest messages first")
+	fs.IntVar(&f.limit, "limit", 1000, "Maximum number of messages to analyze, 0 for no limit")
+	fs.IntVar(&f.pageSize, "page-size", 200, "Number of messages loaded from the database per query")
+}
+
+// filter собирает фильтр выборки из флагов.
+func (f *selectionFlags) filter() (storage.MessageFilter, error) {
+	filter := storage.MessageFilter{
+		WithoutPredictions: true,
+		WithoutFailures:    true,
+		MessageType:        strings.TrimSpace(f.messageType),
+		NewestFirst:        f.newestFirst,
+	}
+	if f.limit < 0 || f.pageSize <= 0 {
+		return filter, fmt.Errorf("limit must be non-negative and page-size positive")
+	}
+
+	var err error
+	if filter.ChannelIDs, err = parseIDList(f.channels); err != nil {
+		return filter, fmt.Errorf("invalid -channels: %w", err)
+	}
+	if filter.MessageIDs, err = parseIDList(f.ids); err != nil {
+		return filter, fmt.Errorf("invalid -ids: %w", err)
+	}
+	if filter.SentFrom, err = parseTimeBound(f.since, false); err != nil {
+		return filter, fmt.Errorf("invalid -since: %w", err)
+	}
+	if filter.SentTo, err = parseTimeBound(f.until, true); err != nil {
+		return filter, fmt.Errorf("invalid -until: %w", err)
+	}
+	if !filter.SentFrom.IsZero() && !filter.SentTo.IsZero() && !filter.SentFrom.Before(filter.SentTo) {
+		return filter, fmt.Errorf("-since must be before -until")
+	}
+	return filter, nil
+}
+
+// parseIDList разбирает список идентификаторов через запятую.
+func parseIDList(value string) ([]int64, error) {
+	var ids []int64
+	for _, item := range splitList(value) {
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer ID", item)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseTimeBound разбирает дату YYYY-MM-DD (в UTC) или время RFC 3339. Для верхней границы дата означает
+// конец дня, то есть начало следующего.
+func parseTimeBound(value string, upper bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if upper {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither YYYY-MM-DD nor RFC 3339", value)
+	}
+	return t, nil
+}
+
+// dbSelectionFlags — флаги, которые имеют смысл только при выборке сообщений из базы данных.
+var dbSelectionFlags = []string{"channels", "ids", "since", "until", "type", "newest-first", "page-size", "resume", "reanalyze", "diff", "diff-report"}
+
+// setFlags возвращает флаги из names, явно указанные в командной строке.
+func setFlags(fs *flag.FlagSet, names ...string) []string {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var set []string
+	fs.Visit(func(f *flag.Flag) {
+		if wanted[f.Name] {
+			set = append(set, "-"+f.Name)
+		}
+	})
+	return set
+}
+
+// walkMessages анализирует сообщения источника постранично, пока они не закончатся или не будет отменен ctx.
+// Возвращает число проанализированных и неудачных сообщений.
+func (a *analyzer) walkMessages(ctx context.Context, src source.Source) (int, int, error) {
+	total, failed := 0, 0
+	for ctx.Err() == nil {
+		messages, err := src.Next(ctx)
+		if err != nil {
+			return total, failed, fmt.Errorf("failed to read messages: %w", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		a.logger.Printf("Read %d messages (%d analyzed so far)", len(messages), total)
+
+		failed += a.analyzeMessages(ctx, messages)
+		total += len(messages)
+	}
+	return total, failed, nil
+}
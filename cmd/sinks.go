@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"rkata-ai/trade-radar/internal/config"
+	"rkata-ai/trade-radar/internal/output"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// outputTarget — один приемник из флага --output-to: вид и, для файловых приемников, путь.
+type outputTarget struct {
+	kind string
+	path string
+}
+
+// Пути по умолчанию для файловых приемников без явного пути
+const (
+	defaultJSONPath = "analysis_results.json"
+	defaultCSVPath  = "analysis_results.csv"
+)
+
+// parseOutputTargets разбирает значение --output-to: список приемников через запятую, например "console,db" или
+// "db,csv=signals.csv". "file" — синоним "jsonl"; путь JSONL по умолчанию берется из --output-file.
+func parseOutputTargets(spec, outputFile string) ([]outputTarget, error) {
+	var targets []outputTarget
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, path, _ := strings.Cut(item, "=")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		path = strings.TrimSpace(path)
+
+		switch kind {
+		case "console", "db":
+			if path != "" {
+				return nil, fmt.Errorf("output %q does not take a path", kind)
+			}
+		case "file", "jsonl":
+			kind = "jsonl"
+			if path == "" {
+				path = outputFile
+			}
+		case "json":
+			if path == "" {
+				path = defaultJSONPath
+			}
+		case "csv":
+			if path == "" {
+				path = defaultCSVPath
+			}
+		default:
+			return nil, fmt.Errorf("invalid output %q: use console, file (jsonl), json, csv or db", kind)
+		}
+
+		if seen[kind] {
+			return nil, fmt.Errorf("output %q is specified more than once", kind)
+		}
+		seen[kind] = true
+		targets = append(targets, outputTarget{kind: kind, path: path})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no output specified")
+	}
+	return targets, nil
+}
+
+// hasOutput сообщает, есть ли среди приемников приемник вида kind.
+func hasOutput(targets []outputTarget, kind string) bool {
+	for _, target := range targets {
+		if target.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// openSinks открывает приемники и объединяет их в один. Вместе с ним возвращается JSONL-приемник, если он есть,
+// чтобы продолжить анализ после последней записанной в файл записи. Результаты, сохраняемые в базу, относятся
+// к запуску runID; при повторном анализе они заменяют прежние результаты сообщений.
+func openSinks(targets []outputTarget, cfg *config.Config, dbStorage storage.Storage, flags analyzeFlags, runID int64, logger *log.Logger) (output.Sink, *output.JSONLSink, error) {
+	var sinks []output.Sink
+	var jsonl *output.JSONLSink
+	closeOpened := func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}
+
+	for _, target := range targets {
+		switch target.kind {
+		case "console":
+			sinks = append(sinks, output.NewConsoleSink(logger, cfg.AI.Confidence.Enabled))
+		case "db":
+			opts := postgresOptions(cfg)
+			opts.RunID = runID
+			opts.Supersede = flags.reanalyze
+			sinks = append(sinks, output.NewPostgresSink(dbStorage, opts, logger))
+		case "jsonl":
+			sink, err := output.NewJSONLSink(target.path, flags.resume, flags.fsyncEvery)
+			if err != nil {
+				closeOpened()
+				return nil, nil, err
+			}
+			jsonl = sink
+			sinks = append(sinks, sink)
+		case "json":
+			sinks = append(sinks, output.NewJSONSink(target.path))
+		case "csv":
+			sink, err := output.NewCSVSink(target.path)
+			if err != nil {
+				closeOpened()
+				return nil, nil, err
+			}
+			sinks = append(sinks, sink)
+		}
+		logger.Printf("Writing results to %s", describeTarget(target))
+	}
+	return output.NewMultiSink(sinks...), jsonl, nil
+}
+
+// postgresOptions возвращает пороги ручной проверки прогнозов из конфигурации.
+func postgresOptions(cfg *config.Config) output.PostgresOptions {
+	return output.PostgresOptions{
+		MinAgreement:      cfg.AI.Ensemble.MinAgreement,
+		ConfidenceEnabled: cfg.AI.Confidence.Enabled,
+		MinConfidence:     cfg.AI.Confidence.MinConfidence,
+	}
+}
+
+func describeTarget(target outputTarget) string {
+	if target.path == "" {
+		return target.kind
+	}
+	return fmt.Sprintf("%s (%s)", target.kind, target.path)
+}
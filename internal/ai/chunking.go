@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// maxChunkDepth ограничивает число повторных разбиений фрагмента, ответ на который модель обрезала.
+const maxChunkDepth = 3
+
+// paragraphRe разделяет абзацы: пустая строка, возможно с пробелами.
+var paragraphRe = regexp.MustCompile(`\n\s*\n`)
+
+// SplitMessage разбивает сообщение на фрагменты примерно до maxTokens токенов (см. EstimateTokens).
+// Сообщение режется по абзацам, затем по строкам, затем перед упоминаниями тикеров ($SBER, #GAZP)
+// и только в крайнем случае по словам. Сообщение, которое укладывается в лимит, возвращается целиком.
+func SplitMessage(message string, maxTokens int) []string {
+	if maxTokens <= 0 || EstimateTokens(message) <= maxTokens {
+		return []string{message}
+	}
+
+	var units []string
+	for _, paragraph := range paragraphRe.Split(message, -1) {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			units = append(units, splitUnit(paragraph, maxTokens)...)
+		}
+	}
+	return packUnits(units, "\n\n", maxTokens)
+}
+
+// splitUnit делит слишком длинный абзац по строкам, по границам тикеров или по словам.
+func splitUnit(text string, maxTokens int) []string {
+	if EstimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	if lines := strings.Split(text, "\n"); len(lines) > 1 {
+		var units []string
+		for _, line := range lines {
+			if line = strings.TrimSpace(line); line != "" {
+				units = append(units, splitUnit(line, maxTokens)...)
+			}
+		}
+		return packUnits(units, "\n", maxTokens)
+	}
+
+	if parts := splitAtTickers(text); len(parts) > 1 {
+		var units []string
+		for _, part := range parts {
+			units = append(units, splitUnit(part, maxTokens)...)
+		}
+		return packUnits(units, " ", maxTokens)
+	}
+
+	return packUnits(strings.Fields(text), " ", maxTokens)
+}
+
+// splitAtTickers режет текст перед каждым упоминанием тикера с префиксом, кроме упоминания в начале текста.
+func splitAtTickers(text string) []string {
+	var parts []string
+	start := 0
+	for _, match := range cashtagRe.FindAllStringIndex(text, -1) {
+		if match[0] == 0 {
+			continue
+		}
+		if part := strings.TrimSpace(text[start:match[0]]); part != "" {
+			parts = append(parts, part)
+		}
+		start = match[0]
+	}
+	if part := strings.TrimSpace(text[start:]); part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// packUnits жадно собирает части в фрагменты, не превышающие maxTokens. Часть длиннее лимита остается отдельным фрагментом.
+func packUnits(units []string, separator string, maxTokens int) []string {
+	var chunks []string
+	current := ""
+	for _, unit := range units {
+		if current != "" && EstimateTokens(current+separator+unit) > maxTokens {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if current != "" {
+			current += separator
+		}
+		current += unit
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// SetChunking включает разбиение длинных сообщений. Сообщения длиннее maxMessageTokens анализируются по фрагментам;
+// 0 — не ограничивать длину заранее. Если ответ модели обрезан по num_predict, фрагмент делится на части и анализируется заново.
+func (s *PredictionStep) SetChunking(maxMessageTokens int) {
+	s.chunking = true
+	s.maxMessageTokens = maxMessageTokens
+}
+
+// extractChunks анализирует фрагменты сообщения по очереди и объединяет прогнозы. Фрагменты без прогнозов и
+// с ошибками пропускаются; ошибка возвращается, только если ни один фрагмент не дал прогнозов.
+func (s *PredictionStep) extractChunks(ctx context.Context, client *OllamaClient, chunks []string, messageID int64, replyContext []ContextMessage, depth int) ([]FinancialPrediction, Usage, error) {
+	if len(chunks) == 1 {
+		return s.extractChunk(ctx, client, chunks[0], messageID, replyContext, depth)
+	}
+
+	var merged []FinancialPrediction
+	var usage Usage
+	var lastErr error
+	for idx, chunk := range chunks {
+		predictions, chunkUsage, err := s.extractChunk(ctx, client, chunk, messageID, replyContext, depth)
+		usage.Add(chunkUsage)
+		if err != nil {
+			if !errors.Is(err, ErrNoPredictions) {
+				log.Printf("Chunk %d/%d of message %d failed: %v", idx+1, len(chunks), messageID, err)
+			}
+			lastErr = err
+			continue
+		}
+		merged = mergePredictions(merged, predictions)
+	}
+
+	if len(merged) == 0 {
+		return nil, usage, lastErr
+	}
+	return merged, usage, nil
+}
+
+// extractChunk анализирует один фрагмент. Если ответ обрезан, фрагмент делится на части, пока не кончится глубина разбиения.
+func (s *PredictionStep) extractChunk(ctx context.Context, client *OllamaClient, chunk string, messageID int64, replyContext []ContextMessage, depth int) ([]FinancialPrediction, Usage, error) {
+	predictions, usage, err := s.extractOnce(ctx, client, chunk, messageID, replyContext)
+	if !s.chunking || depth >= maxChunkDepth || !errors.Is(err, ErrTruncatedResponse) {
+		return predictions, usage, err
+	}
+
+	// Лимит в две трети длины дает две части, не разрывая абзацы примерно равной длины
+	parts := SplitMessage(chunk, EstimateTokens(chunk)*2/3)
+	if len(parts) < 2 {
+		return predictions, usage, err
+	}
+	log.Printf("Response for message %d was truncated, retrying in %d chunks", messageID, len(parts))
+
+	predictions, retryUsage, err := s.extractChunks(ctx, client, parts, messageID, replyContext, depth+1)
+	usage.Add(retryUsage)
+	return predictions, usage, err
+}
+
+// mergePredictions добавляет прогнозы фрагмента к уже найденным. Прогноз, уже найденный в предыдущем фрагменте
+// (тот же тикер, направление и цель), не дублируется, а разные прогнозы по одному тикеру, например покупка
+// и более поздняя фиксация прибыли, сохраняются оба.
+func mergePredictions(merged, predictions []FinancialPrediction) []FinancialPrediction {
+	known := make(map[string]bool, len(merged))
+	for _, prediction := range merged {
+		known[chunkPredictionKey(prediction)] = true
+	}
+	for _, prediction := range predictions {
+		key := chunkPredictionKey(prediction)
+		if known[key] {
+			continue
+		}
+		known[key] = true
+		merged = append(merged, prediction)
+	}
+	return merged
+}
+
+// chunkPredictionKey возвращает ключ одинаковых прогнозов из разных фрагментов: тикер, направление и цель.
+func chunkPredictionKey(prediction FinancialPrediction) string {
+	direction := strings.ToLower(strings.TrimSpace(prediction.Direction))
+	return NormalizeTicker(prediction.Ticker) + "|" + direction + "|" + strings.TrimSpace(prediction.TargetPrice.String())
+}
@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSplitMessage проверяет разбиение длинных сообщений по абзацам и тикерам
+func TestSplitMessage(t *testing.T) {
+	t.Run("Короткое сообщение не делится", func(t *testing.T) {
+		assert.Equal(t, []string{"#SBER лонг"}, SplitMessage("#SBER лонг", 100))
+	})
+
+	t.Run("По абзацам", func(t *testing.T) {
+		message := "#SBER лонг от 250, цель 290\n\n#GAZP шорт от 130, стоп 135\n \n#LKOH держим, цель 7500"
+
+		chunks := SplitMessage(message, 25)
+
+		assert.Equal(t, []string{"#SBER лонг от 250, цель 290\n\n#GAZP шорт от 130, стоп 135", "#LKOH держим, цель 7500"}, chunks)
+	})
+
+	t.Run("По тикерам внутри строки", func(t *testing.T) {
+		message := "Обзор недели: #SBER лонг от 250, цель 290; #GAZP шорт от 130, стоп 135"
+
+		chunks := SplitMessage(message, 12)
+
+		assert.Equal(t, []string{"Обзор недели:", "#SBER лонг от 250, цель 290;", "#GAZP шорт от 130, стоп 135"}, chunks)
+	})
+}
+
+// TestChunkedExtraction проверяет повторный анализ по частям, если ответ модели обрезан по num_predict
+func TestChunkedExtraction(t *testing.T) {
+	message := "#SBER лонг от 250, цель 290\n\n#GAZP шорт от 130, стоп 135"
+	calls := 0
+	client := &OllamaClient{
+		maxTokens: 64,
+		sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+			calls++
+			response := OllamaGenerateResponse{Done: true, DoneReason: "stop"}
+			switch {
+			case strings.Contains(prompt, "#SBER") && strings.Contains(prompt, "#GAZP"):
+				response.Response = `[{"ticker": "SBER", "direction": "Лонг"}, {"ticker": "GA`
+				response.DoneReason = "length"
+			case strings.Contains(prompt, "#SBER"):
+				response.Response = `[{"ticker": "SBER", "direction": "Лонг"}]`
+			default:
+				response.Response = `[{"ticker": "GAZP", "direction": "Шорт"}, {"ticker": "sber", "direction": "Лонг"}]`
+			}
+			return json.Marshal(response)
+		},
+	}
+
+	t.Run("Обрезанный ответ без разбиения", func(t *testing.T) {
+		_, err := NewPredictionStep().Execute(context.Background(), client, message, 1)
+
+		assert.True(t, errors.Is(err, ErrTruncatedResponse))
+	})
+
+	t.Run("Повторный анализ по частям", func(t *testing.T) {
+		calls = 0
+		step := NewPredictionStep()
+		step.SetChunking(0)
+
+		predictions, err := step.Execute(context.Background(), client, message, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Len(t, predictions, 2)
+		assert.Equal(t, "SBER", predictions[0].Ticker)
+		assert.Equal(t, "GAZP", predictions[1].Ticker)
+	})
+
+	t.Run("Длинное сообщение сразу делится на фрагменты", func(t *testing.T) {
+		calls = 0
+		step := NewPredictionStep()
+		step.SetChunking(15)
+
+		predictions, err := step.Execute(context.Background(), client, message, 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Len(t, predictions, 2)
+	})
+}
+
+// TestMergePredictions проверяет объединение прогнозов из разных фрагментов сообщения
+func TestMergePredictions(t *testing.T) {
+	parse := func(content string) []FinancialPrediction {
+		var predictions []FinancialPrediction
+		assert.NoError(t, json.Unmarshal([]byte(content), &predictions))
+		return predictions
+	}
+
+	merged := mergePredictions(nil, parse(`[{"ticker": "SBER", "direction": "Лонг", "target_price": 290}]`))
+	merged = mergePredictions(merged, parse(`[
+		{"ticker": "#sber", "direction": "лонг", "target_price": 290},
+		{"ticker": "SBER", "direction": "Шорт", "target_price": 260},
+		{"ticker": "GAZP", "direction": "Шорт"}
+	]`))
+
+	// Повтор прогноза из первого фрагмента отброшен, второй прогноз по тому же тикеру сохранен
+	if assert.Len(t, merged, 3) {
+		assert.Equal(t, "Лонг", merged[0].Direction)
+		assert.Equal(t, "Шорт", merged[1].Direction)
+		assert.Equal(t, "GAZP", merged[2].Ticker)
+	}
+}
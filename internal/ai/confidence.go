@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ConfidenceWeights задает вклад составляющих в итоговую уверенность прогноза.
+// Составляющие, для которых нет данных (например, согласие без ансамбля), не учитываются, а веса остальных нормируются.
+type ConfidenceWeights struct {
+	SelfRating float64 // Самооценка модели: насколько явно автор дает рекомендацию
+	Evidence   float64 // Результат проверки цитаты и тикера EvidenceStep
+	Ticker     float64 // Качество сопоставления тикера со справочником акций
+	Agreement  float64 // Согласие участников ансамбля
+}
+
+// DefaultConfidenceWeights — веса, используемые, если в конфигурации не задано своих.
+var DefaultConfidenceWeights = ConfidenceWeights{SelfRating: 0.3, Evidence: 0.3, Ticker: 0.2, Agreement: 0.2}
+
+// TickerResolver оценивает, насколько уверенно тикер сопоставляется с известной акцией: 1 — точное совпадение, 0 — не найден.
+type TickerResolver interface {
+	ResolveTicker(ctx context.Context, ticker string) (float64, error)
+}
+
+// exactTickerRe описывает тикер в привычном формате биржи: 3-6 заглавных латинских букв.
+var exactTickerRe = regexp.MustCompile(`^[A-Z]{3,6}$`)
+
+// Самооценка модели, если она ответила словами, а не числом.
+var selfRatingWords = map[string]float64{
+	"высокая": 0.9,
+	"high":    0.9,
+	"средняя": 0.6,
+	"medium":  0.6,
+	"низкая":  0.3,
+	"low":     0.3,
+}
+
+// ConfidenceStep реализует PipelineStep, который рассчитывает итоговую уверенность каждого прогноза
+// из самооценки модели, проверки цитаты, сопоставления тикера и согласия ансамбля.
+type ConfidenceStep struct {
+	weights ConfidenceWeights
+}
+
+// NewConfidenceStep создает шаг расчета уверенности. Нулевые веса заменяются DefaultConfidenceWeights.
+func NewConfidenceStep(weights ConfidenceWeights) *ConfidenceStep {
+	if weights == (ConfidenceWeights{}) {
+		weights = DefaultConfidenceWeights
+	}
+	return &ConfidenceStep{weights: weights}
+}
+
+// Process рассчитывает уверенность прогнозов, полученных предыдущими шагами.
+// Тикер оценивается через TickerResolver клиента, если он задан, иначе — по формату.
+func (s *ConfidenceStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	for i := range state.Predictions {
+		prediction := &state.Predictions[i]
+
+		tickerScore := tickerFormatScore(prediction.Ticker)
+		if client.tickerResolver != nil {
+			score, err := client.tickerResolver.ResolveTicker(ctx, prediction.Ticker)
+			if err != nil {
+				log.Printf("Failed to resolve ticker '%s' for message %d, falling back to format check: %v", prediction.Ticker, state.MessageID, err)
+			} else {
+				tickerScore = score
+			}
+		}
+
+		prediction.Confidence = s.Score(*prediction, tickerScore)
+	}
+	return nil
+}
+
+// Score объединяет составляющие уверенности прогноза во взвешенное среднее от 0 до 1.
+func (s *ConfidenceStep) Score(prediction FinancialPrediction, tickerScore float64) float64 {
+	var total, weight float64
+	add := func(value, w float64) {
+		total += clamp01(value) * w
+		weight += w
+	}
+
+	if rating, ok := prediction.SelfRatingValue(); ok {
+		add(rating, s.weights.SelfRating)
+	}
+	if prediction.Evidence != nil {
+		switch prediction.Evidence.Status {
+		case EvidenceVerified:
+			add(1, s.weights.Evidence)
+		case EvidenceUnverified:
+			add(0.5, s.weights.Evidence)
+		default:
+			add(0, s.weights.Evidence)
+		}
+	}
+	add(tickerScore, s.weights.Ticker)
+	if prediction.EnsembleSize > 0 {
+		add(prediction.Agreement, s.weights.Agreement)
+	}
+
+	if weight == 0 {
+		return 0
+	}
+	return total / weight
+}
+
+// SelfRatingValue возвращает самооценку модели в диапазоне 0..1.
+// Проценты (например, 80) приводятся к долям, словесные оценки — по selfRatingWords.
+func (p FinancialPrediction) SelfRatingValue() (float64, bool) {
+	if p.SelfRating.IsNull {
+		return 0, false
+	}
+	if p.SelfRating.IsString {
+		value, ok := selfRatingWords[strings.ToLower(strings.TrimSpace(p.SelfRating.StringValue))]
+		if ok {
+			return value, true
+		}
+		low, high, ok := p.SelfRating.Range()
+		if !ok {
+			return 0, false
+		}
+		return normalizeRating((low + high) / 2), true
+	}
+	// Нулевое значение без признака IsNull означает, что модель не вернула поле
+	if p.SelfRating.FloatValue == 0 {
+		return 0, false
+	}
+	return normalizeRating(p.SelfRating.FloatValue), true
+}
+
+// tickerFormatScore оценивает тикер по формату, когда справочник акций недоступен.
+func tickerFormatScore(ticker string) float64 {
+	normalized := NormalizeTicker(ticker)
+	if exactTickerRe.MatchString(normalized) {
+		return 0.7
+	}
+	return 0.3
+}
+
+func normalizeRating(value float64) float64 {
+	if value > 1 {
+		value /= 100
+	}
+	return clamp01(value)
+}
+
+func clamp01(value float64) float64 {
+	if value < 0 {
+		return 0
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver оценивает тикеры по заранее заданной таблице.
+type fakeResolver struct {
+	scores map[string]float64
+	err    error
+}
+
+func (r *fakeResolver) ResolveTicker(ctx context.Context, ticker string) (float64, error) {
+	return r.scores[ticker], r.err
+}
+
+// TestConfidenceStep проверяет расчет итоговой уверенности прогноза
+func TestConfidenceStep(t *testing.T) {
+	step := NewConfidenceStep(ConfidenceWeights{})
+
+	t.Run("Самооценка модели", func(t *testing.T) {
+		var prediction FinancialPrediction
+		assert.NoError(t, json.Unmarshal([]byte(`{"ticker": "SBER", "confidence": 80}`), &prediction))
+		rating, ok := prediction.SelfRatingValue()
+		assert.True(t, ok)
+		assert.InDelta(t, 0.8, rating, 1e-9)
+
+		assert.NoError(t, json.Unmarshal([]byte(`{"ticker": "SBER", "confidence": "низкая"}`), &prediction))
+		rating, ok = prediction.SelfRatingValue()
+		assert.True(t, ok)
+		assert.InDelta(t, 0.3, rating, 1e-9)
+
+		_, ok = FinancialPrediction{Ticker: "SBER"}.SelfRatingValue()
+		assert.False(t, ok)
+	})
+
+	t.Run("Явный сигнал увереннее предположения", func(t *testing.T) {
+		explicit := FinancialPrediction{
+			Ticker:     "SBER",
+			SelfRating: FlexibleStringOrNumber{FloatValue: 0.9},
+			Evidence:   &EvidenceCheck{Status: EvidenceVerified},
+		}
+		vague := FinancialPrediction{
+			Ticker:     "SBER",
+			SelfRating: FlexibleStringOrNumber{FloatValue: 0.3},
+			Evidence:   &EvidenceCheck{Status: EvidenceUnverified},
+		}
+
+		// Без ансамбля веса самооценки, цитаты и тикера нормируются: (0.3*0.9 + 0.3*1 + 0.2*1) / 0.8
+		assert.InDelta(t, 0.9625, step.Score(explicit, 1), 1e-9)
+		assert.Less(t, step.Score(vague, 1), step.Score(explicit, 1))
+
+		explicit.EnsembleSize, explicit.Agreement = 3, 1.0/3.0
+		assert.Less(t, step.Score(explicit, 1), 0.9625)
+	})
+
+	t.Run("Сопоставление тикера через справочник", func(t *testing.T) {
+		client := &OllamaClient{tickerResolver: &fakeResolver{scores: map[string]float64{"SBER": 1}}}
+		state := &PipelineState{Predictions: []FinancialPrediction{{Ticker: "SBER"}, {Ticker: "XXXX"}}}
+
+		assert.NoError(t, step.Process(context.Background(), client, state))
+		assert.Equal(t, 1.0, state.Predictions[0].Confidence)
+		assert.Equal(t, 0.0, state.Predictions[1].Confidence)
+
+		// При ошибке справочника тикер оценивается по формату
+		client.tickerResolver = &fakeResolver{err: errors.New("connection refused")}
+		assert.NoError(t, step.Process(context.Background(), client, state))
+		assert.InDelta(t, 0.7, state.Predictions[0].Confidence, 1e-9)
+	})
+}
@@ -0,0 +1,246 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SkipReasonDuplicate — сообщение повторяет ранее проанализированное (репост, пересылка).
+const SkipReasonDuplicate = "duplicate"
+
+// OllamaEmbedRequest - структура для запроса к Ollama API /api/embed
+type OllamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// OllamaEmbedResponse - структура для ответа от Ollama API /api/embed
+type OllamaEmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float64 `json:"embeddings"`
+}
+
+// Embed возвращает векторы текстов, рассчитанные моделью эмбеддингов model.
+func (c *OllamaClient) Embed(ctx context.Context, model string, inputs []string) ([][]float64, error) {
+	res, err := c.embedRequestFunc(ctx, model, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send ollama embed request: %w", err)
+	}
+
+	var embedResponse OllamaEmbedResponse
+	if err := json.Unmarshal(res, &embedResponse); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Ollama embed response JSON: %v", ErrInvalidResponse, err)
+	}
+	if len(embedResponse.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("%w: ollama returned %d embeddings for %d inputs", ErrInvalidResponse, len(embedResponse.Embeddings), len(inputs))
+	}
+	return embedResponse.Embeddings, nil
+}
+
+// defaultSendEmbedRequest отправляет запрос к Ollama API /api/embed и возвращает байты ответа.
+func (c *OllamaClient) defaultSendEmbedRequest(ctx context.Context, model string, inputs []string) ([]byte, error) {
+	jsonData, err := json.Marshal(OllamaEmbedRequest{Model: model, Input: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ollama embed request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama embed request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ollama embed response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama embed api returned non-200 status: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return bodyBytes, nil
+}
+
+// DuplicateMatch указывает на исходное сообщение, которое повторяет анализируемое.
+type DuplicateMatch struct {
+	Channel    string
+	MessageID  int64
+	Similarity float64
+}
+
+// EmbeddingEntry — вектор сообщения в индексе. SentAt — время отправки сообщения, нулевое, если оно неизвестно.
+// DuplicateOf заполнен, если сообщение признано повтором.
+type EmbeddingEntry struct {
+	Channel     string
+	MessageID   int64
+	SentAt      time.Time
+	Vector      []float64
+	DuplicateOf *DuplicateMatch
+}
+
+// EmbeddingIndex хранит векторы ранее обработанных сообщений и ищет среди них ближайшее, отправленное раньше before,
+// но не раньше чем за window до него. Нулевой before означает, что время сообщения неизвестно, и ищутся все
+// сообщения; window <= 0 не ограничивает глубину поиска. Prune удаляет векторы сообщений, отправленных раньше
+// olderThan, чтобы индекс долго работающего процесса не рос без ограничений.
+type EmbeddingIndex interface {
+	Nearest(ctx context.Context, vector []float64, before time.Time, window time.Duration) (DuplicateMatch, bool, error)
+	Add(ctx context.Context, entry EmbeddingEntry) error
+	Prune(olderThan time.Time) int
+}
+
+// MemoryEmbeddingIndex — EmbeddingIndex в памяти с линейным поиском по косинусной близости.
+// Повторы не становятся оригиналами, а исходным сообщением может быть только отправленное раньше повтора, даже если
+// повтор был проанализирован первым.
+type MemoryEmbeddingIndex struct {
+	mu      sync.RWMutex
+	entries []EmbeddingEntry
+}
+
+// NewMemoryEmbeddingIndex создает индекс, заполненный ранее сохраненными векторами.
+func NewMemoryEmbeddingIndex(entries []EmbeddingEntry) *MemoryEmbeddingIndex {
+	index := &MemoryEmbeddingIndex{}
+	for _, entry := range entries {
+		if entry.DuplicateOf == nil {
+			index.entries = append(index.entries, entry)
+		}
+	}
+	return index
+}
+
+// Nearest возвращает самое похожее исходное сообщение из индекса, отправленное раньше before, но не раньше
+// before - window. Сообщения с неизвестным временем отправки при заданном before не рассматриваются.
+func (i *MemoryEmbeddingIndex) Nearest(ctx context.Context, vector []float64, before time.Time, window time.Duration) (DuplicateMatch, bool, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var best DuplicateMatch
+	found := false
+	for _, entry := range i.entries {
+		if !before.IsZero() {
+			if entry.SentAt.IsZero() || !entry.SentAt.Before(before) {
+				continue
+			}
+			if window > 0 && entry.SentAt.Before(before.Add(-window)) {
+				continue
+			}
+		}
+		similarity := CosineSimilarity(vector, entry.Vector)
+		if !found || similarity > best.Similarity {
+			best = DuplicateMatch{Channel: entry.Channel, MessageID: entry.MessageID, Similarity: similarity}
+			found = true
+		}
+	}
+	return best, found, nil
+}
+
+// Add добавляет вектор в индекс. Повторы в индекс не попадают.
+func (i *MemoryEmbeddingIndex) Add(ctx context.Context, entry EmbeddingEntry) error {
+	if entry.DuplicateOf != nil {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.entries = append(i.entries, entry)
+	return nil
+}
+
+// Prune удаляет из индекса векторы сообщений, отправленных раньше olderThan, и возвращает их число.
+// Векторы с неизвестным временем отправки остаются.
+func (i *MemoryEmbeddingIndex) Prune(olderThan time.Time) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	kept := i.entries[:0]
+	for _, entry := range i.entries {
+		if entry.SentAt.IsZero() || !entry.SentAt.Before(olderThan) {
+			kept = append(kept, entry)
+		}
+	}
+	pruned := len(i.entries) - len(kept)
+	clear(i.entries[len(kept):])
+	i.entries = kept
+	return pruned
+}
+
+// CosineSimilarity рассчитывает косинусную близость двух векторов; для векторов разной длины возвращает 0.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// WithSentAt передает время отправки сообщения: DuplicateStep ищет исходное сообщение только среди отправленных раньше.
+func WithSentAt(sentAt time.Time) AnalyzeOption {
+	return func(state *PipelineState) {
+		state.SentAt = sentAt
+	}
+}
+
+// DuplicateStep реализует PipelineStep, который находит почти дословные повторы ранее обработанных сообщений
+// по эмбеддингам. Повтор пропускается и связывается с исходным сообщением, которому засчитывается сигнал.
+type DuplicateStep struct {
+	model     string
+	threshold float64
+	window    time.Duration
+}
+
+// NewDuplicateStep создает шаг поиска повторов. Сообщения с близостью не ниже threshold считаются повторами,
+// исходное сообщение ищется среди отправленных не раньше чем за window до повтора.
+func NewDuplicateStep(model string, threshold float64, window time.Duration) *DuplicateStep {
+	return &DuplicateStep{model: model, threshold: threshold, window: window}
+}
+
+// Process рассчитывает вектор сообщения, ищет исходное сообщение в EmbeddingIndex клиента и добавляет в него вектор.
+// Без индекса шаг ничего не делает. Ошибки эмбеддингов не прерывают анализ: сообщение обрабатывается как оригинал.
+func (s *DuplicateStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	index := client.embeddingIndex
+	if index == nil {
+		return nil
+	}
+
+	vectors, err := client.Embed(ctx, s.model, []string{state.Message})
+	if err != nil {
+		log.Printf("Failed to embed message %d, skipping duplicate check: %v", state.MessageID, err)
+		return nil
+	}
+	entry := EmbeddingEntry{Channel: state.Channel, MessageID: state.MessageID, SentAt: state.SentAt, Vector: vectors[0]}
+
+	match, found, err := index.Nearest(ctx, entry.Vector, state.SentAt, s.window)
+	if err != nil {
+		log.Printf("Failed to search embedding index for message %d: %v", state.MessageID, err)
+	} else if found && match.Similarity >= s.threshold && !(match.Channel == state.Channel && match.MessageID == state.MessageID) {
+		entry.DuplicateOf = &match
+		state.DuplicateOf = &match
+		state.Skip(SkipReasonDuplicate)
+		log.Printf("Message %d duplicates message %d from channel %s (similarity %.3f)", state.MessageID, match.MessageID, match.Channel, match.Similarity)
+	}
+
+	if err := index.Add(ctx, entry); err != nil {
+		return fmt.Errorf("failed to store message embedding: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDuplicateStep проверяет поиск повторов сообщений по эмбеддингам
+func TestDuplicateStep(t *testing.T) {
+	vectors := map[string][]float64{
+		"SBER лонг от 250":         {1, 0, 0},
+		"Репост: SBER лонг от 250": {0.99, 0.05, 0},
+		"Обзор рынка на неделю":    {0, 1, 0},
+	}
+	newClient := func(index EmbeddingIndex) *OllamaClient {
+		client := &OllamaClient{
+			embedRequestFunc: func(ctx context.Context, model string, inputs []string) ([]byte, error) {
+				vector, ok := vectors[inputs[0]]
+				if !ok {
+					return nil, errors.New("ollama недоступна")
+				}
+				return json.Marshal(OllamaEmbedResponse{Model: model, Embeddings: [][]float64{vector}})
+			},
+		}
+		client.SetEmbeddingIndex(index)
+		return client
+	}
+	step := NewDuplicateStep("nomic-embed-text", 0.95, 72*time.Hour)
+	process := func(client *OllamaClient, message, channel string, messageID int64) *PipelineState {
+		state := &PipelineState{MessageID: messageID, Channel: channel, Message: message}
+		assert.NoError(t, step.Process(context.Background(), client, state))
+		return state
+	}
+
+	t.Run("Повтор связывается с исходным сообщением", func(t *testing.T) {
+		index := NewMemoryEmbeddingIndex(nil)
+		client := newClient(index)
+
+		original := process(client, "SBER лонг от 250", "100", 1)
+		assert.False(t, original.Skipped)
+
+		repost := process(client, "Репост: SBER лонг от 250", "200", 7)
+		assert.True(t, repost.Skipped)
+		assert.Equal(t, SkipReasonDuplicate, repost.SkipReason)
+		assert.Equal(t, "100", repost.DuplicateOf.Channel)
+		assert.Equal(t, int64(1), repost.DuplicateOf.MessageID)
+
+		other := process(client, "Обзор рынка на неделю", "200", 8)
+		assert.False(t, other.Skipped)
+		assert.Nil(t, other.DuplicateOf)
+
+		// Повторная обработка того же сообщения не считается повтором
+		again := process(client, "SBER лонг от 250", "100", 1)
+		assert.False(t, again.Skipped)
+	})
+
+	t.Run("Исходным считается только более раннее сообщение", func(t *testing.T) {
+		index := NewMemoryEmbeddingIndex(nil)
+		client := newClient(index)
+		sentAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		processAt := func(message, channel string, messageID int64, sentAt time.Time) *PipelineState {
+			state := &PipelineState{MessageID: messageID, Channel: channel, Message: message, SentAt: sentAt}
+			assert.NoError(t, step.Process(context.Background(), client, state))
+			return state
+		}
+
+		// Репост проанализирован раньше оригинала, но отправлен позже: оригинал не становится его повтором
+		repost := processAt("Репост: SBER лонг от 250", "200", 7, sentAt.Add(time.Hour))
+		assert.False(t, repost.Skipped)
+		original := processAt("SBER лонг от 250", "100", 1, sentAt)
+		assert.False(t, original.Skipped)
+
+		// Более поздний репост связывается с самым похожим из более ранних сообщений
+		later := processAt("Репост: SBER лонг от 250", "300", 9, sentAt.Add(2*time.Hour))
+		if assert.True(t, later.Skipped) {
+			assert.Equal(t, "200", later.DuplicateOf.Channel)
+		}
+
+		// Сообщения с неизвестным временем отправки исходными не считаются
+		unknown := NewMemoryEmbeddingIndex([]EmbeddingEntry{{Channel: "100", MessageID: 1, Vector: []float64{1, 0, 0}}})
+		_, found, err := unknown.Nearest(context.Background(), []float64{1, 0, 0}, sentAt, 0)
+		assert.NoError(t, err)
+		assert.False(t, found)
+		_, found, _ = unknown.Nearest(context.Background(), []float64{1, 0, 0}, time.Time{}, 0)
+		assert.True(t, found)
+	})
+
+	t.Run("Исходное сообщение ищется только в окне", func(t *testing.T) {
+		sentAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+		index := NewMemoryEmbeddingIndex([]EmbeddingEntry{
+			{Channel: "100", MessageID: 1, SentAt: sentAt.Add(-30 * 24 * time.Hour), Vector: []float64{1, 0, 0}},
+			{Channel: "100", MessageID: 2, SentAt: sentAt.Add(-time.Hour), Vector: []float64{0, 1, 0}},
+		})
+		client := newClient(index)
+
+		// Такой же шаблонный сигнал месяц назад — не исходное сообщение: он вне окна в 72 часа
+		state := &PipelineState{MessageID: 3, Channel: "100", Message: "SBER лонг от 250", SentAt: sentAt}
+		assert.NoError(t, step.Process(context.Background(), client, state))
+		assert.False(t, state.Skipped)
+
+		match, found, err := index.Nearest(context.Background(), []float64{1, 0, 0}, sentAt, 72*time.Hour)
+		assert.NoError(t, err)
+		if assert.True(t, found) {
+			assert.Equal(t, int64(2), match.MessageID)
+		}
+
+		// Устаревшие векторы удаляются из индекса, векторы с неизвестным временем остаются
+		assert.NoError(t, index.Add(context.Background(), EmbeddingEntry{Channel: "100", MessageID: 4, Vector: []float64{0, 0, 1}}))
+		assert.Equal(t, 1, index.Prune(sentAt.Add(-72*time.Hour)))
+		assert.Equal(t, 2, index.Prune(sentAt.Add(time.Minute)))
+		_, found, _ = index.Nearest(context.Background(), []float64{1, 0, 0}, time.Time{}, 0)
+		assert.True(t, found)
+		_, found, _ = index.Nearest(context.Background(), []float64{1, 0, 0}, sentAt.Add(time.Hour), 0)
+		assert.False(t, found)
+	})
+
+	t.Run("Ошибка эмбеддингов не прерывает анализ", func(t *testing.T) {
+		client := newClient(NewMemoryEmbeddingIndex(nil))
+
+		analysis := process(client, "неизвестный текст", "100", 2)
+
+		assert.False(t, analysis.Skipped)
+	})
+
+	t.Run("Без индекса шаг не выполняется", func(t *testing.T) {
+		client := newClient(nil)
+
+		analysis := process(client, "неизвестный текст", "100", 3)
+
+		assert.False(t, analysis.Skipped)
+	})
+
+	t.Run("Косинусная близость", func(t *testing.T) {
+		assert.InDelta(t, 1.0, CosineSimilarity([]float64{1, 2}, []float64{2, 4}), 1e-9)
+		assert.Equal(t, 0.0, CosineSimilarity([]float64{1, 2}, []float64{1}))
+	})
+}
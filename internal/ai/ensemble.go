@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// EnsembleMember — один участник ансамбля: модель и температура выборки.
+// Пустая модель и нулевая температура означают значения основного клиента.
+type EnsembleMember struct {
+	Model       string
+	Temperature float64
+}
+
+// EnsembleStep реализует PipelineStep, который извлекает прогнозы несколькими моделями
+// или несколькими выборками одной модели и объединяет их голосованием большинством.
+type EnsembleStep struct {
+	members    []EnsembleMember
+	prediction *PredictionStep
+}
+
+// NewEnsembleStep создает шаг ансамбля. Если models не пуст, каждая модель опрашивается один раз;
+// иначе основная модель опрашивается samples раз с температурой temperature (self-consistency).
+func NewEnsembleStep(prediction *PredictionStep, models []string, samples int, temperature float64) *EnsembleStep {
+	var members []EnsembleMember
+	if len(models) > 0 {
+		for _, model := range models {
+			members = append(members, EnsembleMember{Model: model, Temperature: temperature})
+		}
+	} else {
+		for i := 0; i < samples; i++ {
+			members = append(members, EnsembleMember{Temperature: temperature})
+		}
+	}
+	return &EnsembleStep{members: members, prediction: prediction}
+}
+
+// Members возвращает участников ансамбля.
+func (s *EnsembleStep) Members() []EnsembleMember {
+	return s.members
+}
+
+// Process опрашивает участников ансамбля по очереди и добавляет объединенные прогнозы к состоянию.
+// Ошибки отдельных участников пропускаются; шаг завершается ошибкой, только если не ответил ни один участник.
+func (s *EnsembleStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	if state.FullyExtracted {
+		return nil
+	}
+
+	var votes [][]FinancialPrediction
+	var lastErr error
+	for idx, member := range s.members {
+		memberClient := client.withSampling(member.Model, member.Temperature)
+		predictions, usage, err := s.prediction.extract(ctx, memberClient, state.Message, state.MessageID, state.ReplyContext)
+		state.Usage.Add(usage)
+		if err != nil {
+			log.Printf("Ensemble member %d (%s) failed for message %d: %v", idx+1, memberClient.Model(), state.MessageID, err)
+			lastErr = err
+			continue
+		}
+		votes = append(votes, predictions)
+	}
+
+	if len(votes) == 0 {
+		return fmt.Errorf("all %d ensemble members failed: %w", len(s.members), lastErr)
+	}
+
+	state.AddModelPredictions(MergeVotes(votes))
+	return nil
+}
+
+// MergeVotes объединяет ответы участников ансамбля по тикеру. Каждое поле прогноза определяется
+// большинством голосов участников, назвавших тикер; при равенстве побеждает значение, встретившееся раньше.
+// Agreement — доля всех ответивших участников, чьи направление и рекомендация совпали с итоговыми.
+func MergeVotes(votes [][]FinancialPrediction) []FinancialPrediction {
+	var order []string
+	byTicker := make(map[string][]FinancialPrediction)
+	for _, predictions := range votes {
+		seen := make(map[string]bool)
+		for _, prediction := range predictions {
+			key := NormalizeTicker(prediction.Ticker)
+			// Один участник — один голос по тикеру
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if _, ok := byTicker[key]; !ok {
+				order = append(order, key)
+			}
+			byTicker[key] = append(byTicker[key], prediction)
+		}
+	}
+
+	merged := make([]FinancialPrediction, 0, len(order))
+	for _, key := range order {
+		candidates := byTicker[key]
+
+		direction := candidates[vote(candidates, func(p FinancialPrediction) string { return p.Direction })].Direction
+		recommendation := candidates[vote(candidates, func(p FinancialPrediction) string { return p.Recommendation })].Recommendation
+
+		// За основу берем первый ответ, согласный с итоговыми направлением и рекомендацией, — из него берется цитата
+		agreeing := 0
+		base := -1
+		for idx, candidate := range candidates {
+			if candidate.Direction == direction && candidate.Recommendation == recommendation {
+				agreeing++
+				if base < 0 {
+					base = idx
+				}
+			}
+		}
+		if base < 0 {
+			base = 0
+		}
+
+		result := candidates[base]
+		result.Ticker = key
+		result.Direction = direction
+		result.Recommendation = recommendation
+		result.PredictionType = candidates[vote(candidates, func(p FinancialPrediction) string { return p.PredictionType })].PredictionType
+		result.Period = candidates[vote(candidates, func(p FinancialPrediction) string { return p.Period })].Period
+		result.TargetPrice = candidates[vote(candidates, func(p FinancialPrediction) string { return p.TargetPrice.String() })].TargetPrice
+		result.TargetChangePercent = candidates[vote(candidates, func(p FinancialPrediction) string { return p.TargetChangePercent.String() })].TargetChangePercent
+		result.EntryPrice = candidates[vote(candidates, func(p FinancialPrediction) string { return p.EntryPrice.String() })].EntryPrice
+		result.StopLoss = candidates[vote(candidates, func(p FinancialPrediction) string { return p.StopLoss.String() })].StopLoss
+		result.TakeProfitLevels = candidates[vote(candidates, func(p FinancialPrediction) string { return p.TakeProfitLevels.String() })].TakeProfitLevels
+		result.SelfRating = candidates[vote(candidates, func(p FinancialPrediction) string { return p.SelfRating.String() })].SelfRating
+		result.EnsembleSize = len(votes)
+		result.Agreement = float64(agreeing) / float64(len(votes))
+
+		merged = append(merged, result)
+	}
+	return merged
+}
+
+// vote возвращает индекс первого кандидата с самым частым непустым значением поля.
+// Если значение не указано ни одним кандидатом, возвращается 0.
+func vote(candidates []FinancialPrediction, value func(FinancialPrediction) string) int {
+	counts := make(map[string]int)
+	for _, candidate := range candidates {
+		if v := strings.TrimSpace(value(candidate)); v != "" {
+			counts[v]++
+		}
+	}
+
+	best, bestCount := 0, 0
+	for idx, candidate := range candidates {
+		if count := counts[strings.TrimSpace(value(candidate))]; count > bestCount {
+			best, bestCount = idx, count
+		}
+	}
+	return best
+}
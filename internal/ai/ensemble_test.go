@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEnsembleStep проверяет объединение ответов участников ансамбля голосованием
+func TestEnsembleStep(t *testing.T) {
+	t.Run("Голосование по полям и согласие", func(t *testing.T) {
+		votes := [][]FinancialPrediction{
+			{{Ticker: "SBER", Direction: "Лонг", Recommendation: "Покупать", Period: "Краткосрочный", JustificationText: "первый"}},
+			{{Ticker: "#sber", Direction: "Шорт", Recommendation: "Продавать", Period: "Краткосрочный"}, {Ticker: "GAZP", Direction: "Лонг", Recommendation: "Покупать"}},
+			{{Ticker: "SBER", Direction: "Лонг", Recommendation: "Покупать", Period: "Среднесрочный", JustificationText: "третий"}},
+		}
+
+		merged := MergeVotes(votes)
+
+		assert.Len(t, merged, 2)
+		assert.Equal(t, "SBER", merged[0].Ticker)
+		assert.Equal(t, "Лонг", merged[0].Direction)
+		assert.Equal(t, "Покупать", merged[0].Recommendation)
+		assert.Equal(t, "Краткосрочный", merged[0].Period)
+		assert.Equal(t, "первый", merged[0].JustificationText)
+		assert.Equal(t, 3, merged[0].EnsembleSize)
+		assert.InDelta(t, 2.0/3.0, merged[0].Agreement, 1e-9)
+
+		// Тикер, найденный только одним участником, получает низкое согласие
+		assert.Equal(t, "GAZP", merged[1].Ticker)
+		assert.InDelta(t, 1.0/3.0, merged[1].Agreement, 1e-9)
+	})
+
+	t.Run("Ошибки отдельных участников пропускаются", func(t *testing.T) {
+		responses := []string{
+			`[{"ticker": "SBER", "direction": "Лонг", "recommendation": "Покупать"}]`,
+			`не JSON`,
+			`[{"ticker": "SBER", "direction": "Лонг", "recommendation": "Покупать"}]`,
+		}
+		calls := 0
+		client := &OllamaClient{
+			sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+				response := responses[calls%len(responses)]
+				calls++
+				return json.Marshal(OllamaGenerateResponse{Response: response, Done: true})
+			},
+			steps: []PipelineStep{NewEnsembleStep(NewPredictionStep(), nil, 3, 0.7)},
+		}
+
+		analysis, err := client.AnalyzeMessage(context.Background(), "SBER лонг", "1", 5)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 3, analysis.Usage.Requests)
+		assert.Len(t, analysis.Predictions, 1)
+		assert.Equal(t, int64(5), analysis.Predictions[0].MessageID)
+		assert.Equal(t, 2, analysis.Predictions[0].EnsembleSize)
+		assert.Equal(t, 1.0, analysis.Predictions[0].Agreement)
+	})
+
+	t.Run("Ошибка, если не ответил ни один участник", func(t *testing.T) {
+		client := &OllamaClient{
+			sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+				return json.Marshal(OllamaGenerateResponse{Response: "не JSON", Done: true})
+			},
+			steps: []PipelineStep{NewEnsembleStep(NewPredictionStep(), []string{"gemma3:1b", "qwen2.5:7b"}, 0, 0)},
+		}
+
+		_, err := client.AnalyzeMessage(context.Background(), "SBER лонг", "1", 5)
+
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInvalidResponse))
+	})
+}
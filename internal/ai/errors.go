@@ -0,0 +1,18 @@
+package ai
+
+import "errors"
+
+var (
+	// ErrInvalidResponse означает, что ответ модели не удалось разобрать как JSON с прогнозами.
+	ErrInvalidResponse = errors.New("invalid model response")
+	// ErrNoPredictions означает, что модель или конвейер не вернули ни одного прогноза.
+	ErrNoPredictions = errors.New("returned no predictions")
+	// ErrTruncatedResponse означает, что модель исчерпала num_predict и ответ обрезан.
+	ErrTruncatedResponse = errors.New("model response truncated")
+	// ErrRequestFailed означает, что запрос к модели не выполнен: сетевая ошибка или ответ Ollama не 200.
+	ErrRequestFailed = errors.New("model request failed")
+)
+
+// SkipReasonNoPredictions — причина пропуска сообщения, в котором модель не нашла ни одного прогноза: такое сообщение
+// записывается в message_skips, а не в analysis_failures.
+const SkipReasonNoPredictions = "no_predictions"
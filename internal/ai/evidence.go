@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"context"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Статусы проверки доказательной базы прогноза.
+const (
+	EvidenceVerified   = "verified"   // Цитата и тикер найдены в сообщении
+	EvidenceUnverified = "unverified" // Найдено только одно из двух: прогноз понижается до raw_predictions
+	EvidenceRejected   = "rejected"   // Ни цитаты, ни тикера в сообщении нет
+)
+
+// EvidenceCheck содержит результат сверки прогноза с исходным текстом сообщения.
+// Смещения SpanStart/SpanEnd указаны в символах (рунах) исходного сообщения; -1, если цитата не найдена.
+type EvidenceCheck struct {
+	Status      string  `json:"status"`
+	QuoteScore  float64 `json:"quote_score"`
+	TickerFound bool    `json:"ticker_found"`
+	SpanStart   int     `json:"span_start"`
+	SpanEnd     int     `json:"span_end"`
+}
+
+// EvidenceStep реализует PipelineStep для защиты от галлюцинаций:
+// проверяет, что justification_text и тикер действительно присутствуют в сообщении.
+type EvidenceStep struct {
+	minQuoteScore    float64
+	rejectUnverified bool
+}
+
+// NewEvidenceStep создает шаг проверки. minQuoteScore — минимальная доля слов цитаты, найденных в сообщении;
+// при rejectUnverified прогнозы без подтверждения отбрасываются, иначе только помечаются.
+func NewEvidenceStep(minQuoteScore float64, rejectUnverified bool) *EvidenceStep {
+	return &EvidenceStep{
+		minQuoteScore:    minQuoteScore,
+		rejectUnverified: rejectUnverified,
+	}
+}
+
+// Process проверяет прогнозы, полученные предыдущими шагами, и отбрасывает отклоненные.
+func (s *EvidenceStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	verified := state.Predictions[:0]
+	for _, prediction := range state.Predictions {
+		check := s.Verify(state.Message, prediction, state.ReplyContext...)
+		prediction.Evidence = &check
+
+		if check.Status == EvidenceRejected || (s.rejectUnverified && check.Status == EvidenceUnverified) {
+			log.Printf("Prediction for message %d with ticker '%s' rejected: evidence %s (quote score %.2f, ticker found %t)",
+				state.MessageID, prediction.Ticker, check.Status, check.QuoteScore, check.TickerFound)
+			continue
+		}
+		verified = append(verified, prediction)
+	}
+	state.Predictions = verified
+	return nil
+}
+
+// Verify сверяет цитату и тикер прогноза с текстом сообщения. Тикер ответа может быть взят из контекста replyContext,
+// цитата же всегда должна находиться в самом сообщении.
+func (s *EvidenceStep) Verify(message string, prediction FinancialPrediction, replyContext ...ContextMessage) EvidenceCheck {
+	check := EvidenceCheck{SpanStart: -1, SpanEnd: -1}
+
+	check.QuoteScore, check.SpanStart, check.SpanEnd = matchQuote(message, prediction.JustificationText)
+	check.TickerFound = containsTicker(message, prediction.Ticker) || contextContainsTicker(replyContext, prediction.Ticker)
+
+	quoteFound := check.QuoteScore >= s.minQuoteScore
+	switch {
+	case quoteFound && check.TickerFound:
+		check.Status = EvidenceVerified
+	case quoteFound || check.TickerFound:
+		check.Status = EvidenceUnverified
+	default:
+		check.Status = EvidenceRejected
+	}
+	if !quoteFound {
+		check.SpanStart, check.SpanEnd = -1, -1
+	}
+
+	return check
+}
+
+// word — слово текста с его позицией в рунах.
+type word struct {
+	text  string
+	start int
+	end   int
+}
+
+// splitWords разбивает текст на нормализованные слова (нижний регистр, ё→е) с позициями в рунах.
+func splitWords(text string) []word {
+	var words []word
+	var current []rune
+	start := 0
+	position := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, word{text: string(current), start: start, end: position})
+			current = current[:0]
+		}
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if len(current) == 0 {
+				start = position
+			}
+			r = unicode.ToLower(r)
+			if r == 'ё' {
+				r = 'е'
+			}
+			current = append(current, r)
+		} else {
+			flush()
+		}
+		position++
+	}
+	flush()
+
+	return words
+}
+
+// stem грубо отбрасывает окончание слова, чтобы "продолжении" и "продолжение" совпадали.
+func stem(w string) string {
+	runes := []rune(w)
+	if len(runes) > 5 {
+		return string(runes[:5])
+	}
+	return w
+}
+
+// matchQuote ищет в сообщении окно слов, лучше всего покрывающее цитату.
+// Возвращает долю слов цитаты, найденных в окне, и границы совпавшего фрагмента в рунах.
+func matchQuote(message, quote string) (score float64, spanStart, spanEnd int) {
+	quoteWords := splitWords(quote)
+	messageWords := splitWords(message)
+	if len(quoteWords) == 0 || len(messageWords) == 0 {
+		return 0, -1, -1
+	}
+
+	window := len(quoteWords)
+	if window > len(messageWords) {
+		window = len(messageWords)
+	}
+
+	spanStart, spanEnd = -1, -1
+	bestMatched := 0
+	for i := 0; i+window <= len(messageWords); i++ {
+		available := make(map[string]int, window)
+		for _, w := range messageWords[i : i+window] {
+			available[stem(w.text)]++
+		}
+
+		matched := 0
+		for _, w := range quoteWords {
+			if available[stem(w.text)] > 0 {
+				available[stem(w.text)]--
+				matched++
+			}
+		}
+
+		if matched > bestMatched {
+			bestMatched = matched
+			spanStart, spanEnd = matchedSpan(messageWords[i:i+window], quoteWords)
+		}
+	}
+
+	return float64(bestMatched) / float64(len(quoteWords)), spanStart, spanEnd
+}
+
+// matchedSpan возвращает границы от первого до последнего слова окна, встречающегося в цитате.
+func matchedSpan(windowWords, quoteWords []word) (int, int) {
+	inQuote := make(map[string]bool, len(quoteWords))
+	for _, w := range quoteWords {
+		inQuote[stem(w.text)] = true
+	}
+
+	start, end := -1, -1
+	for _, w := range windowWords {
+		if !inQuote[stem(w.text)] {
+			continue
+		}
+		if start == -1 {
+			start = w.start
+		}
+		end = w.end
+	}
+	return start, end
+}
+
+// containsTicker проверяет, что тикер упомянут в сообщении отдельным словом (в том числе как #SBER или $SBER).
+func containsTicker(message, ticker string) bool {
+	ticker = strings.ToLower(NormalizeTicker(ticker))
+	if ticker == "" {
+		return false
+	}
+	for _, w := range splitWords(message) {
+		if w.text == ticker {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEvidenceStep проверяет сверку цитаты и тикера с исходным сообщением
+func TestEvidenceStep(t *testing.T) {
+	message := "#SBER лонг. Вход 250-255, стоп 240, цели 270/290. Ожидаем продолжение роста после отчета."
+	step := NewEvidenceStep(0.6, false)
+
+	t.Run("Цитата и тикер присутствуют в сообщении", func(t *testing.T) {
+		check := step.Verify(message, FinancialPrediction{
+			Ticker:            "SBER",
+			JustificationText: "ожидаем продолжения роста после отчета",
+		})
+
+		assert.Equal(t, EvidenceVerified, check.Status)
+		assert.True(t, check.TickerFound)
+		assert.Equal(t, 1.0, check.QuoteScore)
+		assert.Equal(t, "Ожидаем продолжение роста после отчета", string([]rune(message)[check.SpanStart:check.SpanEnd]))
+	})
+
+	t.Run("Выдуманная цитата понижает прогноз", func(t *testing.T) {
+		check := step.Verify(message, FinancialPrediction{
+			Ticker:            "SBER",
+			JustificationText: "Автор сообщения уверен в развороте тренда",
+		})
+
+		assert.Equal(t, EvidenceUnverified, check.Status)
+		assert.Equal(t, -1, check.SpanStart)
+	})
+
+	t.Run("Выдуманные тикер и цитата отбрасываются", func(t *testing.T) {
+		state := &PipelineState{
+			MessageID: 1,
+			Message:   message,
+			Predictions: []FinancialPrediction{
+				{Ticker: "SBER", JustificationText: "Вход 250-255, стоп 240"},
+				{Ticker: "GAZP", JustificationText: "Газпром пробил сопротивление"},
+			},
+		}
+
+		err := step.Process(context.Background(), nil, state)
+
+		assert.NoError(t, err)
+		assert.Len(t, state.Predictions, 1)
+		assert.Equal(t, "SBER", state.Predictions[0].Ticker)
+		assert.Equal(t, EvidenceVerified, state.Predictions[0].Evidence.Status)
+	})
+}
@@ -0,0 +1,223 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FewShotExample — эталонная пара для промта: сообщение и ожидаемый JSON-ответ модели.
+type FewShotExample struct {
+	ID     string          `json:"id"`
+	Text   string          `json:"text"`
+	Answer json.RawMessage `json:"answer"`
+}
+
+// LoadFewShotExamples читает библиотеку примеров в формате JSONL: один пример на строку.
+// Пустые строки и строки, начинающиеся с "#", пропускаются.
+func LoadFewShotExamples(path string) ([]FewShotExample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open few-shot examples: %w", err)
+	}
+	defer file.Close()
+
+	var examples []FewShotExample
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var example FewShotExample
+		if err := json.Unmarshal([]byte(text), &example); err != nil {
+			return nil, fmt.Errorf("failed to parse few-shot example on line %d: %w", line, err)
+		}
+		if strings.TrimSpace(example.Text) == "" || !json.Valid(example.Answer) {
+			return nil, fmt.Errorf("few-shot example on line %d must have text and a JSON answer", line)
+		}
+		if example.ID == "" {
+			example.ID = fmt.Sprintf("line-%d", line)
+		}
+		examples = append(examples, example)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read few-shot examples: %w", err)
+	}
+
+	return examples, nil
+}
+
+// FewShotSelector подбирает для сообщения самые похожие примеры из библиотеки по TF-IDF косинусной близости
+// основ слов и укладывает их в бюджет токенов.
+type FewShotSelector struct {
+	examples       []FewShotExample
+	vectors        []map[string]float64
+	idf            map[string]float64
+	maxExamples    int
+	maxTokens      int
+	minSimilarity  float64
+	contextWindow  int // num_ctx модели; 0 — не ограничивать
+	reservedTokens int // Токены, оставляемые под ответ модели (num_predict)
+}
+
+// NewFewShotSelector создает селектор примеров. maxTokens ограничивает размер блока примеров,
+// а contextWindow и reservedTokens — весь промт вместе с ответом, чтобы не превысить num_ctx.
+func NewFewShotSelector(examples []FewShotExample, maxExamples, maxTokens int, minSimilarity float64, contextWindow, reservedTokens int) *FewShotSelector {
+	s := &FewShotSelector{
+		examples:       examples,
+		idf:            make(map[string]float64),
+		maxExamples:    maxExamples,
+		maxTokens:      maxTokens,
+		minSimilarity:  minSimilarity,
+		contextWindow:  contextWindow,
+		reservedTokens: reservedTokens,
+	}
+
+	documentFrequency := make(map[string]int)
+	terms := make([]map[string]int, len(examples))
+	for i, example := range examples {
+		terms[i] = termCounts(example.Text)
+		for term := range terms[i] {
+			documentFrequency[term]++
+		}
+	}
+	for term, df := range documentFrequency {
+		s.idf[term] = math.Log(float64(len(examples)+1)/float64(df+1)) + 1
+	}
+	for _, counts := range terms {
+		s.vectors = append(s.vectors, s.vector(counts))
+	}
+	return s
+}
+
+// Select возвращает до maxExamples самых похожих примеров, укладывающихся в бюджет budget токенов.
+// Пример с тем же текстом, что и сообщение, не выбирается, чтобы не подсказывать модели готовый ответ.
+func (s *FewShotSelector) Select(message string, budget int) []FewShotExample {
+	query := s.vector(termCounts(message))
+	normalized := normalizeText(message)
+
+	type candidate struct {
+		index int
+		score float64
+	}
+	var candidates []candidate
+	for i, vector := range s.vectors {
+		if normalizeText(s.examples[i].Text) == normalized {
+			continue
+		}
+		if score := cosine(query, vector); score >= s.minSimilarity && score > 0 {
+			candidates = append(candidates, candidate{index: i, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var selected []FewShotExample
+	for _, c := range candidates {
+		if len(selected) >= s.maxExamples {
+			break
+		}
+		cost := EstimateTokens(formatExample(s.examples[c.index]))
+		if cost > budget {
+			continue
+		}
+		budget -= cost
+		selected = append(selected, s.examples[c.index])
+	}
+	return selected
+}
+
+// Budget рассчитывает, сколько токенов можно отдать под примеры для промта без примеров basePrompt.
+func (s *FewShotSelector) Budget(basePrompt string) int {
+	budget := s.maxTokens
+	if s.contextWindow > 0 {
+		if available := s.contextWindow - s.reservedTokens - EstimateTokens(basePrompt); available < budget {
+			budget = available
+		}
+	}
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+func (s *FewShotSelector) vector(counts map[string]int) map[string]float64 {
+	vector := make(map[string]float64, len(counts))
+	for term, count := range counts {
+		idf, ok := s.idf[term]
+		if !ok {
+			// Слова, которых нет в библиотеке, не влияют на близость
+			continue
+		}
+		vector[term] = float64(count) * idf
+	}
+	return vector
+}
+
+// termCounts считает основы слов текста.
+func termCounts(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range splitWords(text) {
+		counts[stem(w.text)]++
+	}
+	return counts
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, value := range a {
+		dot += value * b[term]
+		normA += value * value
+	}
+	for _, value := range b {
+		normB += value * value
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// EstimateTokens грубо оценивает число токенов текста: около трех символов на токен для смеси кириллицы и латиницы.
+func EstimateTokens(text string) int {
+	return utf8.RuneCountInString(text)/3 + 1
+}
+
+// formatExample оформляет пример так же, как сообщение в промте извлечения.
+func formatExample(example FewShotExample) string {
+	return fmt.Sprintf("Сообщение: %s\nОтвет: %s\n", example.Text, example.Answer)
+}
+
+// insertExamples вставляет блок примеров в шаблон промта перед строкой с {{message}}.
+func insertExamples(template string, examples []FewShotExample) string {
+	if len(examples) == 0 {
+		return template
+	}
+
+	var block strings.Builder
+	block.WriteString("Примеры:\n")
+	for _, example := range examples {
+		block.WriteString(formatExample(example))
+		block.WriteString("\n")
+	}
+
+	index := strings.Index(template, messagePlaceholder)
+	if index < 0 {
+		return template + "\n" + block.String()
+	}
+	lineStart := strings.LastIndex(template[:index], "\n") + 1
+	return template[:lineStart] + block.String() + template[lineStart:]
+}
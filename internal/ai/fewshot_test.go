@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFewShotSelector проверяет подбор похожих примеров и бюджет токенов
+func TestFewShotSelector(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "examples.jsonl")
+	library := `# библиотека примеров
+{"id": "long", "text": "#SBER лонг, вход 250-255, стоп 240, цели 270/290", "answer": [{"ticker": "SBER", "direction": "Лонг"}]}
+{"id": "short", "text": "GAZP шортим от 130, стоп 135", "answer": [{"ticker": "GAZP", "direction": "Шорт"}]}
+{"id": "news", "text": "ЦБ сохранил ключевую ставку", "answer": []}
+`
+	assert.NoError(t, os.WriteFile(path, []byte(library), 0644))
+
+	examples, err := LoadFewShotExamples(path)
+	assert.NoError(t, err)
+	assert.Len(t, examples, 3)
+
+	selector := NewFewShotSelector(examples, 2, 1000, 0.05, 0, 0)
+
+	t.Run("Выбор по близости", func(t *testing.T) {
+		selected := selector.Select("#LKOH лонг, вход 7000, стоп 6800, цели 7500", 1000)
+		assert.NotEmpty(t, selected)
+		assert.Equal(t, "long", selected[0].ID)
+
+		// Пример с тем же текстом не подсказывает ответ
+		selected = selector.Select("#SBER лонг, вход 250-255, стоп 240, цели 270/290", 1000)
+		for _, example := range selected {
+			assert.NotEqual(t, "long", example.ID)
+		}
+	})
+
+	t.Run("Бюджет токенов", func(t *testing.T) {
+		assert.Empty(t, selector.Select("#LKOH лонг, вход 7000, стоп 6800", 5))
+
+		limited := NewFewShotSelector(examples, 2, 1000, 0.05, 600, 500)
+		assert.Equal(t, 100-EstimateTokens("промт"), limited.Budget("промт"))
+		assert.Equal(t, 0, limited.Budget(strings.Repeat("промт ", 200)))
+	})
+
+	t.Run("Примеры вставляются перед сообщением", func(t *testing.T) {
+		step := NewPredictionStep()
+		step.SetFewShot(selector)
+
+		prompt := step.BuildPrompt("#LKOH лонг, вход 7000, стоп 6800, цели 7500")
+
+		examplesAt := strings.Index(prompt, "Примеры:")
+		messageAt := strings.Index(prompt, "Сообщение: #LKOH")
+		assert.True(t, examplesAt > 0 && examplesAt < messageAt)
+		assert.Contains(t, prompt, `Ответ: [{"ticker": "SBER", "direction": "Лонг"}]`)
+		assert.NotContains(t, prompt, messagePlaceholder)
+	})
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// priceNumberRe находит числа в строковых ценовых значениях ("250-255", "270/290", "250,5").
+var priceNumberRe = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+
+// parsePriceNumbers извлекает все числа из строки с ценами.
+func parsePriceNumbers(s string) []float64 {
+	var numbers []float64
+	for _, match := range priceNumberRe.FindAllString(s, -1) {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(match, ",", "."), 64)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, value)
+	}
+	return numbers
+}
+
+// Range возвращает нижнюю и верхнюю границу значения.
+// Для числа границы совпадают, для строки вида "250-255" берутся минимальное и максимальное число из строки.
+func (fsn FlexibleStringOrNumber) Range() (low, high float64, ok bool) {
+	if fsn.IsNull {
+		return 0, 0, false
+	}
+	if !fsn.IsString {
+		return fsn.FloatValue, fsn.FloatValue, true
+	}
+
+	numbers := parsePriceNumbers(fsn.StringValue)
+	if len(numbers) == 0 {
+		return 0, 0, false
+	}
+
+	low, high = numbers[0], numbers[0]
+	for _, n := range numbers[1:] {
+		low = math.Min(low, n)
+		high = math.Max(high, n)
+	}
+	return low, high, true
+}
+
+// PriceLevels представляет упорядоченный список ценовых уровней (например, цели take-profit).
+// Модель может вернуть массив чисел или строк, одно число или строку вида "270/290" — все варианты приводятся к []float64
+// с сохранением исходного порядка.
+type PriceLevels []float64
+
+// UnmarshalJSON реализует интерфейс json.Unmarshaler для PriceLevels.
+func (pl *PriceLevels) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*pl = nil
+		return nil
+	}
+
+	var items []FlexibleStringOrNumber
+	if err := json.Unmarshal(data, &items); err != nil {
+		var single FlexibleStringOrNumber
+		if err := json.Unmarshal(data, &single); err != nil {
+			return fmt.Errorf("could not unmarshal price levels: %s", data)
+		}
+		items = []FlexibleStringOrNumber{single}
+	}
+
+	levels := PriceLevels{}
+	for _, item := range items {
+		if item.IsNull {
+			continue
+		}
+		if !item.IsString {
+			levels = append(levels, item.FloatValue)
+			continue
+		}
+		levels = append(levels, parsePriceNumbers(item.StringValue)...)
+	}
+
+	*pl = levels
+	return nil
+}
+
+// String возвращает уровни через "/", как их обычно пишут в сигналах.
+func (pl PriceLevels) String() string {
+	parts := make([]string, len(pl))
+	for i, level := range pl {
+		parts[i] = fmt.Sprintf("%.2f", level)
+	}
+	return strings.Join(parts, "/")
+}
+
+// RiskReward рассчитывает соотношение прибыли к риску для первой цели.
+// В качестве цены входа используется середина зоны входа. Возвращает false, если вход, стоп или цели не указаны.
+func (p FinancialPrediction) RiskReward() (float64, bool) {
+	entryLow, entryHigh, ok := p.EntryPrice.Range()
+	if !ok || len(p.TakeProfitLevels) == 0 {
+		return 0, false
+	}
+	stop, _, ok := p.StopLoss.Range()
+	if !ok {
+		return 0, false
+	}
+
+	entry := (entryLow + entryHigh) / 2
+	risk := math.Abs(entry - stop)
+	if risk == 0 {
+		return 0, false
+	}
+
+	return math.Abs(p.TakeProfitLevels[0]-entry) / risk, true
+}
@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFinancialPrediction_Levels проверяет разбор зоны входа, стоп-лосса и целей take-profit
+func TestFinancialPrediction_Levels(t *testing.T) {
+	t.Run("Зона входа строкой и цели массивом", func(t *testing.T) {
+		var prediction FinancialPrediction
+		err := json.Unmarshal([]byte(`{
+			"ticker": "SBER",
+			"entry_price": "250-255",
+			"stop_loss": 240,
+			"take_profit_levels": [270, "290"]
+		}`), &prediction)
+
+		assert.NoError(t, err)
+		low, high, ok := prediction.EntryPrice.Range()
+		assert.True(t, ok)
+		assert.Equal(t, 250.0, low)
+		assert.Equal(t, 255.0, high)
+		assert.Equal(t, PriceLevels{270, 290}, prediction.TakeProfitLevels)
+
+		rr, ok := prediction.RiskReward()
+		assert.True(t, ok)
+		assert.InDelta(t, 17.5/12.5, rr, 1e-9)
+	})
+
+	t.Run("Цели одной строкой через слеш", func(t *testing.T) {
+		var prediction FinancialPrediction
+		err := json.Unmarshal([]byte(`{"take_profit_levels": "270/290", "entry_price": "250,5"}`), &prediction)
+
+		assert.NoError(t, err)
+		assert.Equal(t, PriceLevels{270, 290}, prediction.TakeProfitLevels)
+		low, high, ok := prediction.EntryPrice.Range()
+		assert.True(t, ok)
+		assert.Equal(t, 250.5, low)
+		assert.Equal(t, 250.5, high)
+	})
+
+	t.Run("Отсутствующие уровни", func(t *testing.T) {
+		var prediction FinancialPrediction
+		err := json.Unmarshal([]byte(`{"entry_price": null, "stop_loss": null, "take_profit_levels": null}`), &prediction)
+
+		assert.NoError(t, err)
+		assert.Empty(t, prediction.TakeProfitLevels)
+		_, _, ok := prediction.EntryPrice.Range()
+		assert.False(t, ok)
+		_, ok = prediction.RiskReward()
+		assert.False(t, ok)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // FlexibleFloatOrString представляет собой поле, которое может быть либо float64, либо string.
@@ -88,6 +89,18 @@ func (fsn *FlexibleStringOrNumber) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("could not unmarshal into float64 or string: %s", data)
 }
 
+// MarshalJSON реализует интерфейс json.Marshaler: значение записывается так же, как его вернула модель, —
+// null, строкой или числом, чтобы результат можно было прочитать обратно через UnmarshalJSON.
+func (fsn FlexibleStringOrNumber) MarshalJSON() ([]byte, error) {
+	if fsn.IsNull {
+		return []byte("null"), nil
+	}
+	if fsn.IsString {
+		return json.Marshal(fsn.StringValue)
+	}
+	return json.Marshal(fsn.FloatValue)
+}
+
 // String возвращает строковое представление FlexibleStringOrNumber.
 func (fsn FlexibleStringOrNumber) String() string {
 	if fsn.IsNull {
@@ -101,6 +114,11 @@ func (fsn FlexibleStringOrNumber) String() string {
 
 type MessageAnalysis struct {
 	Predictions []FinancialPrediction
+	Skipped     bool            // Сообщение отсеяно предварительным фильтром и не анализировалось
+	SkipReason  string          // Причина пропуска, см. константы SkipReason*
+	DuplicateOf *DuplicateMatch // Исходное сообщение, если сообщение пропущено как повтор
+	Usage       Usage           // Суммарная статистика обращений к модели
+	Latency     time.Duration   // Время анализа сообщения, включая все шаги конвейера
 }
 
 type FinancialPrediction struct {
@@ -109,45 +127,131 @@ type FinancialPrediction struct {
 	Ticker              string                 `json:"ticker"`
 	TargetPrice         FlexibleStringOrNumber `json:"target_price"`
 	TargetChangePercent FlexibleStringOrNumber `json:"target_change_percent"`
+	EntryPrice          FlexibleStringOrNumber `json:"entry_price"`
+	StopLoss            FlexibleStringOrNumber `json:"stop_loss"`
+	TakeProfitLevels    PriceLevels            `json:"take_profit_levels"`
 	Period              string                 `json:"period"`
 	Recommendation      string                 `json:"recommendation"`
 	Direction           string                 `json:"direction"`
 	JustificationText   string                 `json:"justification_text"`
+	SelfRating          FlexibleStringOrNumber `json:"confidence"`                 // Самооценка модели: насколько явно автор дает рекомендацию
+	Evidence            *EvidenceCheck         `json:"evidence,omitempty"`         // Заполняется EvidenceStep
+	Agreement           float64                `json:"agreement,omitempty"`        // Доля участников ансамбля, согласных с прогнозом
+	EnsembleSize        int                    `json:"ensemble_size,omitempty"`    // Число участников ансамбля; 0 — ансамбль не использовался
+	Confidence          float64                `json:"confidence_score,omitempty"` // Итоговая уверенность 0..1, заполняется ConfidenceStep
+	Contextual          bool                   `json:"contextual,omitempty"`       // Тикер взят из сообщения, на которое отвечает автор
+}
+
+// UnmarshalJSON игнорирует message_id из ответа модели: идентификатор сообщения проставляет конвейер,
+// а модели нередко возвращают в этом поле произвольные строки.
+func (p *FinancialPrediction) UnmarshalJSON(data []byte) error {
+	type predictionAlias FinancialPrediction
+	aux := struct {
+		*predictionAlias
+		MessageID json.RawMessage `json:"message_id"`
+	}{predictionAlias: (*predictionAlias)(p)}
+	return json.Unmarshal(data, &aux)
+}
+
+// NormalizeTicker приводит тикер к виду для сравнения: без пробелов по краям и префиксов # и $, в верхнем регистре.
+func NormalizeTicker(ticker string) string {
+	return strings.ToUpper(strings.TrimLeft(strings.TrimSpace(ticker), "#$"))
 }
 
 type AIClient interface {
-	AnalyzeMessage(ctx context.Context, message, channel string, messageID int64) (*MessageAnalysis, error)
+	AnalyzeMessage(ctx context.Context, message, channel string, messageID int64, opts ...AnalyzeOption) (*MessageAnalysis, error)
 	AnalyzeBatch(ctx context.Context, messages []string, channel string) ([]*MessageAnalysis, error)
 }
 
 type OllamaClient struct {
-	baseURL         string
-	model           string
-	debug           bool
-	sendRequestFunc func(ctx context.Context, prompt string) ([]byte, error) // Добавлено для мокирования
-	temperature     float64
-	topP            float64
-	maxTokens       int
-	stop            []string
+	baseURL          string
+	model            string
+	debug            bool
+	sendRequestFunc  func(ctx context.Context, prompt string) ([]byte, error) // Добавлено для мокирования
+	embedRequestFunc func(ctx context.Context, model string, inputs []string) ([]byte, error)
+	temperature      float64
+	topP             float64
+	maxTokens        int
+	stop             []string
+	numCtx           int
+	steps            []PipelineStep
+	defaultSender    bool           // sendRequestFunc указывает на defaultSendOllamaRequest этого клиента
+	tickerResolver   TickerResolver // Справочник тикеров для шагов конвейера; может быть nil
+	embeddingIndex   EmbeddingIndex // Векторы ранее обработанных сообщений для DuplicateStep; может быть nil
 }
 
 // OllamaGenerateRequest - структура для запроса к Ollama API /api/generate
 type OllamaGenerateRequest struct {
-	Model       string   `json:"model"`
-	Prompt      string   `json:"prompt"`
-	Stream      bool     `json:"stream"`
+	Model   string        `json:"model"`
+	Prompt  string        `json:"prompt"`
+	Stream  bool          `json:"stream"`
+	Options OllamaOptions `json:"options"` // Параметры генерации Ollama принимает только внутри options
+}
+
+// OllamaOptions - параметры генерации для Ollama API
+type OllamaOptions struct {
+	NumCtx      int      `json:"num_ctx,omitempty"` // Размер контекстного окна; 0 — значение модели по умолчанию
 	Temperature float64  `json:"temperature,omitempty"`
 	TopP        float64  `json:"top_p,omitempty"`
 	MaxTokens   int      `json:"num_predict,omitempty"` // Ollama использует num_predict для max_tokens
 	Stop        []string `json:"stop,omitempty"`
 }
 
+// doneReasonLength — значение done_reason, когда генерация остановлена по лимиту num_predict.
+const doneReasonLength = "length"
+
 // OllamaGenerateResponse - структура для ответа от Ollama API /api/generate
 type OllamaGenerateResponse struct {
-	Model     string `json:"model"`
-	CreatedAt string `json:"created_at"`
-	Response  string `json:"response"` // Основной текст ответа
-	Done      bool   `json:"done"`
+	Model              string `json:"model"`
+	CreatedAt          string `json:"created_at"`
+	Response           string `json:"response"` // Основной текст ответа
+	Done               bool   `json:"done"`
+	DoneReason         string `json:"done_reason,omitempty"`
+	TotalDuration      int64  `json:"total_duration,omitempty"` // Длительности Ollama возвращает в наносекундах
+	PromptEvalCount    int    `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int    `json:"eval_count,omitempty"`
+	EvalDuration       int64  `json:"eval_duration,omitempty"`
+}
+
+// Usage возвращает статистику токенов и времени генерации ответа.
+func (r *OllamaGenerateResponse) Usage() Usage {
+	return Usage{
+		Requests:         1,
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		EvalDuration:     time.Duration(r.EvalDuration),
+		TotalDuration:    time.Duration(r.TotalDuration),
+		Responses:        []string{r.Response},
+	}
+}
+
+// Usage накапливает статистику обращений к модели при анализе сообщения.
+type Usage struct {
+	Requests         int           `json:"requests"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	EvalDuration     time.Duration `json:"eval_duration"`
+	TotalDuration    time.Duration `json:"total_duration"`
+	Responses        []string      `json:"-"` // Необработанные ответы модели в порядке запросов; в отчеты не выводятся
+}
+
+// Add суммирует статистику другого запроса.
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.EvalDuration += other.EvalDuration
+	u.TotalDuration += other.TotalDuration
+	u.Responses = append(u.Responses, other.Responses...)
+}
+
+// TokensPerSecond возвращает скорость генерации ответа; 0, если Ollama не сообщила длительность.
+func (u Usage) TokensPerSecond() float64 {
+	if u.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(u.CompletionTokens) / u.EvalDuration.Seconds()
 }
 
 func NewOllamaClient(baseURL string, model string, debug bool, temperature float64, topP float64, maxTokens int, stop []string) *OllamaClient {
@@ -161,61 +265,198 @@ func NewOllamaClient(baseURL string, model string, debug bool, temperature float
 		stop:        stop,
 	}
 	client.sendRequestFunc = client.defaultSendOllamaRequest // Инициализируем реальной функцией
+	client.defaultSender = true
+	client.embedRequestFunc = client.defaultSendEmbedRequest
+	client.steps = []PipelineStep{NewPredictionStep()}
 	return client
 }
 
+// Model возвращает имя модели Ollama, которую использует клиент.
+func (c *OllamaClient) Model() string {
+	return c.model
+}
+
+// withSampling возвращает копию клиента с другой моделью и температурой.
+// Пустая модель и нулевая температура оставляют значения исходного клиента.
+func (c *OllamaClient) withSampling(model string, temperature float64) *OllamaClient {
+	clone := *c
+	if model != "" {
+		clone.model = model
+	}
+	if temperature != 0 {
+		clone.temperature = temperature
+	}
+	if c.defaultSender {
+		clone.sendRequestFunc = clone.defaultSendOllamaRequest
+	}
+	return &clone
+}
+
+// SetContextWindow задает размер контекстного окна модели (num_ctx); 0 оставляет значение модели по умолчанию.
+func (c *OllamaClient) SetContextWindow(numCtx int) {
+	c.numCtx = numCtx
+}
+
+// SetEmbeddingIndex задает индекс векторов сообщений, по которому DuplicateStep ищет повторы.
+func (c *OllamaClient) SetEmbeddingIndex(index EmbeddingIndex) {
+	c.embeddingIndex = index
+}
+
+// SetTickerResolver задает справочник, по которому шаги конвейера оценивают сопоставление тикеров с акциями.
+func (c *OllamaClient) SetTickerResolver(resolver TickerResolver) {
+	c.tickerResolver = resolver
+}
+
+// SetPipeline заменяет шаги конвейера, которые AnalyzeMessage выполняет для каждого сообщения.
+func (c *OllamaClient) SetPipeline(steps ...PipelineStep) {
+	c.steps = steps
+}
+
+// PipelineState хранит состояние анализа одного сообщения, которое шаги конвейера читают и дополняют.
+type PipelineState struct {
+	MessageID      int64
+	Channel        string
+	Message        string
+	SentAt         time.Time // Время отправки сообщения (см. WithSentAt), нулевое, если неизвестно
+	Predictions    []FinancialPrediction
+	Skipped        bool
+	SkipReason     string
+	FullyExtracted bool             // Прогнозы уже полностью извлечены без модели (например, по шаблонам), шаги LLM пропускаются
+	DuplicateOf    *DuplicateMatch  // Исходное сообщение, если DuplicateStep признал сообщение повтором
+	ReplyContext   []ContextMessage // Сообщения, на которые отвечает автор (см. WithReplyContext)
+	Usage          Usage
+}
+
+// Skip останавливает конвейер: оставшиеся шаги не выполняются, сообщение помечается пропущенным с указанной причиной.
+func (s *PipelineState) Skip(reason string) {
+	s.Skipped = true
+	s.SkipReason = reason
+}
+
+// AddModelPredictions добавляет прогнозы модели к состоянию. Прогнозы по тикерам, уже извлеченным предыдущими шагами
+// (например, по шаблонам), имеют приоритет над ответом модели и не дублируются.
+func (s *PipelineState) AddModelPredictions(predictions []FinancialPrediction) {
+	known := make(map[string]bool, len(s.Predictions))
+	for _, prediction := range s.Predictions {
+		known[NormalizeTicker(prediction.Ticker)] = true
+	}
+	for _, prediction := range predictions {
+		if known[NormalizeTicker(prediction.Ticker)] {
+			continue
+		}
+		s.Predictions = append(s.Predictions, prediction)
+	}
+}
+
 // PipelineStep определяет интерфейс для шага в конвейере анализа сообщений.
+// Шаги выполняются последовательно, каждый следующий видит прогнозы, полученные предыдущими.
 type PipelineStep interface {
-	Execute(ctx context.Context, client *OllamaClient, message string, messageID int64) ([]FinancialPrediction, error)
+	Process(ctx context.Context, client *OllamaClient, state *PipelineState) error
 }
 
 // PredictionStep реализует PipelineStep для выполнения финансового прогнозирования.
 type PredictionStep struct {
-	// Здесь могут быть дополнительные поля, если шаг требует собственной конфигурации
+	promptVersion    string
+	promptTemplate   string
+	fewShot          *FewShotSelector // Подбор примеров для промта; nil — промт без примеров
+	chunking         bool             // Анализировать длинные сообщения и обрезанные ответы по фрагментам
+	maxMessageTokens int              // Длина сообщения, начиная с которой оно сразу делится на фрагменты; 0 — не ограничивать
 }
 
-// NewPredictionStep создает новый экземпляр PredictionStep.
+// NewPredictionStep создает новый экземпляр PredictionStep с промтом версии DefaultPromptVersion.
 func NewPredictionStep() *PredictionStep {
-	return &PredictionStep{}
+	return NewPredictionStepWithPrompt(DefaultPromptVersion, promptTemplates[DefaultPromptVersion])
+}
+
+// NewPredictionStepWithPrompt создает PredictionStep с заданным шаблоном промта (см. ResolvePrompt).
+func NewPredictionStepWithPrompt(version, template string) *PredictionStep {
+	return &PredictionStep{
+		promptVersion:  version,
+		promptTemplate: template,
+	}
+}
+
+// PromptVersion возвращает версию промта, которую использует шаг.
+func (s *PredictionStep) PromptVersion() string {
+	return s.promptVersion
+}
+
+// SetFewShot включает подстановку в промт похожих примеров из библиотеки.
+func (s *PredictionStep) SetFewShot(selector *FewShotSelector) {
+	s.fewShot = selector
+}
+
+// BuildPrompt подставляет сообщение в шаблон промта. Если задан FewShotSelector,
+// перед сообщением вставляются похожие примеры в пределах бюджета токенов, а за ними — контекст ответа replyContext.
+func (s *PredictionStep) BuildPrompt(message string, replyContext ...ContextMessage) string {
+	prompt := strings.Replace(insertReplyContext(s.promptTemplate, replyContext), messagePlaceholder, message, 1)
+	if s.fewShot == nil {
+		return prompt
+	}
+
+	examples := s.fewShot.Select(message, s.fewShot.Budget(prompt))
+	template := insertReplyContext(insertExamples(s.promptTemplate, examples), replyContext)
+	return strings.Replace(template, messagePlaceholder, message, 1)
+}
+
+// Process извлекает прогнозы из сообщения и добавляет их к состоянию конвейера.
+func (s *PredictionStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	if state.FullyExtracted {
+		return nil
+	}
+	predictions, usage, err := s.extract(ctx, client, state.Message, state.MessageID, state.ReplyContext)
+	state.Usage.Add(usage)
+	if err != nil {
+		return err
+	}
+
+	state.AddModelPredictions(predictions)
+	return nil
 }
 
 // Execute выполняет шаг прогнозирования, используя предоставленный промт.
 func (s *PredictionStep) Execute(ctx context.Context, client *OllamaClient, message string, messageID int64) ([]FinancialPrediction, error) {
-	prompt := fmt.Sprintf(`
-	Ты опытный финансовый аналитик. Твоя задача — извлечь из предоставленного сообщения прогнозы по акциям и структурировать их в формате JSON. Если какая-либо информация (например, целевая цена или период) отсутствует, используй значение null.
-	Формат ответа: JSON-массив, содержащий один или несколько объектов. Каждый объект должен иметь следующие поля:
-	prediction_type: Тип прогноза. Используй один из вариантов: "Продолжение тренда", "Разворот", "Цель с коррекцией", "Накопление перед пробоем", "Долгосрочный пессимизм", "Неопределенный".
-	ticker: Тикер акции, указанный в сообщении.
-	period: Временной горизонт прогноза. Используй один из вариантов: "Сегодня", "Краткосрочный", "Среднесрочный", "Долгосрочный", "Неопределенный".
-	target_price: Целевая цена или ценовой диапазон. Извлеки числовое значение или диапазон в виде строки. Если цена не указана, используй null.
-	target_change_percent: Целевой процент изменения цены. Извлеки числовое значение или диапазон в виде строки. Если процент не указан, используй null.
-	recommendation: Рекомендация автора сообщения. Используй один из вариантов: "Покупать", "Продавать", "Держать", "Неопределенный".
-	direction: Направление сделки. Используй один из вариантов: "Лонг", "Шорт", "Неопределенный".
-	justification_text: Цитата из исходного текста, которая подтверждает данный прогноз.
-
-	Сообщение: %s
-
-	Отвечай только JSON, без дополнительного текста.`, message)
-
-	req := OllamaGenerateRequest{
-		Model:  client.model,
-		Prompt: prompt,
-		Stream: false,
+	predictions, _, err := s.extract(ctx, client, message, messageID, nil)
+	return predictions, err
+}
+
+// extract извлекает прогнозы из сообщения, при включенном разбиении — по фрагментам (см. SetChunking).
+// Статистика запросов возвращается и при ошибке разбора.
+func (s *PredictionStep) extract(ctx context.Context, client *OllamaClient, message string, messageID int64, replyContext []ContextMessage) ([]FinancialPrediction, Usage, error) {
+	chunks := []string{message}
+	if s.chunking && s.maxMessageTokens > 0 {
+		chunks = SplitMessage(message, s.maxMessageTokens)
+		if len(chunks) > 1 {
+			log.Printf("Message %d is too long (~%d tokens), analyzing it in %d chunks", messageID, EstimateTokens(message), len(chunks))
+		}
 	}
+	return s.extractChunks(ctx, client, chunks, messageID, replyContext, 0)
+}
 
-	res, err := client.sendRequestFunc(ctx, req.Prompt) // Используем внутреннюю функцию
+// extractOnce отправляет промт модели и разбирает прогнозы из ответа. Если модель остановилась по лимиту num_predict
+// (done_reason "length") и JSON не разбирается, возвращается ErrTruncatedResponse.
+func (s *PredictionStep) extractOnce(ctx context.Context, client *OllamaClient, message string, messageID int64, replyContext []ContextMessage) ([]FinancialPrediction, Usage, error) {
+	ollamaResponse, err := client.generate(ctx, s.BuildPrompt(message, replyContext...))
 	if err != nil {
-		return nil, fmt.Errorf("failed to send ollama request: %w", err)
+		return nil, Usage{}, err
 	}
 
-	var ollamaResponse OllamaGenerateResponse
-	if err := json.Unmarshal(res, &ollamaResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse Ollama response JSON: %w, body: %s", err, string(res))
+	predictions, err := parsePredictions(client, ollamaResponse.Response, messageID)
+	if err != nil && ollamaResponse.DoneReason == doneReasonLength {
+		return nil, ollamaResponse.Usage(), fmt.Errorf("%w: generation stopped at num_predict=%d: %v", ErrTruncatedResponse, client.maxTokens, err)
 	}
+	return predictions, ollamaResponse.Usage(), err
+}
 
-	content := ollamaResponse.Response
+// parsePredictions извлекает JSON с прогнозами из текстового ответа модели.
+func parsePredictions(client *OllamaClient, response string, messageID int64) ([]FinancialPrediction, error) {
+	content := response
 	content = strings.TrimSpace(content)
 
+	if content == "" || content == "null" {
+		return nil, fmt.Errorf("%w: ollama returned empty or null JSON content", ErrInvalidResponse)
+	}
+
 	// Удаляем Markdown-обертку, если она есть
 	if strings.HasPrefix(content, "```json") && strings.HasSuffix(content, "```") {
 		content = strings.TrimPrefix(content, "```json")
@@ -239,22 +480,25 @@ func (s *PredictionStep) Execute(ctx context.Context, client *OllamaClient, mess
 	}
 
 	if jsonStart == -1 || jsonEnd == -1 || jsonEnd < jsonStart {
-		return nil, fmt.Errorf("invalid JSON response from Ollama: %s", content)
+		return nil, fmt.Errorf("%w: invalid JSON response from Ollama: %s", ErrInvalidResponse, content)
 	}
 
 	jsonContent := content[jsonStart : jsonEnd+1]
+	if !json.Valid([]byte(jsonContent)) {
+		return nil, fmt.Errorf("%w: invalid JSON response from Ollama: %s", ErrInvalidResponse, content)
+	}
 
 	if client.debug {
 		log.Printf("Ollama Financial Prediction Raw JSON Content: %s", jsonContent) // Логируем извлеченный JSON
 	}
 
 	if jsonContent == "" || jsonContent == "null" {
-		return nil, fmt.Errorf("ollama returned empty or null JSON content")
+		return nil, fmt.Errorf("%w: ollama returned empty or null JSON content", ErrInvalidResponse)
 	}
 
 	log.Printf("Attempting to unmarshal JSON into []FinancialPrediction, content length: %d", len(jsonContent))
 	var predictions []FinancialPrediction
-	err = json.Unmarshal([]byte(jsonContent), &predictions)
+	err := json.Unmarshal([]byte(jsonContent), &predictions)
 	if err != nil {
 		log.Printf("Failed to unmarshal financial prediction JSON as slice, attempting as single object: %v", err)
 		// Если не удалось демаршалировать как слайс, попробуем как одиночный объект
@@ -262,7 +506,7 @@ func (s *PredictionStep) Execute(ctx context.Context, client *OllamaClient, mess
 		if err := json.Unmarshal([]byte(jsonContent), &singlePrediction); err == nil {
 			predictions = []FinancialPrediction{singlePrediction}
 		} else {
-			return nil, fmt.Errorf("failed to unmarshal financial prediction JSON as slice or single object: %w, content: %s", err, jsonContent)
+			return nil, fmt.Errorf("%w: failed to unmarshal financial prediction JSON as slice or single object: %v, content: %s", ErrInvalidResponse, err, jsonContent)
 		}
 	}
 
@@ -273,23 +517,40 @@ func (s *PredictionStep) Execute(ctx context.Context, client *OllamaClient, mess
 	log.Printf("Successfully unmarshaled %d predictions.", len(predictions))
 
 	if len(predictions) == 0 {
-		return nil, fmt.Errorf("ollama analysis returned no predictions from content: %s", jsonContent)
+		return nil, fmt.Errorf("ollama analysis %w from content: %s", ErrNoPredictions, jsonContent)
 	}
 
 	return predictions, nil
 }
 
+// generate отправляет промт модели и разбирает конверт ответа Ollama.
+func (c *OllamaClient) generate(ctx context.Context, prompt string) (*OllamaGenerateResponse, error) {
+	res, err := c.sendRequestFunc(ctx, prompt) // Используем внутреннюю функцию
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to send ollama request: %w", ErrRequestFailed, err)
+	}
+
+	var ollamaResponse OllamaGenerateResponse
+	if err := json.Unmarshal(res, &ollamaResponse); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse Ollama response JSON: %v, body: %s", ErrInvalidResponse, err, string(res))
+	}
+	return &ollamaResponse, nil
+}
+
 // sendOllamaRequest отправляет запрос к Ollama API и возвращает байты ответа.
 // Переименовываем оригинальную функцию в defaultSendOllamaRequest
 func (c *OllamaClient) defaultSendOllamaRequest(ctx context.Context, prompt string) ([]byte, error) {
 	requestBody := OllamaGenerateRequest{
-		Model:       c.model,
-		Prompt:      prompt,
-		Stream:      false, // Мы хотим получить весь ответ сразу
-		Temperature: c.temperature,
-		TopP:        c.topP,
-		MaxTokens:   c.maxTokens,
-		Stop:        c.stop,
+		Model:  c.model,
+		Prompt: prompt,
+		Stream: false, // Мы хотим получить весь ответ сразу
+		Options: OllamaOptions{
+			NumCtx:      c.numCtx,
+			Temperature: c.temperature,
+			TopP:        c.topP,
+			MaxTokens:   c.maxTokens,
+			Stop:        c.stop,
+		},
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -322,20 +583,40 @@ func (c *OllamaClient) defaultSendOllamaRequest(ctx context.Context, prompt stri
 	return bodyBytes, nil
 }
 
-func (c *OllamaClient) AnalyzeMessage(ctx context.Context, message, channel string, messageID int64) (*MessageAnalysis, error) {
-	// messageID := uuid.New() // Теперь ID приходит из БД
+func (c *OllamaClient) AnalyzeMessage(ctx context.Context, message, channel string, messageID int64, opts ...AnalyzeOption) (*MessageAnalysis, error) {
+	start := time.Now()
+	state := &PipelineState{
+		MessageID: messageID,
+		Channel:   channel,
+		Message:   message,
+	}
+	for _, opt := range opts {
+		opt(state)
+	}
 
-	predictionStep := NewPredictionStep()
-	predictions, err := predictionStep.Execute(ctx, c, message, messageID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute prediction step: %w", err)
+	for _, step := range c.steps {
+		if err := step.Process(ctx, c, state); err != nil {
+			return nil, fmt.Errorf("failed to execute pipeline step %T: %w", step, err)
+		}
+		if state.Skipped {
+			return &MessageAnalysis{
+				Skipped:     true,
+				SkipReason:  state.SkipReason,
+				DuplicateOf: state.DuplicateOf,
+				Usage:       state.Usage,
+				Latency:     time.Since(start),
+			}, nil
+		}
 	}
 
-	if len(predictions) == 0 {
-		return nil, fmt.Errorf("no predictions returned for message ID %d", messageID)
+	if len(state.Predictions) == 0 {
+		return nil, fmt.Errorf("pipeline %w for message ID %d", ErrNoPredictions, messageID)
 	}
+	markContextual(state.Predictions, state.Message, state.ReplyContext)
 
 	return &MessageAnalysis{
-		Predictions: predictions,
+		Predictions: state.Predictions,
+		Usage:       state.Usage,
+		Latency:     time.Since(start),
 	}, nil
 }
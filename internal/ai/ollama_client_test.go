@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -83,7 +82,7 @@ func TestPredictionStep_Execute(t *testing.T) {
 ]`
 
 	t.Run("Успешное выполнение и демаршалинг нескольких прогнозов", func(t *testing.T) {
-		messageID := uuid.MustParse("2238f6c5-98a0-4180-8dcc-be76dd15fecc")
+		messageID := int64(2238)
 
 		formattedInternalResponse := internalJSONResponse // Теперь без Sprintf
 
@@ -183,7 +182,7 @@ func TestPredictionStep_Execute(t *testing.T) {
 	})
 
 	t.Run("Ошибка при запросе к Ollama", func(t *testing.T) {
-		messageID := uuid.New()
+		messageID := int64(1)
 
 		// Мок-функция, которая всегда возвращает ошибку
 		mockSendOllamaRequest := func(ctx context.Context, prompt string) ([]byte, error) {
@@ -204,7 +203,7 @@ func TestPredictionStep_Execute(t *testing.T) {
 	})
 
 	t.Run("Некорректный JSON-ответ от Ollama", func(t *testing.T) {
-		messageID := uuid.New()
+		messageID := int64(1)
 		// Мок-функция
 		mockSendOllamaRequest := func(ctx context.Context, prompt string) ([]byte, error) {
 			fullOllamaResponse := OllamaGenerateResponse{
@@ -230,7 +229,7 @@ func TestPredictionStep_Execute(t *testing.T) {
 	})
 
 	t.Run("Пустой JSON-контент от Ollama", func(t *testing.T) {
-		messageID := uuid.New()
+		messageID := int64(1)
 		// Мок-функция
 		mockSendOllamaRequest := func(ctx context.Context, prompt string) ([]byte, error) {
 			fullOllamaResponse := OllamaGenerateResponse{
@@ -256,7 +255,7 @@ func TestPredictionStep_Execute(t *testing.T) {
 	})
 
 	t.Run("Ollama возвращает пустой массив прогнозов", func(t *testing.T) {
-		messageID := uuid.New()
+		messageID := int64(1)
 		// Мок-функция
 		mockSendOllamaRequest := func(ctx context.Context, prompt string) ([]byte, error) {
 			fullOllamaResponse := OllamaGenerateResponse{
@@ -281,3 +280,14 @@ func TestPredictionStep_Execute(t *testing.T) {
 		assert.Contains(t, err.Error(), "returned no predictions")
 	})
 }
+
+// TestNormalizeTicker проверяет приведение тикера к виду для сравнения
+func TestNormalizeTicker(t *testing.T) {
+	assert.Equal(t, "SBER", NormalizeTicker(" $sber "))
+	assert.Equal(t, "GAZP", NormalizeTicker("#GAZP"))
+
+	// Прогноз модели по тикеру, уже извлеченному шаблоном с префиксом, не дублируется
+	state := &PipelineState{Predictions: []FinancialPrediction{{Ticker: "#SBER"}}}
+	state.AddModelPredictions([]FinancialPrediction{{Ticker: "sber"}, {Ticker: "GAZP"}})
+	assert.Len(t, state.Predictions, 2)
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+
+	"rkata-ai/trade-radar/internal/config"
+)
+
+// BuildPipeline собирает шаги конвейера анализа согласно конфигурации:
+// предварительный фильтр, поиск повторов, извлечение по шаблонам, извлечение моделью или ансамблем,
+// проверка цитат и расчет уверенности.
+func BuildPipeline(cfg *config.AIConfig) ([]PipelineStep, error) {
+	var steps []PipelineStep
+
+	if cfg.PreFilter.Enabled {
+		preFilterStep, err := NewPreFilterStep(cfg.PreFilter.AdPatterns, cfg.PreFilter.UseLLM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pre-filter step: %w", err)
+		}
+		steps = append(steps, preFilterStep)
+	}
+
+	if cfg.Embeddings.Enabled {
+		window := time.Duration(cfg.Embeddings.WindowHours) * time.Hour
+		steps = append(steps, NewDuplicateStep(cfg.Embeddings.Model, cfg.Embeddings.DuplicateThreshold, window))
+	}
+
+	if len(cfg.Rules.Templates) > 0 {
+		templates := make([]SignalTemplate, 0, len(cfg.Rules.Templates))
+		for _, template := range cfg.Rules.Templates {
+			templates = append(templates, SignalTemplate{
+				Name:           template.Name,
+				Channels:       template.Channels,
+				Pattern:        template.Pattern,
+				PredictionType: template.PredictionType,
+				Period:         template.Period,
+			})
+		}
+		ruleStep, err := NewRuleExtractionStep(templates, cfg.Rules.MinCoverage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create rule extraction step: %w", err)
+		}
+		steps = append(steps, ruleStep)
+	}
+
+	promptVersion, promptTemplate, err := ResolvePrompt(cfg.PromptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve prompt: %w", err)
+	}
+	predictionStep := NewPredictionStepWithPrompt(promptVersion, promptTemplate)
+	if cfg.FewShot.Enabled {
+		examples, err := LoadFewShotExamples(cfg.FewShot.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load few-shot examples: %w", err)
+		}
+		predictionStep.SetFewShot(NewFewShotSelector(examples, cfg.FewShot.MaxExamples, cfg.FewShot.MaxTokens,
+			cfg.FewShot.MinSimilarity, cfg.NumCtx, cfg.MaxTokens))
+	}
+	if cfg.Chunking.Enabled {
+		predictionStep.SetChunking(chunkTokens(cfg, promptTemplate))
+	}
+	if cfg.Ensemble.Enabled {
+		steps = append(steps, NewEnsembleStep(predictionStep, cfg.Ensemble.Models, cfg.Ensemble.Samples, cfg.Ensemble.Temperature))
+	} else {
+		steps = append(steps, predictionStep)
+	}
+
+	if cfg.Evidence.Enabled {
+		steps = append(steps, NewEvidenceStep(cfg.Evidence.MinQuoteScore, cfg.Evidence.RejectUnverified))
+	}
+
+	if cfg.Confidence.Enabled {
+		steps = append(steps, NewConfidenceStep(ConfidenceWeights{
+			SelfRating: cfg.Confidence.Weights.SelfRating,
+			Evidence:   cfg.Confidence.Weights.Evidence,
+			Ticker:     cfg.Confidence.Weights.Ticker,
+			Agreement:  cfg.Confidence.Weights.Agreement,
+		}))
+	}
+
+	return steps, nil
+}
+
+// minChunkTokens — нижняя граница длины фрагмента, рассчитанной по num_ctx, чтобы не дробить сообщение на обрывки.
+const minChunkTokens = 256
+
+// chunkTokens возвращает длину фрагмента сообщения: из конфигурации или, если она не задана,
+// остаток контекстного окна после шаблона промта, примеров и ответа модели. Без num_ctx длина не ограничивается.
+func chunkTokens(cfg *config.AIConfig, promptTemplate string) int {
+	if cfg.Chunking.MaxMessageTokens > 0 || cfg.NumCtx <= 0 {
+		return cfg.Chunking.MaxMessageTokens
+	}
+
+	available := cfg.NumCtx - cfg.MaxTokens - EstimateTokens(promptTemplate)
+	if cfg.FewShot.Enabled {
+		available -= cfg.FewShot.MaxTokens
+	}
+	if available < minChunkTokens {
+		return minChunkTokens
+	}
+	return available
+}
@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Причины пропуска сообщения предварительным фильтром.
+const (
+	SkipReasonEmpty       = "empty"
+	SkipReasonAd          = "ad"
+	SkipReasonNoSignal    = "no_signal_tokens"
+	SkipReasonLLMNoSignal = "llm_no_signal"
+)
+
+// DefaultAdPatterns — шаблоны рекламных сообщений, используемые, если в конфигурации не задано своих.
+var DefaultAdPatterns = []string{
+	`(?i)#реклама`,
+	`(?i)\berid\b`,
+	`(?i)промокод`,
+	`(?i)розыгрыш`,
+	`(?i)подписывайтесь`,
+}
+
+var (
+	// cashtagRe находит тикеры с префиксом: $SBER, #GAZP.
+	cashtagRe = regexp.MustCompile(`[#$][A-Za-z]{2,6}\b`)
+	// tickerLikeRe находит отдельно стоящие слова из заглавных латинских букв: SBER, GAZP.
+	tickerLikeRe = regexp.MustCompile(`\b[A-Z]{3,5}\b`)
+	// priceLikeRe находит числа с единицами цены или процента: 250₽, 12%, 300 руб.
+	priceLikeRe = regexp.MustCompile(`(?i)\d+(?:[.,]\d+)?\s*(?:%|₽|\$|руб|р\.|usd|п\.п\.)`)
+	// signalKeywordRe находит слова, характерные для торговых сигналов.
+	signalKeywordRe = regexp.MustCompile(`(?i)(покуп|прода|лонг|шорт|цел[ьи]|стоп|вход|тейк|таргет|держ)`)
+)
+
+// PreFilterStep реализует PipelineStep для дешевого отсева сообщений без торговых сигналов
+// (новости, реклама, болтовня) до основного промта извлечения.
+type PreFilterStep struct {
+	adPatterns []*regexp.Regexp
+	useLLM     bool
+}
+
+// NewPreFilterStep создает шаг предварительной фильтрации. Если adPatterns пуст, используются DefaultAdPatterns.
+// При useLLM сообщения, прошедшие детерминированные правила, дополнительно проверяются коротким вопросом к модели.
+func NewPreFilterStep(adPatterns []string, useLLM bool) (*PreFilterStep, error) {
+	if len(adPatterns) == 0 {
+		adPatterns = DefaultAdPatterns
+	}
+
+	step := &PreFilterStep{useLLM: useLLM}
+	for _, pattern := range adPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ad pattern %q: %w", pattern, err)
+		}
+		step.adPatterns = append(step.adPatterns, re)
+	}
+	return step, nil
+}
+
+// Process помечает сообщение пропущенным, если оно не похоже на торговый сигнал.
+func (s *PreFilterStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	if reason := s.Check(state.Message); reason != "" {
+		state.Skip(reason)
+		return nil
+	}
+
+	if !s.useLLM {
+		return nil
+	}
+
+	isSignal, usage, err := s.classify(ctx, client, state.Message)
+	state.Usage.Add(usage)
+	if err != nil {
+		// Классификация — лишь оптимизация: при ошибке сообщение уходит на полный анализ
+		log.Printf("Pre-filter classification failed for message %d, continuing with full analysis: %v", state.MessageID, err)
+		return nil
+	}
+	if !isSignal {
+		state.Skip(SkipReasonLLMNoSignal)
+	}
+	return nil
+}
+
+// Check применяет детерминированные правила и возвращает причину пропуска или пустую строку.
+func (s *PreFilterStep) Check(message string) string {
+	if strings.TrimSpace(message) == "" {
+		return SkipReasonEmpty
+	}
+
+	for _, re := range s.adPatterns {
+		if re.MatchString(message) {
+			return SkipReasonAd
+		}
+	}
+
+	if cashtagRe.MatchString(message) ||
+		tickerLikeRe.MatchString(message) ||
+		priceLikeRe.MatchString(message) ||
+		signalKeywordRe.MatchString(message) {
+		return ""
+	}
+
+	return SkipReasonNoSignal
+}
+
+// classify задает модели короткий вопрос да/нет о наличии торговой рекомендации.
+// Неоднозначный ответ трактуется как "да", чтобы не терять сигналы.
+func (s *PreFilterStep) classify(ctx context.Context, client *OllamaClient, message string) (bool, Usage, error) {
+	prompt := fmt.Sprintf(`Содержит ли сообщение торговую рекомендацию или прогноз по конкретной акции (покупка, продажа, цель, стоп)?
+	Ответь одним словом: "да" или "нет".
+
+	Сообщение: %s`, message)
+
+	ollamaResponse, err := client.generate(ctx, prompt)
+	if err != nil {
+		return false, Usage{}, err
+	}
+	usage := ollamaResponse.Usage()
+
+	answer := strings.ToLower(strings.Trim(strings.TrimSpace(ollamaResponse.Response), `."'!`))
+	if strings.HasPrefix(answer, "нет") || strings.HasPrefix(answer, "no") {
+		return false, usage, nil
+	}
+	return true, usage, nil
+}
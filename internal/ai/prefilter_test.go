@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPreFilterStep проверяет отсев сообщений без торговых сигналов
+func TestPreFilterStep(t *testing.T) {
+	step, err := NewPreFilterStep(nil, false)
+	assert.NoError(t, err)
+
+	t.Run("Детерминированные правила", func(t *testing.T) {
+		assert.Equal(t, "", step.Check("#SBER лонг от 250"))
+		assert.Equal(t, "", step.Check("Сбер: покупаем на откате"))
+		assert.Equal(t, "", step.Check("Газпром может вырасти на 15%"))
+		assert.Equal(t, SkipReasonEmpty, step.Check("   "))
+		assert.Equal(t, SkipReasonAd, step.Check("Курс по инвестициям со скидкой, промокод START #реклама"))
+		assert.Equal(t, SkipReasonNoSignal, step.Check("Всем хороших выходных!"))
+	})
+
+	t.Run("Пропуск останавливает конвейер", func(t *testing.T) {
+		client := &OllamaClient{
+			sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+				t.Fatal("LLM must not be called for skipped messages")
+				return nil, nil
+			},
+			steps: []PipelineStep{step, NewPredictionStep()},
+		}
+
+		analysis, err := client.AnalyzeMessage(context.Background(), "Всем хороших выходных!", "1", 1)
+
+		assert.NoError(t, err)
+		assert.True(t, analysis.Skipped)
+		assert.Equal(t, SkipReasonNoSignal, analysis.SkipReason)
+		assert.Empty(t, analysis.Predictions)
+	})
+
+	t.Run("Классификация моделью", func(t *testing.T) {
+		llmStep, err := NewPreFilterStep(nil, true)
+		assert.NoError(t, err)
+
+		answer := "Нет."
+		client := &OllamaClient{
+			sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+				return json.Marshal(OllamaGenerateResponse{Response: answer, Done: true})
+			},
+		}
+
+		state := &PipelineState{Message: "SBER отчитался по МСФО"}
+		assert.NoError(t, llmStep.Process(context.Background(), client, state))
+		assert.True(t, state.Skipped)
+		assert.Equal(t, SkipReasonLLMNoSignal, state.SkipReason)
+
+		answer = "Да"
+		state = &PipelineState{Message: "SBER отчитался по МСФО, покупаем"}
+		assert.NoError(t, llmStep.Process(context.Background(), client, state))
+		assert.False(t, state.Skipped)
+	})
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultPromptVersion — версия промта извлечения, используемая по умолчанию.
+const DefaultPromptVersion = "v1"
+
+// messagePlaceholder заменяется в шаблоне промта на текст сообщения.
+const messagePlaceholder = "{{message}}"
+
+// promptV1 — исходный подробный промт извлечения прогнозов.
+const promptV1 = `
+	Ты опытный финансовый аналитик. Твоя задача — извлечь из предоставленного сообщения прогнозы по акциям и структурировать их в формате JSON. Если какая-либо информация (например, целевая цена или период) отсутствует, используй значение null.
+	Формат ответа: JSON-массив, содержащий один или несколько объектов. Каждый объект должен иметь следующие поля:
+	prediction_type: Тип прогноза. Используй один из вариантов: "Продолжение тренда", "Разворот", "Цель с коррекцией", "Накопление перед пробоем", "Долгосрочный пессимизм", "Неопределенный".
+	ticker: Тикер акции, указанный в сообщении.
+	period: Временной горизонт прогноза. Используй один из вариантов: "Сегодня", "Краткосрочный", "Среднесрочный", "Долгосрочный", "Неопределенный".
+	target_price: Целевая цена или ценовой диапазон. Извлеки числовое значение или диапазон в виде строки. Если цена не указана, используй null.
+	target_change_percent: Целевой процент изменения цены. Извлеки числовое значение или диапазон в виде строки. Если процент не указан, используй null.
+	entry_price: Цена или зона входа в сделку. Извлеки числовое значение или диапазон в виде строки (например, "250-255"). Если вход не указан, используй null.
+	stop_loss: Уровень стоп-лосса. Извлеки числовое значение. Если стоп не указан, используй null.
+	take_profit_levels: Цели (take-profit) в том порядке, в котором они указаны в сообщении, в виде массива чисел (например, [270, 290]). Если цели не указаны, используй null.
+	recommendation: Рекомендация автора сообщения. Используй один из вариантов: "Покупать", "Продавать", "Держать", "Неопределенный".
+	direction: Направление сделки. Используй один из вариантов: "Лонг", "Шорт", "Неопределенный".
+	justification_text: Цитата из исходного текста, которая подтверждает данный прогноз.
+	confidence: Насколько явно автор дает рекомендацию, число от 0 до 1. Например, 0.3 для предположения "может вырасти" и 0.9 для явного сигнала "покупаем, цель 300".
+
+	Сообщение: {{message}}
+
+	Отвечай только JSON, без дополнительного текста.`
+
+// promptV2 — компактный промт с примером ответа для небольших моделей.
+const promptV2 = `Извлеки из сообщения торговые прогнозы по акциям. Верни JSON-массив объектов с полями:
+ticker, prediction_type ("Продолжение тренда"|"Разворот"|"Цель с коррекцией"|"Накопление перед пробоем"|"Долгосрочный пессимизм"|"Неопределенный"),
+period ("Сегодня"|"Краткосрочный"|"Среднесрочный"|"Долгосрочный"|"Неопределенный"), target_price, target_change_percent, entry_price, stop_loss,
+take_profit_levels (массив чисел), recommendation ("Покупать"|"Продавать"|"Держать"|"Неопределенный"), direction ("Лонг"|"Шорт"|"Неопределенный"),
+justification_text (точная цитата из сообщения), confidence (0..1: 0.3 — "может вырасти", 0.9 — "покупаем, цель 300"). Отсутствующие значения — null. Если прогнозов нет, верни [].
+
+Пример: "#SBER лонг, вход 250-255, стоп 240, цели 270/290" ->
+[{"ticker":"SBER","prediction_type":"Неопределенный","period":"Неопределенный","target_price":290,"target_change_percent":null,"entry_price":"250-255","stop_loss":240,"take_profit_levels":[270,290],"recommendation":"Покупать","direction":"Лонг","justification_text":"#SBER лонг, вход 250-255, стоп 240, цели 270/290","confidence":0.9}]
+
+Сообщение: {{message}}
+
+Ответ (только JSON):`
+
+var promptTemplates = map[string]string{
+	"v1": promptV1,
+	"v2": promptV2,
+}
+
+// PromptVersions возвращает отсортированный список встроенных версий промта.
+func PromptVersions() []string {
+	versions := make([]string, 0, len(promptTemplates))
+	for version := range promptTemplates {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// ResolvePrompt находит шаблон промта по имени встроенной версии или по пути к файлу.
+// Для файла версией считается имя файла без расширения. Шаблон должен содержать {{message}}.
+func ResolvePrompt(spec string) (version, template string, err error) {
+	if spec == "" {
+		spec = DefaultPromptVersion
+	}
+	if template, ok := promptTemplates[spec]; ok {
+		return spec, template, nil
+	}
+
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return "", "", fmt.Errorf("unknown prompt version %q (built-in: %s): %w", spec, strings.Join(PromptVersions(), ", "), err)
+	}
+	template = string(data)
+	if !strings.Contains(template, messagePlaceholder) {
+		return "", "", fmt.Errorf("prompt file %s does not contain %s placeholder", spec, messagePlaceholder)
+	}
+
+	version = strings.TrimSuffix(filepath.Base(spec), filepath.Ext(spec))
+	return version, template, nil
+}
+
+// PromptInstructions возвращает инструкции шаблона без строки с {{message}}.
+// Используется там, где сообщение передается отдельно, например в системном промте чат-формата.
+func PromptInstructions(template string) string {
+	lines := strings.Split(template, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if strings.Contains(line, messagePlaceholder) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
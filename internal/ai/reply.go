@@ -0,0 +1,65 @@
+package ai
+
+import "strings"
+
+// ContextMessage — сообщение, на которое отвечает анализируемое сообщение.
+type ContextMessage struct {
+	MessageID int64
+	Text      string
+}
+
+// AnalyzeOption задает дополнительные параметры анализа сообщения.
+type AnalyzeOption func(state *PipelineState)
+
+// WithReplyContext добавляет в промт извлечения цепочку сообщений, на которые отвечает автор, от самого раннего к последнему.
+// Прогнозы из контекста не извлекаются: он нужен, чтобы понять, о какой бумаге идет речь в ответе вроде "докупаем".
+func WithReplyContext(parents ...ContextMessage) AnalyzeOption {
+	return func(state *PipelineState) {
+		state.ReplyContext = append(state.ReplyContext, parents...)
+	}
+}
+
+// insertReplyContext вставляет блок контекста в шаблон промта перед строкой с {{message}}.
+func insertReplyContext(template string, parents []ContextMessage) string {
+	if len(parents) == 0 {
+		return template
+	}
+
+	var block strings.Builder
+	block.WriteString("Контекст: автор отвечает на сообщения ниже. Извлекай прогнозы только из сообщения, тикер можно взять из контекста.\n")
+	for _, parent := range parents {
+		for _, line := range strings.Split(strings.TrimSpace(parent.Text), "\n") {
+			block.WriteString("> ")
+			block.WriteString(line)
+			block.WriteString("\n")
+		}
+		block.WriteString("\n")
+	}
+
+	index := strings.Index(template, messagePlaceholder)
+	if index < 0 {
+		return template + "\n" + block.String()
+	}
+	lineStart := strings.LastIndex(template[:index], "\n") + 1
+	return template[:lineStart] + block.String() + template[lineStart:]
+}
+
+// markContextual помечает прогнозы, тикер которых не упоминается в сообщении, но есть в контексте ответа.
+func markContextual(predictions []FinancialPrediction, message string, parents []ContextMessage) {
+	for i := range predictions {
+		predictions[i].Contextual = false
+		if !containsTicker(message, predictions[i].Ticker) {
+			predictions[i].Contextual = contextContainsTicker(parents, predictions[i].Ticker)
+		}
+	}
+}
+
+// contextContainsTicker проверяет, что тикер упоминается в одном из сообщений контекста.
+func contextContainsTicker(parents []ContextMessage, ticker string) bool {
+	for _, parent := range parents {
+		if containsTicker(parent.Text, ticker) {
+			return true
+		}
+	}
+	return false
+}
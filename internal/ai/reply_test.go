@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReplyContext проверяет подстановку сообщений, на которые отвечает автор, и пометку тикеров из контекста
+func TestReplyContext(t *testing.T) {
+	var prompt string
+	client := &OllamaClient{
+		sendRequestFunc: func(ctx context.Context, p string) ([]byte, error) {
+			prompt = p
+			response := `[{"ticker": "SBER", "direction": "Лонг", "recommendation": "Покупать", "justification_text": "докупаем"},
+				{"ticker": "GAZP", "direction": "Лонг", "justification_text": "GAZP тоже держим"}]`
+			return json.Marshal(OllamaGenerateResponse{Response: response, Done: true})
+		},
+		steps: []PipelineStep{NewPredictionStep(), NewEvidenceStep(0.6, false)},
+	}
+
+	t.Run("Тикер из сообщения, на которое отвечает автор", func(t *testing.T) {
+		analysis, err := client.AnalyzeMessage(context.Background(), "докупаем, GAZP тоже держим", "1", 2,
+			WithReplyContext(ContextMessage{MessageID: 1, Text: "#SBER лонг от 250\nцель 290"}))
+
+		assert.NoError(t, err)
+		contextAt := strings.Index(prompt, "> #SBER лонг от 250\n> цель 290")
+		messageAt := strings.Index(prompt, "Сообщение: докупаем")
+		assert.True(t, contextAt > 0 && contextAt < messageAt)
+
+		assert.Len(t, analysis.Predictions, 2)
+		assert.True(t, analysis.Predictions[0].Contextual)
+		assert.Equal(t, EvidenceVerified, analysis.Predictions[0].Evidence.Status)
+		assert.False(t, analysis.Predictions[1].Contextual)
+	})
+
+	t.Run("Без контекста", func(t *testing.T) {
+		analysis, err := client.AnalyzeMessage(context.Background(), "докупаем, GAZP тоже держим", "1", 3)
+
+		assert.NoError(t, err)
+		assert.NotContains(t, prompt, "Контекст:")
+		assert.False(t, analysis.Predictions[0].Contextual)
+		assert.Equal(t, EvidenceUnverified, analysis.Predictions[0].Evidence.Status)
+	})
+}
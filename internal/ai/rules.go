@@ -0,0 +1,183 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SignalTemplate описывает шаблон сигнала фиксированного формата.
+// Pattern — регулярное выражение с именованными группами ticker, direction, recommendation, entry, stop, targets,
+// target_change_percent, period; отсутствующие группы оставляют поля прогноза пустыми.
+type SignalTemplate struct {
+	Name           string
+	Channels       []string // Каналы, для которых применяется шаблон; пустой список — все каналы
+	Pattern        string
+	PredictionType string // Тип прогноза по умолчанию для сигналов шаблона
+	Period         string // Период по умолчанию для сигналов шаблона
+}
+
+type compiledTemplate struct {
+	SignalTemplate
+	re       *regexp.Regexp
+	channels map[string]bool
+}
+
+// RuleExtractionStep реализует PipelineStep для детерминированного извлечения сигналов по шаблонам каналов.
+// Если совпадения шаблонов покрывают сообщение не меньше чем на minCoverage, вызов модели пропускается.
+type RuleExtractionStep struct {
+	templates   []compiledTemplate
+	minCoverage float64
+}
+
+// NewRuleExtractionStep компилирует шаблоны и создает шаг извлечения.
+func NewRuleExtractionStep(templates []SignalTemplate, minCoverage float64) (*RuleExtractionStep, error) {
+	step := &RuleExtractionStep{minCoverage: minCoverage}
+	for _, template := range templates {
+		re, err := regexp.Compile(template.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in signal template %q: %w", template.Name, err)
+		}
+		compiled := compiledTemplate{SignalTemplate: template, re: re}
+		if len(template.Channels) > 0 {
+			compiled.channels = make(map[string]bool, len(template.Channels))
+			for _, channel := range template.Channels {
+				compiled.channels[channel] = true
+			}
+		}
+		step.templates = append(step.templates, compiled)
+	}
+	return step, nil
+}
+
+// Process извлекает сигналы по шаблонам и помечает сообщение полностью разобранным при достаточном покрытии.
+func (s *RuleExtractionStep) Process(ctx context.Context, client *OllamaClient, state *PipelineState) error {
+	predictions, coverage := s.Extract(state.Message, state.Channel)
+	if len(predictions) == 0 {
+		return nil
+	}
+
+	for i := range predictions {
+		predictions[i].MessageID = state.MessageID
+	}
+	state.Predictions = append(state.Predictions, predictions...)
+
+	if coverage >= s.minCoverage {
+		state.FullyExtracted = true
+		log.Printf("Message %d fully matched signal templates (coverage %.2f), skipping LLM extraction", state.MessageID, coverage)
+	}
+	return nil
+}
+
+// Extract применяет шаблоны канала к сообщению. Возвращает найденные прогнозы
+// и долю букв и цифр сообщения, покрытых совпадениями.
+func (s *RuleExtractionStep) Extract(message, channel string) ([]FinancialPrediction, float64) {
+	var predictions []FinancialPrediction
+	covered := make([]bool, len(message))
+
+	for _, template := range s.templates {
+		if template.channels != nil && !template.channels[channel] {
+			continue
+		}
+		for _, match := range template.re.FindAllStringSubmatchIndex(message, -1) {
+			predictions = append(predictions, template.build(message, match))
+			for i := match[0]; i < match[1]; i++ {
+				covered[i] = true
+			}
+		}
+	}
+
+	return predictions, coverage(message, covered)
+}
+
+// build собирает прогноз из именованных групп совпадения.
+func (t compiledTemplate) build(message string, match []int) FinancialPrediction {
+	group := func(name string) string {
+		index := t.re.SubexpIndex(name)
+		if index < 0 || match[2*index] < 0 {
+			return ""
+		}
+		return strings.TrimSpace(message[match[2*index]:match[2*index+1]])
+	}
+
+	prediction := FinancialPrediction{
+		PredictionType:      t.PredictionType,
+		Ticker:              NormalizeTicker(group("ticker")),
+		Period:              t.Period,
+		Direction:           normalizeDirection(group("direction")),
+		Recommendation:      group("recommendation"),
+		JustificationText:   strings.TrimSpace(message[match[0]:match[1]]),
+		EntryPrice:          flexibleFromText(group("entry")),
+		StopLoss:            flexibleFromText(group("stop")),
+		TargetChangePercent: flexibleFromText(group("target_change_percent")),
+		TargetPrice:         FlexibleStringOrNumber{IsNull: true},
+		SelfRating:          FlexibleStringOrNumber{FloatValue: 1}, // Сигнал в формате канала всегда явный
+	}
+
+	if targets := group("targets"); targets != "" {
+		prediction.TakeProfitLevels = PriceLevels(parsePriceNumbers(targets))
+	}
+	if len(prediction.TakeProfitLevels) > 0 {
+		// Целевой ценой считается последняя, самая дальняя цель
+		prediction.TargetPrice = FlexibleStringOrNumber{FloatValue: prediction.TakeProfitLevels[len(prediction.TakeProfitLevels)-1]}
+	}
+
+	if prediction.Recommendation == "" {
+		switch prediction.Direction {
+		case "Лонг":
+			prediction.Recommendation = "Покупать"
+		case "Шорт":
+			prediction.Recommendation = "Продавать"
+		}
+	}
+
+	return prediction
+}
+
+// normalizeDirection приводит направление из шаблона к значениям, которые использует промт.
+func normalizeDirection(direction string) string {
+	switch strings.ToLower(direction) {
+	case "":
+		return ""
+	case "long", "лонг", "buy", "покупка":
+		return "Лонг"
+	case "short", "шорт", "sell", "продажа":
+		return "Шорт"
+	default:
+		return "Неопределенный"
+	}
+}
+
+// flexibleFromText превращает текст группы в FlexibleStringOrNumber: одно число — как число, диапазон — как строку.
+func flexibleFromText(text string) FlexibleStringOrNumber {
+	numbers := parsePriceNumbers(text)
+	switch len(numbers) {
+	case 0:
+		return FlexibleStringOrNumber{IsNull: true}
+	case 1:
+		return FlexibleStringOrNumber{FloatValue: numbers[0]}
+	default:
+		return FlexibleStringOrNumber{StringValue: text, IsString: true}
+	}
+}
+
+// coverage считает долю букв и цифр сообщения, попавших в совпадения шаблонов.
+func coverage(message string, covered []bool) float64 {
+	total, matched := 0, 0
+	for i, r := range message {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		total++
+		if covered[i] {
+			matched++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(matched) / float64(total)
+}
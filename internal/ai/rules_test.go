@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRuleExtractionStep проверяет извлечение сигналов по шаблонам канала
+func TestRuleExtractionStep(t *testing.T) {
+	template := SignalTemplate{
+		Name:           "hashtag-signal",
+		Channels:       []string{"42"},
+		Pattern:        `(?i)#(?P<ticker>[A-Z]{3,5})\s+(?P<direction>long|short)\s+вход\s+(?P<entry>[\d.,\-]+)(?:\s+стоп\s+(?P<stop>[\d.,]+))?\s+цел[ьи]\s+(?P<targets>[\d.,/]+)`,
+		PredictionType: "Продолжение тренда",
+	}
+	step, err := NewRuleExtractionStep([]SignalTemplate{template}, 0.9)
+	assert.NoError(t, err)
+
+	t.Run("Полное совпадение пропускает вызов модели", func(t *testing.T) {
+		client := &OllamaClient{
+			sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+				t.Fatal("LLM must not be called for fully matched messages")
+				return nil, nil
+			},
+			steps: []PipelineStep{step, NewPredictionStep()},
+		}
+
+		analysis, err := client.AnalyzeMessage(context.Background(), "#SBER LONG вход 250-255 стоп 240 цели 270/290", "42", 7)
+
+		assert.NoError(t, err)
+		assert.Len(t, analysis.Predictions, 1)
+		prediction := analysis.Predictions[0]
+		assert.Equal(t, int64(7), prediction.MessageID)
+		assert.Equal(t, "SBER", prediction.Ticker)
+		assert.Equal(t, "Лонг", prediction.Direction)
+		assert.Equal(t, "Покупать", prediction.Recommendation)
+		assert.Equal(t, "250-255", prediction.EntryPrice.String())
+		assert.Equal(t, 240.0, prediction.StopLoss.FloatValue)
+		assert.Equal(t, PriceLevels{270, 290}, prediction.TakeProfitLevels)
+		assert.Equal(t, 290.0, prediction.TargetPrice.FloatValue)
+	})
+
+	t.Run("Частичное совпадение дополняется моделью", func(t *testing.T) {
+		llmCalled := false
+		client := &OllamaClient{
+			sendRequestFunc: func(ctx context.Context, prompt string) ([]byte, error) {
+				llmCalled = true
+				return json.Marshal(OllamaGenerateResponse{Response: `[{"ticker": "SBER", "direction": "Лонг"}, {"ticker": "GAZP", "direction": "Лонг"}]`, Done: true})
+			},
+			steps: []PipelineStep{step, NewPredictionStep()},
+		}
+
+		message := "#SBER LONG вход 250 цель 270. Также присматриваемся к Газпрому на фоне дивидендов, возможен рост."
+		analysis, err := client.AnalyzeMessage(context.Background(), message, "42", 8)
+
+		assert.NoError(t, err)
+		assert.True(t, llmCalled)
+		assert.Len(t, analysis.Predictions, 2)
+		assert.Equal(t, 250.0, analysis.Predictions[0].EntryPrice.FloatValue)
+		assert.Equal(t, "GAZP", analysis.Predictions[1].Ticker)
+	})
+
+	t.Run("Шаблон другого канала не применяется", func(t *testing.T) {
+		predictions, coverage := step.Extract("#SBER LONG вход 250 цель 270", "100")
+
+		assert.Empty(t, predictions)
+		assert.Equal(t, 0.0, coverage)
+	})
+}
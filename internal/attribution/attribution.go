@@ -0,0 +1,310 @@
+package attribution
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Виды атрибуции сигнала.
+const (
+	KindOriginal = "original" // Канал опубликовал сигнал первым
+	KindForward  = "forward"  // Сообщение переслано из другого канала
+	KindCopy     = "copy"     // Тот же сигнал раньше опубликовал другой канал
+)
+
+// ForwardOrigin — источник пересланного сообщения из raw_data.
+type ForwardOrigin struct {
+	ChannelID int64
+	MessageID int64
+	Date      time.Time // Время публикации оригинала; нулевое, если неизвестно
+}
+
+// rawMessage описывает поля пересылки в raw_data: fwd_from из Telethon и forward_origin/forward_from_chat из Bot API.
+type rawMessage struct {
+	FwdFrom *struct {
+		FromID *struct {
+			ChannelID int64 `json:"channel_id"`
+		} `json:"from_id"`
+		ChannelPost int64           `json:"channel_post"`
+		Date        json.RawMessage `json:"date"`
+	} `json:"fwd_from"`
+	ForwardOrigin *struct {
+		Chat *struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		MessageID int64           `json:"message_id"`
+		Date      json.RawMessage `json:"date"`
+	} `json:"forward_origin"`
+	ForwardFromChat *struct {
+		ID int64 `json:"id"`
+	} `json:"forward_from_chat"`
+	ForwardFromMessageID int64           `json:"forward_from_message_id"`
+	ForwardDate          json.RawMessage `json:"forward_date"`
+}
+
+// ParseForward извлекает источник пересылки из raw_data сообщения.
+// Возвращает nil, если сообщение не переслано из канала или канал-источник скрыт.
+func ParseForward(raw []byte) (*ForwardOrigin, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var message rawMessage
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return nil, fmt.Errorf("failed to parse raw_data: %w", err)
+	}
+
+	var origin ForwardOrigin
+	var date json.RawMessage
+	switch {
+	case message.FwdFrom != nil && message.FwdFrom.FromID != nil && message.FwdFrom.FromID.ChannelID != 0:
+		origin.ChannelID = message.FwdFrom.FromID.ChannelID
+		origin.MessageID = message.FwdFrom.ChannelPost
+		date = message.FwdFrom.Date
+	case message.ForwardOrigin != nil && message.ForwardOrigin.Chat != nil:
+		origin.ChannelID = botChannelID(message.ForwardOrigin.Chat.ID)
+		origin.MessageID = message.ForwardOrigin.MessageID
+		date = message.ForwardOrigin.Date
+	case message.ForwardFromChat != nil:
+		origin.ChannelID = botChannelID(message.ForwardFromChat.ID)
+		origin.MessageID = message.ForwardFromMessageID
+		date = message.ForwardDate
+	default:
+		return nil, nil
+	}
+
+	parsed, err := parseDate(date)
+	if err != nil {
+		return nil, err
+	}
+	origin.Date = parsed
+	return &origin, nil
+}
+
+// botChannelID приводит идентификатор канала Bot API (-100XXXXXXXXXX) к идентификатору MTProto, который хранится в messages.
+func botChannelID(id int64) int64 {
+	if id < 0 {
+		id = -id
+	}
+	const botChannelPrefix = 1000000000000
+	if id > botChannelPrefix {
+		return id - botChannelPrefix
+	}
+	return id
+}
+
+// dateLayouts — форматы дат, которые встречаются в выгрузках Telethon.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// parseDate разбирает дату в виде unix-времени или строки.
+func parseDate(raw json.RawMessage) (time.Time, error) {
+	value := strings.TrimSpace(string(raw))
+	if value == "" || value == "null" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse forward date %s: %w", value, err)
+	}
+	for _, layout := range dateLayouts {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported forward date format: %q", text)
+}
+
+// Signal — прогноз канала, приведенный к виду, достаточному для сравнения с сигналами других каналов.
+type Signal struct {
+	ChannelID int64
+	MessageID int64
+	SentAt    time.Time
+	Ticker    string
+	Direction string
+	Target    float64 // Целевая цена или первая цель take-profit; 0, если не указана
+	Forward   *ForwardOrigin
+}
+
+// Attribution связывает сигнал с каналом, которому засчитывается его авторство.
+type Attribution struct {
+	Signal
+	Kind            string
+	SourceChannelID int64
+	SourceMessageID int64
+	Lag             time.Duration // Задержка относительно источника; 0 для оригиналов
+}
+
+// Attribute определяет первоисточник каждого сигнала. Пересланные сообщения засчитываются каналу из метаданных пересылки.
+// Сигнал с тем же тикером, направлением и целью, опубликованный другим каналом не позднее window после первого,
+// считается копией. Сигналы без направления или цели не сравниваются и всегда считаются оригинальными.
+func Attribute(signals []Signal, window time.Duration) []Attribution {
+	sorted := make([]Signal, len(signals))
+	copy(sorted, signals)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].SentAt.Before(sorted[j].SentAt) })
+
+	// Самый ранний сигнал в каждой группе одинаковых сигналов
+	first := make(map[string]Attribution)
+	attributions := make([]Attribution, 0, len(sorted))
+	for _, signal := range sorted {
+		attribution := Attribution{
+			Signal:          signal,
+			Kind:            KindOriginal,
+			SourceChannelID: signal.ChannelID,
+			SourceMessageID: signal.MessageID,
+		}
+
+		if signal.Forward != nil && signal.Forward.ChannelID != signal.ChannelID {
+			attribution.Kind = KindForward
+			attribution.SourceChannelID = signal.Forward.ChannelID
+			attribution.SourceMessageID = signal.Forward.MessageID
+			if !signal.Forward.Date.IsZero() {
+				attribution.Lag = signal.SentAt.Sub(signal.Forward.Date)
+			}
+		}
+
+		if key, ok := signalKey(signal); ok {
+			earliest, seen := first[key]
+			switch {
+			case !seen || signal.SentAt.Sub(earliest.SentAt) > window:
+				first[key] = attribution
+			case attribution.Kind == KindOriginal && earliest.SourceChannelID != signal.ChannelID:
+				attribution.Kind = KindCopy
+				attribution.SourceChannelID = earliest.SourceChannelID
+				attribution.SourceMessageID = earliest.SourceMessageID
+				attribution.Lag = signal.SentAt.Sub(earliest.SentAt) + earliest.Lag
+			}
+		}
+
+		attributions = append(attributions, attribution)
+	}
+	return attributions
+}
+
+// signalKey возвращает ключ для поиска одинаковых сигналов: тикер, направление и цель.
+func signalKey(signal Signal) (string, bool) {
+	ticker := ai.NormalizeTicker(signal.Ticker)
+	direction := strings.ToLower(strings.TrimSpace(signal.Direction))
+	if ticker == "" || direction == "" || signal.Target <= 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%s|%s|%.4f", ticker, direction, math.Round(signal.Target*10000)/10000), true
+}
+
+// ChannelRating — сводка по авторству сигналов канала.
+type ChannelRating struct {
+	ChannelID int64
+	Signals   int           // Всего сигналов канала
+	Original  int           // Собственные сигналы канала
+	Forwarded int           // Пересланные из других каналов
+	Copied    int           // Повторенные за другими каналами без пересылки
+	Credited  int           // Сигналы других каналов, засчитанные этому каналу как первоисточнику
+	CopyShare float64       // Доля пересланных и скопированных сигналов
+	MedianLag time.Duration // Типичная задержка относительно первоисточника
+	Copier    bool          // Доля заимствованных сигналов не ниже порога
+}
+
+// Rate собирает рейтинг каналов по атрибуции. Канал помечается как копирующий, если доля заимствованных
+// сигналов не ниже copierShare. Каналы-первоисточники, сигналы которых не собираются, тоже попадают в рейтинг.
+func Rate(attributions []Attribution, copierShare float64) []ChannelRating {
+	ratings := make(map[int64]*ChannelRating)
+	lags := make(map[int64][]time.Duration)
+	rating := func(channelID int64) *ChannelRating {
+		if r, ok := ratings[channelID]; ok {
+			return r
+		}
+		r := &ChannelRating{ChannelID: channelID}
+		ratings[channelID] = r
+		return r
+	}
+
+	for _, attribution := range attributions {
+		r := rating(attribution.ChannelID)
+		r.Signals++
+		switch attribution.Kind {
+		case KindForward:
+			r.Forwarded++
+		case KindCopy:
+			r.Copied++
+		default:
+			r.Original++
+			continue
+		}
+		if attribution.SourceChannelID != 0 {
+			rating(attribution.SourceChannelID).Credited++
+		}
+		lags[attribution.ChannelID] = append(lags[attribution.ChannelID], attribution.Lag)
+	}
+
+	result := make([]ChannelRating, 0, len(ratings))
+	for channelID, r := range ratings {
+		if r.Signals > 0 {
+			r.CopyShare = float64(r.Forwarded+r.Copied) / float64(r.Signals)
+			r.Copier = r.CopyShare >= copierShare
+		}
+		r.MedianLag = median(lags[channelID])
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Original+result[i].Credited != result[j].Original+result[j].Credited {
+			return result[i].Original+result[i].Credited > result[j].Original+result[j].Credited
+		}
+		return result[i].ChannelID < result[j].ChannelID
+	})
+	return result
+}
+
+func median(values []time.Duration) time.Duration {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// NewSignal приводит прогноз из базы к Signal. Ошибка разбора raw_data не мешает использовать сигнал:
+// сообщение с флагом is_forward считается пересланным из неизвестного источника.
+func NewSignal(row storage.ChannelSignal) (Signal, error) {
+	prediction := row.Prediction.Prediction
+	signal := Signal{
+		ChannelID: row.Message.ChannelID,
+		MessageID: row.Message.TelegramID,
+		SentAt:    row.Message.SentAt,
+		Ticker:    row.Prediction.Ticker,
+		Direction: prediction.Direction.String,
+	}
+	if prediction.TargetPrice.Valid {
+		signal.Target = prediction.TargetPrice.Float64
+	} else if len(prediction.TakeProfitLevels) > 0 {
+		signal.Target = prediction.TakeProfitLevels[0]
+	}
+
+	origin, err := ParseForward(row.Message.RawData)
+	if origin == nil && row.Message.IsForward.Valid && row.Message.IsForward.Bool {
+		origin = &ForwardOrigin{}
+	}
+	signal.Forward = origin
+	return signal, err
+}
@@ -0,0 +1,100 @@
+package attribution
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseForward проверяет разбор метаданных пересылки из raw_data
+func TestParseForward(t *testing.T) {
+	t.Run("Telethon", func(t *testing.T) {
+		raw := `{"id": 10, "fwd_from": {"_": "MessageFwdHeader", "from_id": {"_": "PeerChannel", "channel_id": 1234}, "channel_post": 55, "date": "2024-03-01 10:00:00+00:00"}}`
+
+		origin, err := ParseForward([]byte(raw))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1234), origin.ChannelID)
+		assert.Equal(t, int64(55), origin.MessageID)
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), origin.Date)
+	})
+
+	t.Run("Bot API", func(t *testing.T) {
+		raw := `{"forward_origin": {"type": "channel", "chat": {"id": -1001234567890}, "message_id": 55, "date": 1709287200}}`
+
+		origin, err := ParseForward([]byte(raw))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1234567890), origin.ChannelID)
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), origin.Date)
+	})
+
+	t.Run("Сообщение без пересылки", func(t *testing.T) {
+		origin, err := ParseForward([]byte(`{"id": 10, "fwd_from": null}`))
+		assert.NoError(t, err)
+		assert.Nil(t, origin)
+
+		origin, err = ParseForward(nil)
+		assert.NoError(t, err)
+		assert.Nil(t, origin)
+
+		_, err = ParseForward([]byte(`не JSON`))
+		assert.Error(t, err)
+	})
+}
+
+// TestAttribute проверяет атрибуцию пересылок и копий первоисточнику и рейтинг каналов
+func TestAttribute(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	signals := []Signal{
+		{ChannelID: 2, MessageID: 20, SentAt: start.Add(30 * time.Minute), Ticker: "#sber", Direction: "Лонг", Target: 290},
+		{ChannelID: 1, MessageID: 10, SentAt: start, Ticker: "SBER", Direction: "лонг", Target: 290},
+		{ChannelID: 3, MessageID: 30, SentAt: start.Add(2 * time.Hour), Ticker: "GAZP", Direction: "Шорт", Target: 120,
+			Forward: &ForwardOrigin{ChannelID: 1, MessageID: 11, Date: start.Add(time.Hour)}},
+		// Тот же сигнал за пределами окна считается новым
+		{ChannelID: 3, MessageID: 31, SentAt: start.Add(48 * time.Hour), Ticker: "SBER", Direction: "Лонг", Target: 290},
+		// Сигналы без цели не сравниваются
+		{ChannelID: 3, MessageID: 32, SentAt: start.Add(time.Hour), Ticker: "SBER", Direction: "Лонг"},
+	}
+
+	attributions := Attribute(signals, 6*time.Hour)
+
+	byMessage := make(map[int64]Attribution)
+	for _, a := range attributions {
+		byMessage[a.MessageID] = a
+	}
+	assert.Equal(t, KindOriginal, byMessage[10].Kind)
+	assert.Equal(t, KindCopy, byMessage[20].Kind)
+	assert.Equal(t, int64(1), byMessage[20].SourceChannelID)
+	assert.Equal(t, 30*time.Minute, byMessage[20].Lag)
+	assert.Equal(t, KindForward, byMessage[30].Kind)
+	assert.Equal(t, int64(11), byMessage[30].SourceMessageID)
+	assert.Equal(t, time.Hour, byMessage[30].Lag)
+	assert.Equal(t, KindOriginal, byMessage[31].Kind)
+	assert.Equal(t, KindOriginal, byMessage[32].Kind)
+
+	ratings := Rate(attributions, 0.5)
+
+	assert.Len(t, ratings, 3)
+	assert.Equal(t, int64(1), ratings[0].ChannelID)
+	assert.Equal(t, 2, ratings[0].Credited)
+	assert.False(t, ratings[0].Copier)
+
+	copier := ratings[2]
+	assert.Equal(t, int64(2), copier.ChannelID)
+	assert.True(t, copier.Copier)
+	assert.Equal(t, 1.0, copier.CopyShare)
+	assert.Equal(t, 30*time.Minute, copier.MedianLag)
+
+	forwarder := ratings[1]
+	assert.Equal(t, int64(3), forwarder.ChannelID)
+	assert.False(t, forwarder.Copier)
+	assert.InDelta(t, 1.0/3.0, forwarder.CopyShare, 1e-9)
+
+	// Префиксы тикера # и $ не различают одинаковые сигналы
+	key, ok := signalKey(Signal{Ticker: "$sber", Direction: "Лонг", Target: 290})
+	assert.True(t, ok)
+	plain, _ := signalKey(Signal{Ticker: "SBER", Direction: "лонг", Target: 290})
+	assert.Equal(t, plain, key)
+}
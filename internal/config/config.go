@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,6 +10,7 @@ import (
 type Config struct {
 	AI       AIConfig       `mapstructure:"ai"`
 	Database DatabaseConfig `mapstructure:"database"`
+	Retry    RetryConfig    `mapstructure:"retry"`
 }
 
 type AIConfig struct {
@@ -19,6 +21,109 @@ type AIConfig struct {
 	TopP          float64  `mapstructure:"top_p"`
 	MaxTokens     int      `mapstructure:"max_tokens"` // Соответствует num_predict в Ollama API
 	Stop          []string `mapstructure:"stop"`
+	PromptVersion string   `mapstructure:"prompt_version"` // Встроенная версия промта извлечения или путь к файлу шаблона
+	NumCtx        int      `mapstructure:"num_ctx"`        // Размер контекстного окна модели; 0 — значение модели по умолчанию
+
+	Evidence     EvidenceConfig     `mapstructure:"evidence"`
+	PreFilter    PreFilterConfig    `mapstructure:"prefilter"`
+	Rules        RulesConfig        `mapstructure:"rules"`
+	Ensemble     EnsembleConfig     `mapstructure:"ensemble"`
+	Confidence   ConfidenceConfig   `mapstructure:"confidence"`
+	FewShot      FewShotConfig      `mapstructure:"few_shot"`
+	Embeddings   EmbeddingsConfig   `mapstructure:"embeddings"`
+	ReplyContext ReplyContextConfig `mapstructure:"reply_context"`
+	Chunking     ChunkingConfig     `mapstructure:"chunking"`
+}
+
+// EvidenceConfig настраивает проверку того, что цитата и тикер прогноза присутствуют в исходном сообщении.
+type EvidenceConfig struct {
+	Enabled          bool    `mapstructure:"enabled"`
+	MinQuoteScore    float64 `mapstructure:"min_quote_score"`   // Минимальная доля слов цитаты, найденных в сообщении
+	RejectUnverified bool    `mapstructure:"reject_unverified"` // Отбрасывать частично подтвержденные прогнозы вместо понижения
+}
+
+// PreFilterConfig настраивает дешевый отсев сообщений без торговых сигналов перед основным промтом.
+type PreFilterConfig struct {
+	Enabled    bool     `mapstructure:"enabled"`
+	UseLLM     bool     `mapstructure:"use_llm"`     // Дополнительная короткая классификация да/нет моделью
+	AdPatterns []string `mapstructure:"ad_patterns"` // Регулярные выражения рекламных сообщений
+}
+
+// RulesConfig настраивает детерминированное извлечение сигналов по шаблонам каналов.
+type RulesConfig struct {
+	MinCoverage float64          `mapstructure:"min_coverage"` // Доля сообщения, покрытая шаблонами, при которой вызов модели пропускается
+	Templates   []TemplateConfig `mapstructure:"templates"`
+}
+
+// TemplateConfig описывает шаблон сигнала: регулярное выражение с именованными группами
+// ticker, direction, recommendation, entry, stop, targets, target_change_percent, period.
+type TemplateConfig struct {
+	Name           string   `mapstructure:"name"`
+	Channels       []string `mapstructure:"channels"` // ID каналов; пустой список — все каналы
+	Pattern        string   `mapstructure:"pattern"`
+	PredictionType string   `mapstructure:"prediction_type"`
+	Period         string   `mapstructure:"period"`
+}
+
+// EnsembleConfig настраивает извлечение ансамблем: несколько моделей или несколько выборок одной модели
+// с голосованием большинством по полям прогноза.
+type EnsembleConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Models       []string `mapstructure:"models"`        // Модели ансамбля; пустой список — выборки основной модели
+	Samples      int      `mapstructure:"samples"`       // Число выборок основной модели, если models не задан
+	Temperature  float64  `mapstructure:"temperature"`   // Температура выборок; должна быть ненулевой для self-consistency
+	MinAgreement float64  `mapstructure:"min_agreement"` // Прогнозы с меньшим согласием уходят на ручную проверку
+}
+
+// ConfidenceConfig настраивает расчет итоговой уверенности прогноза и порог отправки на ручную проверку.
+type ConfidenceConfig struct {
+	Enabled       bool                    `mapstructure:"enabled"`
+	MinConfidence float64                 `mapstructure:"min_confidence"` // Прогнозы с меньшей уверенностью уходят на ручную проверку
+	Weights       ConfidenceWeightsConfig `mapstructure:"weights"`
+}
+
+// ConfidenceWeightsConfig задает вклад составляющих в уверенность прогноза.
+type ConfidenceWeightsConfig struct {
+	SelfRating float64 `mapstructure:"self_rating"`
+	Evidence   float64 `mapstructure:"evidence"`
+	Ticker     float64 `mapstructure:"ticker"`
+	Agreement  float64 `mapstructure:"agreement"`
+}
+
+// FewShotConfig настраивает подстановку в промт похожих примеров из библиотеки.
+type FewShotConfig struct {
+	Enabled       bool    `mapstructure:"enabled"`
+	Path          string  `mapstructure:"path"`           // JSONL-файл с примерами {"text": ..., "answer": [...]}
+	MaxExamples   int     `mapstructure:"max_examples"`   // Максимальное число примеров в промте
+	MaxTokens     int     `mapstructure:"max_tokens"`     // Бюджет токенов на блок примеров
+	MinSimilarity float64 `mapstructure:"min_similarity"` // Минимальная близость примера к сообщению
+}
+
+// EmbeddingsConfig настраивает расчет эмбеддингов сообщений и поиск почти дословных повторов.
+type EmbeddingsConfig struct {
+	Enabled            bool    `mapstructure:"enabled"`
+	Model              string  `mapstructure:"model"`               // Модель эмбеддингов Ollama
+	DuplicateThreshold float64 `mapstructure:"duplicate_threshold"` // Косинусная близость, начиная с которой сообщение считается повтором
+	WindowHours        int     `mapstructure:"window_hours"`        // Глубина поиска исходного сообщения в часах
+}
+
+// ReplyContextConfig настраивает добавление в промт сообщений, на которые отвечает автор.
+type ReplyContextConfig struct {
+	Enabled  bool `mapstructure:"enabled"`
+	MaxDepth int  `mapstructure:"max_depth"` // Сколько сообщений цепочки ответов подставлять
+}
+
+// ChunkingConfig настраивает анализ длинных сообщений по фрагментам.
+type ChunkingConfig struct {
+	Enabled          bool `mapstructure:"enabled"`
+	MaxMessageTokens int  `mapstructure:"max_message_tokens"` // Длина фрагмента в токенах; 0 — рассчитать по num_ctx
+}
+
+// RetryConfig настраивает повторный анализ сообщений из analysis_failures.
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"` // Число попыток, после которого сообщение больше не повторяется
+	BaseDelay   time.Duration `mapstructure:"base_delay"`   // Задержка после первой неудачи; удваивается с каждой следующей
+	MaxDelay    time.Duration `mapstructure:"max_delay"`    // Максимальная задержка между попытками
 }
 
 type DatabaseConfig struct {
@@ -31,7 +136,18 @@ type DatabaseConfig struct {
 	ConnectionString string `mapstructure:"connection_string"`
 }
 
+// Load загружает конфигурацию из файла configPath и переменных окружения и проверяет ее, включая настройки базы данных.
 func Load(configPath string) (*Config, error) {
+	return load(configPath, true)
+}
+
+// LoadWithoutDatabase загружает конфигурацию, как Load, но не требует настроек базы данных: для команд,
+// которые к ней не подключаются.
+func LoadWithoutDatabase(configPath string) (*Config, error) {
+	return load(configPath, false)
+}
+
+func load(configPath string, requireDatabase bool) (*Config, error) {
 	// Устанавливаем значения по умолчанию
 	viper.SetDefault("ai.ollama_base_url", "http://localhost:11434")
 	viper.SetDefault("ai.ollama_model", "llama2")
@@ -40,6 +156,39 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("ai.top_p", 0.9)
 	viper.SetDefault("ai.max_tokens", 2048)
 	viper.SetDefault("ai.stop", []string{})
+	viper.SetDefault("ai.prompt_version", "v1")
+	viper.SetDefault("ai.num_ctx", 0)
+	viper.SetDefault("ai.evidence.enabled", true)
+	viper.SetDefault("ai.evidence.min_quote_score", 0.6)
+	viper.SetDefault("ai.evidence.reject_unverified", false)
+	viper.SetDefault("ai.prefilter.enabled", true)
+	viper.SetDefault("ai.prefilter.use_llm", false)
+	viper.SetDefault("ai.prefilter.ad_patterns", []string{})
+	viper.SetDefault("ai.rules.min_coverage", 0.9)
+	viper.SetDefault("ai.ensemble.enabled", false)
+	viper.SetDefault("ai.ensemble.models", []string{})
+	viper.SetDefault("ai.ensemble.samples", 3)
+	viper.SetDefault("ai.ensemble.temperature", 0.7)
+	viper.SetDefault("ai.ensemble.min_agreement", 0.6)
+	viper.SetDefault("ai.confidence.enabled", true)
+	viper.SetDefault("ai.confidence.min_confidence", 0.5)
+	viper.SetDefault("ai.confidence.weights.self_rating", 0.3)
+	viper.SetDefault("ai.confidence.weights.evidence", 0.3)
+	viper.SetDefault("ai.confidence.weights.ticker", 0.2)
+	viper.SetDefault("ai.confidence.weights.agreement", 0.2)
+	viper.SetDefault("ai.few_shot.enabled", false)
+	viper.SetDefault("ai.few_shot.path", "")
+	viper.SetDefault("ai.few_shot.max_examples", 3)
+	viper.SetDefault("ai.few_shot.max_tokens", 1024)
+	viper.SetDefault("ai.few_shot.min_similarity", 0.1)
+	viper.SetDefault("ai.embeddings.enabled", false)
+	viper.SetDefault("ai.embeddings.model", "nomic-embed-text")
+	viper.SetDefault("ai.embeddings.duplicate_threshold", 0.95)
+	viper.SetDefault("ai.embeddings.window_hours", 72)
+	viper.SetDefault("ai.reply_context.enabled", true)
+	viper.SetDefault("ai.reply_context.max_depth", 2)
+	viper.SetDefault("ai.chunking.enabled", true)
+	viper.SetDefault("ai.chunking.max_message_tokens", 0)
 
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
@@ -48,6 +197,10 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("database.dbname", "tg_reader") // Используем tg_reader как базу по умолчанию
 	viper.SetDefault("database.sslmode", "disable")
 
+	viper.SetDefault("retry.max_attempts", 5)
+	viper.SetDefault("retry.base_delay", "5m")
+	viper.SetDefault("retry.max_delay", "6h")
+
 	// Читаем конфигурацию из указанного файла
 	viper.SetConfigFile(configPath)
 
@@ -74,6 +227,11 @@ func Load(configPath string) (*Config, error) {
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
+	if requireDatabase {
+		if err := validateDatabase(&config.Database); err != nil {
+			return nil, fmt.Errorf("config validation failed: %w", err)
+		}
+	}
 
 	return &config, nil
 }
@@ -86,6 +244,27 @@ func bindEnvs() {
 	viper.BindEnv("ai.top_p", "TRADING_AI_TOP_P")
 	viper.BindEnv("ai.max_tokens", "TRADING_AI_MAX_TOKENS")
 	viper.BindEnv("ai.stop", "TRADING_AI_STOP")
+	viper.BindEnv("ai.prompt_version", "TRADING_AI_PROMPT_VERSION")
+	viper.BindEnv("ai.num_ctx", "TRADING_AI_NUM_CTX")
+	viper.BindEnv("ai.evidence.enabled", "TRADING_AI_EVIDENCE_ENABLED")
+	viper.BindEnv("ai.evidence.min_quote_score", "TRADING_AI_EVIDENCE_MIN_QUOTE_SCORE")
+	viper.BindEnv("ai.evidence.reject_unverified", "TRADING_AI_EVIDENCE_REJECT_UNVERIFIED")
+	viper.BindEnv("ai.prefilter.enabled", "TRADING_AI_PREFILTER_ENABLED")
+	viper.BindEnv("ai.prefilter.use_llm", "TRADING_AI_PREFILTER_USE_LLM")
+	viper.BindEnv("ai.ensemble.enabled", "TRADING_AI_ENSEMBLE_ENABLED")
+	viper.BindEnv("ai.ensemble.models", "TRADING_AI_ENSEMBLE_MODELS")
+	viper.BindEnv("ai.ensemble.samples", "TRADING_AI_ENSEMBLE_SAMPLES")
+	viper.BindEnv("ai.ensemble.min_agreement", "TRADING_AI_ENSEMBLE_MIN_AGREEMENT")
+	viper.BindEnv("ai.confidence.enabled", "TRADING_AI_CONFIDENCE_ENABLED")
+	viper.BindEnv("ai.confidence.min_confidence", "TRADING_AI_CONFIDENCE_MIN_CONFIDENCE")
+	viper.BindEnv("ai.few_shot.enabled", "TRADING_AI_FEW_SHOT_ENABLED")
+	viper.BindEnv("ai.few_shot.path", "TRADING_AI_FEW_SHOT_PATH")
+	viper.BindEnv("ai.embeddings.enabled", "TRADING_AI_EMBEDDINGS_ENABLED")
+	viper.BindEnv("ai.embeddings.model", "TRADING_AI_EMBEDDINGS_MODEL")
+	viper.BindEnv("ai.embeddings.duplicate_threshold", "TRADING_AI_EMBEDDINGS_DUPLICATE_THRESHOLD")
+	viper.BindEnv("ai.reply_context.enabled", "TRADING_AI_REPLY_CONTEXT_ENABLED")
+	viper.BindEnv("ai.chunking.enabled", "TRADING_AI_CHUNKING_ENABLED")
+	viper.BindEnv("ai.chunking.max_message_tokens", "TRADING_AI_CHUNKING_MAX_MESSAGE_TOKENS")
 
 	viper.BindEnv("database.host", "TRADING_DATABASE_HOST")
 	viper.BindEnv("database.port", "TRADING_DATABASE_PORT")
@@ -93,27 +272,87 @@ func bindEnvs() {
 	viper.BindEnv("database.password", "TRADING_DATABASE_PASSWORD")
 	viper.BindEnv("database.dbname", "TRADING_DATABASE_DBNAME")
 	viper.BindEnv("database.sslmode", "TRADING_DATABASE_SSLMODE")
+
+	viper.BindEnv("retry.max_attempts", "TRADING_RETRY_MAX_ATTEMPTS")
+	viper.BindEnv("retry.base_delay", "TRADING_RETRY_BASE_DELAY")
+	viper.BindEnv("retry.max_delay", "TRADING_RETRY_MAX_DELAY")
 }
 
 func validateConfig(config *Config) error {
-	// Проверяем конфигурацию базы данных
-	if config.Database.Host == "" {
+	for _, template := range config.AI.Rules.Templates {
+		if template.Pattern == "" {
+			return fmt.Errorf("signal template %q has empty pattern", template.Name)
+		}
+	}
+
+	if config.AI.Evidence.MinQuoteScore < 0 || config.AI.Evidence.MinQuoteScore > 1 {
+		return fmt.Errorf("ai.evidence.min_quote_score must be between 0 and 1")
+	}
+
+	if config.AI.Ensemble.Enabled {
+		if len(config.AI.Ensemble.Models) == 0 && config.AI.Ensemble.Samples < 2 {
+			return fmt.Errorf("ai.ensemble requires at least two models or samples")
+		}
+		if config.AI.Ensemble.MinAgreement < 0 || config.AI.Ensemble.MinAgreement > 1 {
+			return fmt.Errorf("ai.ensemble.min_agreement must be between 0 and 1")
+		}
+	}
+
+	if config.AI.Confidence.MinConfidence < 0 || config.AI.Confidence.MinConfidence > 1 {
+		return fmt.Errorf("ai.confidence.min_confidence must be between 0 and 1")
+	}
+
+	if config.AI.FewShot.Enabled && config.AI.FewShot.Path == "" {
+		return fmt.Errorf("ai.few_shot.path is required when few-shot examples are enabled")
+	}
+
+	if config.AI.Embeddings.Enabled {
+		if config.AI.Embeddings.Model == "" {
+			return fmt.Errorf("ai.embeddings.model is required when embeddings are enabled")
+		}
+		if config.AI.Embeddings.DuplicateThreshold <= 0 || config.AI.Embeddings.DuplicateThreshold > 1 {
+			return fmt.Errorf("ai.embeddings.duplicate_threshold must be in (0, 1]")
+		}
+	}
+
+	if config.AI.ReplyContext.Enabled && config.AI.ReplyContext.MaxDepth <= 0 {
+		return fmt.Errorf("ai.reply_context.max_depth must be positive when reply context is enabled")
+	}
+
+	if config.AI.Chunking.MaxMessageTokens < 0 {
+		return fmt.Errorf("ai.chunking.max_message_tokens cannot be negative")
+	}
+
+	if config.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry.max_attempts must be at least 1")
+	}
+	// retry.max_delay: 0 снимает ограничение задержки
+	if config.Retry.BaseDelay <= 0 || config.Retry.MaxDelay > 0 && config.Retry.MaxDelay < config.Retry.BaseDelay {
+		return fmt.Errorf("retry.base_delay must be positive and not greater than retry.max_delay")
+	}
+
+	return nil
+}
+
+// validateDatabase проверяет конфигурацию базы данных.
+func validateDatabase(database *DatabaseConfig) error {
+	if database.Host == "" {
 		return fmt.Errorf("database host is required")
 	}
 
-	if config.Database.Port == 0 {
+	if database.Port == 0 {
 		return fmt.Errorf("database port is required")
 	}
 
-	if config.Database.User == "" {
+	if database.User == "" {
 		return fmt.Errorf("database user is required")
 	}
 
-	if config.Database.Password == "" {
+	if database.Password == "" {
 		return fmt.Errorf("database password is required")
 	}
 
-	if config.Database.DBName == "" {
+	if database.DBName == "" {
 		return fmt.Errorf("database name is required")
 	}
 
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadWithoutDatabase проверяет, что для работы без базы данных ее настройки не нужны
+func TestLoadWithoutDatabase(t *testing.T) {
+	t.Setenv("TRADING_DATABASE_PASSWORD", "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("ai:\n  ollama_model: \"qwen2.5:7b\"\n"), 0o644))
+
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "database password is required")
+
+	cfg, err := LoadWithoutDatabase(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "qwen2.5:7b", cfg.AI.OllamaModel)
+		assert.Empty(t, cfg.Database.Password)
+	}
+}
@@ -0,0 +1,230 @@
+package dataset
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Sample — проверенное сообщение и исправленный ответ модели для дообучения.
+// Пустой Answer означает, что сигналов в сообщении нет.
+type Sample struct {
+	ID      string
+	Channel string
+	Text    string
+	Answer  []Answer
+}
+
+// Answer — прогноз в том виде, в котором его должна вернуть модель по промту извлечения.
+type Answer struct {
+	Ticker              string    `json:"ticker"`
+	PredictionType      *string   `json:"prediction_type"`
+	Period              *string   `json:"period"`
+	TargetPrice         *float64  `json:"target_price"`
+	TargetChangePercent *float64  `json:"target_change_percent"`
+	EntryPrice          any       `json:"entry_price"` // Число или диапазон строкой "250-255"
+	StopLoss            *float64  `json:"stop_loss"`
+	TakeProfitLevels    []float64 `json:"take_profit_levels"`
+	Recommendation      *string   `json:"recommendation"`
+	Direction           *string   `json:"direction"`
+	JustificationText   *string   `json:"justification_text"`
+}
+
+// ChatMessage — сообщение диалога в чат-формате дообучения.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Record — строка JSONL-файла: системный промт, сообщение канала и ожидаемый ответ.
+type Record struct {
+	Messages []ChatMessage `json:"messages"`
+}
+
+// FromReviewed преобразует проверенные сообщения в примеры. Ответ составляют прогнозы, принятые проверяющими;
+// пустой ответ получает только сообщение, в котором проверяющий подтвердил отсутствие сигналов. Отклонение прогноза
+// означает лишь, что он извлечен неверно, поэтому сообщения без принятых прогнозов и без такого подтверждения,
+// как и сообщения с противоречивыми решениями, пропускаются. Сообщения без текста тоже пропускаются.
+func FromReviewed(messages []storage.ReviewedMessage) []Sample {
+	samples := make([]Sample, 0, len(messages))
+	for _, reviewed := range messages {
+		if strings.TrimSpace(reviewed.Message.Text.String) == "" {
+			continue
+		}
+		if reviewed.NoSignal == (len(reviewed.Predictions) > 0) {
+			continue
+		}
+
+		sample := Sample{
+			ID:      fmt.Sprintf("%d:%d", reviewed.Message.ChannelID, reviewed.Message.TelegramID),
+			Channel: strconv.FormatInt(reviewed.Message.ChannelID, 10),
+			Text:    reviewed.Message.Text.String,
+			Answer:  []Answer{},
+		}
+		for _, item := range reviewed.Predictions {
+			sample.Answer = append(sample.Answer, newAnswer(item))
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+func newAnswer(item storage.StockPrediction) Answer {
+	p := item.Prediction
+	answer := Answer{
+		Ticker:              item.Ticker,
+		PredictionType:      nullString(p.PredictionType.String, p.PredictionType.Valid),
+		Period:              nullString(p.Period.String, p.Period.Valid),
+		TargetPrice:         nullFloat(p.TargetPrice.Float64, p.TargetPrice.Valid),
+		TargetChangePercent: nullFloat(p.TargetChangePercent.Float64, p.TargetChangePercent.Valid),
+		StopLoss:            nullFloat(p.StopLoss.Float64, p.StopLoss.Valid),
+		TakeProfitLevels:    []float64(p.TakeProfitLevels),
+		Recommendation:      nullString(p.Recommendation.String, p.Recommendation.Valid),
+		Direction:           nullString(p.Direction.String, p.Direction.Valid),
+		JustificationText:   nullString(p.JustificationText.String, p.JustificationText.Valid),
+	}
+	if len(answer.TakeProfitLevels) == 0 {
+		answer.TakeProfitLevels = nil
+	}
+	if p.EntryPriceMin.Valid && p.EntryPriceMax.Valid {
+		if p.EntryPriceMin.Float64 == p.EntryPriceMax.Float64 {
+			answer.EntryPrice = p.EntryPriceMin.Float64
+		} else {
+			answer.EntryPrice = fmt.Sprintf("%s-%s", formatFloat(p.EntryPriceMin.Float64), formatFloat(p.EntryPriceMax.Float64))
+		}
+	}
+	return answer
+}
+
+// Dedup удаляет примеры с одинаковым текстом сообщения (без учета регистра и пробелов), оставляя первый.
+func Dedup(samples []Sample) []Sample {
+	seen := make(map[string]bool, len(samples))
+	unique := make([]Sample, 0, len(samples))
+	for _, sample := range samples {
+		key := strings.Join(strings.Fields(strings.ToLower(sample.Text)), " ")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, sample)
+	}
+	return unique
+}
+
+// Stratify оставляет не более perChannel случайных примеров каждого канала, чтобы крупные каналы
+// не доминировали в наборе. perChannel <= 0 отключает ограничение. Порядок каналов детерминирован.
+func Stratify(samples []Sample, perChannel int, seed int64) []Sample {
+	if perChannel <= 0 {
+		return samples
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	var result []Sample
+	for _, group := range byChannel(samples) {
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		if len(group) > perChannel {
+			group = group[:perChannel]
+		}
+		result = append(result, group...)
+	}
+	return result
+}
+
+// Split делит примеры на обучающую и валидационную выборки в заданной пропорции отдельно внутри каждого канала.
+// В канале с одним-единственным примером он попадает в обучающую выборку.
+func Split(samples []Sample, validationRatio float64, seed int64) (train, validation []Sample) {
+	rng := rand.New(rand.NewSource(seed))
+	for _, group := range byChannel(samples) {
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		count := int(float64(len(group))*validationRatio + 0.5)
+		if count >= len(group) {
+			count = len(group) - 1
+		}
+		validation = append(validation, group[:count]...)
+		train = append(train, group[count:]...)
+	}
+	return train, validation
+}
+
+// byChannel группирует копии примеров по каналу в порядке возрастания идентификатора канала.
+func byChannel(samples []Sample) [][]Sample {
+	groups := make(map[string][]Sample)
+	var channels []string
+	for _, sample := range samples {
+		if _, ok := groups[sample.Channel]; !ok {
+			channels = append(channels, sample.Channel)
+		}
+		groups[sample.Channel] = append(groups[sample.Channel], sample)
+	}
+	sort.Strings(channels)
+
+	result := make([][]Sample, 0, len(channels))
+	for _, channel := range channels {
+		result = append(result, groups[channel])
+	}
+	return result
+}
+
+// NewRecord формирует запись чат-формата: системный промт, текст сообщения и ответ в JSON.
+func NewRecord(systemPrompt string, sample Sample) (Record, error) {
+	answer, err := json.Marshal(sample.Answer)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to marshal answer for sample %s: %w", sample.ID, err)
+	}
+	return Record{Messages: []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: sample.Text},
+		{Role: "assistant", Content: string(answer)},
+	}}, nil
+}
+
+// WriteJSONL записывает примеры в файл, по одной записи чат-формата на строку.
+func WriteJSONL(path, systemPrompt string, samples []Sample) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	for _, sample := range samples {
+		record, err := NewRecord(systemPrompt, sample)
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write sample %s to %s: %w", sample.ID, path, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func nullString(value string, valid bool) *string {
+	if !valid {
+		return nil
+	}
+	return &value
+}
+
+func nullFloat(value float64, valid bool) *float64 {
+	if !valid {
+		return nil
+	}
+	return &value
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
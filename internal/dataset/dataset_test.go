@@ -0,0 +1,88 @@
+package dataset
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFromReviewed проверяет формирование ответа модели из проверенных прогнозов
+func TestFromReviewed(t *testing.T) {
+	reviewed := []storage.ReviewedMessage{
+		{
+			Message: storage.Message{TelegramID: 1, ChannelID: 100, Text: sql.NullString{String: "#SBER лонг 250-255, цели 270/290", Valid: true}},
+			Predictions: []storage.StockPrediction{{
+				Ticker: "SBER",
+				Prediction: storage.Prediction{
+					Direction:        sql.NullString{String: "Лонг", Valid: true},
+					EntryPriceMin:    sql.NullFloat64{Float64: 250, Valid: true},
+					EntryPriceMax:    sql.NullFloat64{Float64: 255, Valid: true},
+					TakeProfitLevels: pq.Float64Array{270, 290},
+				},
+			}},
+		},
+		{Message: storage.Message{TelegramID: 2, ChannelID: 100, Text: sql.NullString{String: "Всем хороших выходных", Valid: true}}, NoSignal: true},
+		{Message: storage.Message{TelegramID: 3, ChannelID: 100}, NoSignal: true},
+		// Все прогнозы отклонены, но отсутствие сигналов не подтверждено: ответ неизвестен
+		{Message: storage.Message{TelegramID: 4, ChannelID: 100, Text: sql.NullString{String: "GAZP шорт", Valid: true}}},
+		// Противоречивые решения: прогноз принят, но отмечено, что сигналов нет
+		{
+			Message:     storage.Message{TelegramID: 5, ChannelID: 100, Text: sql.NullString{String: "LKOH лонг", Valid: true}},
+			Predictions: []storage.StockPrediction{{Ticker: "LKOH"}},
+			NoSignal:    true,
+		},
+	}
+
+	samples := FromReviewed(reviewed)
+
+	if !assert.Len(t, samples, 2) {
+		return
+	}
+	assert.Equal(t, "100:1", samples[0].ID)
+	assert.Equal(t, "100:2", samples[1].ID)
+
+	record, err := NewRecord("system", samples[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "system", record.Messages[0].Content)
+	assert.Equal(t, "user", record.Messages[1].Role)
+	assert.JSONEq(t, `[{"ticker":"SBER","prediction_type":null,"period":null,"target_price":null,"target_change_percent":null,
+		"entry_price":"250-255","stop_loss":null,"take_profit_levels":[270,290],"recommendation":null,"direction":"Лонг","justification_text":null}]`,
+		record.Messages[2].Content)
+
+	// Сообщение, в котором проверяющий подтвердил отсутствие сигналов, дает пустой массив
+	record, err = NewRecord("system", samples[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", record.Messages[2].Content)
+}
+
+// TestSampling проверяет дедупликацию, выборку по каналам и разбиение на train/validation
+func TestSampling(t *testing.T) {
+	var samples []Sample
+	for i := 0; i < 10; i++ {
+		samples = append(samples, Sample{ID: fmt.Sprintf("a%d", i), Channel: "a", Text: fmt.Sprintf("сигнал %d", i)})
+	}
+	samples = append(samples,
+		Sample{ID: "a-dup", Channel: "a", Text: "  СИГНАЛ   0 "},
+		Sample{ID: "b0", Channel: "b", Text: "одиночный"},
+	)
+
+	unique := Dedup(samples)
+	assert.Len(t, unique, 11)
+
+	stratified := Stratify(unique, 4, 1)
+	assert.Len(t, stratified, 5)
+
+	train, validation := Split(stratified, 0.25, 1)
+	assert.Len(t, validation, 1)
+	assert.Len(t, train, 4)
+	assert.Equal(t, "a", validation[0].Channel)
+
+	// Разбиение воспроизводимо при одинаковом seed
+	_, again := Split(Stratify(unique, 4, 1), 0.25, 1)
+	assert.Equal(t, validation, again)
+}
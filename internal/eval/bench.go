@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+)
+
+// Variant — комбинация модели и версии промта, участвующая в сравнении.
+type Variant struct {
+	Model         string
+	PromptVersion string
+	Analyzer      Analyzer
+}
+
+// Name возвращает обозначение варианта в таблицах: "модель@промт".
+func (v Variant) Name() string {
+	return v.Model + "@" + v.PromptVersion
+}
+
+// VariantAgreement — доля совпадающих прогнозов двух вариантов, усредненная по сообщениям.
+type VariantAgreement struct {
+	A     string  `json:"a"`
+	B     string  `json:"b"`
+	Score float64 `json:"score"`
+}
+
+// BenchReport — результаты прогона одного набора сообщений через несколько вариантов.
+type BenchReport struct {
+	Dataset    string             `json:"dataset"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Variants   []*Report          `json:"variants"`
+	Agreement  []VariantAgreement `json:"agreement"`
+}
+
+// Bench последовательно прогоняет сообщения через все варианты и считает попарное согласие между ними.
+func Bench(ctx context.Context, variants []Variant, examples []Example) *BenchReport {
+	bench := &BenchReport{StartedAt: time.Now()}
+
+	for _, variant := range variants {
+		log.Printf("Benchmarking %s on %d messages", variant.Name(), len(examples))
+		report := Run(ctx, variant.Analyzer, examples)
+		report.Model = variant.Model
+		report.PromptVersion = variant.PromptVersion
+		bench.Variants = append(bench.Variants, report)
+	}
+
+	for i := 0; i < len(bench.Variants); i++ {
+		for j := i + 1; j < len(bench.Variants); j++ {
+			bench.Agreement = append(bench.Agreement, VariantAgreement{
+				A:     variants[i].Name(),
+				B:     variants[j].Name(),
+				Score: Agreement(bench.Variants[i], bench.Variants[j]),
+			})
+		}
+	}
+
+	bench.FinishedAt = time.Now()
+	return bench
+}
+
+// Agreement считает среднюю по сообщениям меру Жаккара между наборами пар (тикер, направление) двух отчетов.
+// Сообщения, где оба варианта не нашли прогнозов, считаются полностью согласованными.
+func Agreement(a, b *Report) float64 {
+	if len(a.Results) == 0 || len(a.Results) != len(b.Results) {
+		return 0
+	}
+
+	total := 0.0
+	for i := range a.Results {
+		total += jaccard(predictionKeys(a.Results[i]), predictionKeys(b.Results[i]))
+	}
+	return total / float64(len(a.Results))
+}
+
+func predictionKeys(result ExampleResult) map[string]bool {
+	keys := make(map[string]bool, len(result.Predicted))
+	for _, prediction := range result.Predicted {
+		ticker := ai.NormalizeTicker(prediction.Ticker)
+		if ticker == "" {
+			continue
+		}
+		keys[ticker+"|"+prediction.Direction] = true
+	}
+	return keys
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	intersection := 0
+	for key := range a {
+		if b[key] {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
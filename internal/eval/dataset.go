@@ -0,0 +1,62 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"rkata-ai/trade-radar/internal/ai"
+)
+
+// Example — размеченное сообщение из эталонного набора: текст и ожидаемые прогнозы.
+// Пустой Expected ("expected": []) означает, что сообщение не содержит сигналов;
+// отсутствие поля expected — что сообщение не размечено и участвует только в сравнении моделей.
+type Example struct {
+	ID       string                   `json:"id"`
+	Channel  string                   `json:"channel"`
+	Text     string                   `json:"text"`
+	Expected []ai.FinancialPrediction `json:"expected"`
+}
+
+// Labeled сообщает, есть ли у сообщения эталонная разметка.
+func (e Example) Labeled() bool {
+	return e.Expected != nil
+}
+
+// LoadDataset читает эталонный набор в формате JSONL: одно размеченное сообщение на строку.
+// Пустые строки и строки, начинающиеся с "#", пропускаются.
+func LoadDataset(path string) ([]Example, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	var examples []Example
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var example Example
+		if err := json.Unmarshal([]byte(text), &example); err != nil {
+			return nil, fmt.Errorf("failed to parse dataset line %d: %w", line, err)
+		}
+		if example.ID == "" {
+			example.ID = fmt.Sprintf("line-%d", line)
+		}
+		examples = append(examples, example)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	return examples, nil
+}
@@ -0,0 +1,159 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+)
+
+// Analyzer — часть AI-клиента, которую использует оценка. Реализуется ai.OllamaClient.
+type Analyzer interface {
+	AnalyzeMessage(ctx context.Context, message, channel string, messageID int64, opts ...ai.AnalyzeOption) (*ai.MessageAnalysis, error)
+}
+
+// ExampleResult — результат анализа одного сообщения из набора.
+type ExampleResult struct {
+	ID           string                   `json:"id"`
+	LatencyMs    float64                  `json:"latency_ms"`
+	Error        string                   `json:"error,omitempty"`
+	ParseFailure bool                     `json:"parse_failure,omitempty"`
+	Skipped      bool                     `json:"skipped,omitempty"`
+	SkipReason   string                   `json:"skip_reason,omitempty"`
+	Labeled      bool                     `json:"labeled"`
+	ExactMatch   bool                     `json:"exact_match"`
+	Fields       map[string]FieldScore    `json:"fields,omitempty"`
+	Predicted    []ai.FinancialPrediction `json:"predicted"`
+	Usage        ai.Usage                 `json:"usage"`
+}
+
+// Report — машиночитаемый отчет об оценке качества извлечения, пригодный для сравнения запусков.
+type Report struct {
+	Dataset          string                `json:"dataset"`
+	Model            string                `json:"model"`
+	PromptVersion    string                `json:"prompt_version"`
+	StartedAt        time.Time             `json:"started_at"`
+	FinishedAt       time.Time             `json:"finished_at"`
+	Examples         int                   `json:"examples"`
+	Labeled          int                   `json:"labeled"`
+	ExactMatchRate   float64               `json:"exact_match_rate"` // Доля точных совпадений среди размеченных сообщений
+	ParseFailures    int                   `json:"parse_failures"`
+	ParseFailureRate float64               `json:"parse_failure_rate"`
+	Errors           int                   `json:"errors"` // Прочие ошибки: недоступность Ollama, таймауты и т.п.
+	FailureRate      float64               `json:"failure_rate"`
+	Skipped          int                   `json:"skipped"`
+	SkippedWithGold  int                   `json:"skipped_with_gold"` // Ложные пропуски предварительного фильтра
+	Fields           map[string]FieldScore `json:"fields"`
+	Latency          LatencyStats          `json:"latency"`
+	Usage            ai.Usage              `json:"usage"`
+	TokensPerSecond  float64               `json:"tokens_per_second"`
+	Results          []ExampleResult       `json:"results"`
+}
+
+// Run прогоняет эталонный набор через анализатор и считает метрики.
+// Ошибка ErrNoPredictions считается пустым ответом, ErrInvalidResponse — сбоем разбора.
+func Run(ctx context.Context, analyzer Analyzer, examples []Example) *Report {
+	report := &Report{
+		StartedAt: time.Now(),
+		Examples:  len(examples),
+		Fields:    make(map[string]FieldScore, len(Fields)),
+	}
+
+	var latencies []time.Duration
+	exactMatches := 0
+	for idx, example := range examples {
+		log.Printf("Evaluating example %d/%d (ID: %s)", idx+1, len(examples), example.ID)
+
+		result := ExampleResult{ID: example.ID}
+		start := time.Now()
+		analysis, err := analyzer.AnalyzeMessage(ctx, example.Text, example.Channel, int64(idx+1))
+		elapsed := time.Since(start)
+		latencies = append(latencies, elapsed)
+		result.LatencyMs = milliseconds(elapsed)
+
+		switch {
+		case err == nil:
+			result.Predicted = analysis.Predictions
+			result.Skipped = analysis.Skipped
+			result.SkipReason = analysis.SkipReason
+			result.Usage = analysis.Usage
+			report.Usage.Add(analysis.Usage)
+		case errors.Is(err, ai.ErrNoPredictions):
+		case errors.Is(err, ai.ErrInvalidResponse):
+			result.ParseFailure = true
+			result.Error = err.Error()
+			report.ParseFailures++
+		default:
+			result.Error = err.Error()
+			report.Errors++
+		}
+
+		if result.Skipped {
+			report.Skipped++
+			if len(example.Expected) > 0 {
+				report.SkippedWithGold++
+			}
+		}
+
+		if !example.Labeled() {
+			report.Results = append(report.Results, result)
+			continue
+		}
+		report.Labeled++
+		result.Labeled = true
+
+		result.Fields = make(map[string]FieldScore, len(Fields))
+		result.ExactMatch = true
+		for _, field := range Fields {
+			score := ScoreField(result.Predicted, example.Expected, field)
+			result.Fields[field] = score
+			result.ExactMatch = result.ExactMatch && score.Exact()
+
+			total := report.Fields[field]
+			total.Add(score)
+			report.Fields[field] = total
+		}
+		if result.ExactMatch {
+			exactMatches++
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	for field, score := range report.Fields {
+		score.Finalize()
+		report.Fields[field] = score
+	}
+	for i := range report.Results {
+		for field, score := range report.Results[i].Fields {
+			score.Finalize()
+			report.Results[i].Fields[field] = score
+		}
+	}
+
+	report.ExactMatchRate = ratio(exactMatches, report.Labeled)
+	report.ParseFailureRate = ratio(report.ParseFailures, len(examples))
+	report.FailureRate = ratio(report.ParseFailures+report.Errors, len(examples))
+	report.TokensPerSecond = report.Usage.TokensPerSecond()
+	report.Latency = NewLatencyStats(latencies)
+	report.FinishedAt = time.Now()
+
+	return report
+}
+
+// WriteReport сохраняет отчет в JSON-файл.
+func WriteReport(report any, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal eval report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write eval report to %s: %w", path, err)
+	}
+	return nil
+}
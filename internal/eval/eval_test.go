@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"rkata-ai/trade-radar/internal/ai"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAnalyzer возвращает заранее заданные результаты по тексту сообщения.
+type fakeAnalyzer struct {
+	results map[string][]ai.FinancialPrediction
+	errors  map[string]error
+}
+
+func (f *fakeAnalyzer) AnalyzeMessage(ctx context.Context, message, channel string, messageID int64, opts ...ai.AnalyzeOption) (*ai.MessageAnalysis, error) {
+	if err, ok := f.errors[message]; ok {
+		return nil, err
+	}
+	return &ai.MessageAnalysis{Predictions: f.results[message]}, nil
+}
+
+// TestRun проверяет расчет метрик по эталонному набору
+func TestRun(t *testing.T) {
+	examples, err := LoadDataset("testdata/gold.jsonl")
+	assert.NoError(t, err)
+	assert.Len(t, examples, 3)
+
+	target := func(v float64) ai.FlexibleStringOrNumber { return ai.FlexibleStringOrNumber{FloatValue: v} }
+
+	analyzer := &fakeAnalyzer{
+		results: map[string][]ai.FinancialPrediction{
+			// Полностью верный ответ (цель в пределах допуска 1%)
+			examples[0].Text: {{Ticker: "#sber", Direction: "Лонг", Recommendation: "Покупать", TargetPrice: target(290.5)}},
+			// Неверное направление по GAZP, пропущен LKOH
+			examples[1].Text: {{Ticker: "GAZP", Direction: "Лонг", Recommendation: "Продавать", TargetPrice: target(120)}},
+		},
+		errors: map[string]error{
+			examples[2].Text: fmt.Errorf("pipeline %w", ai.ErrNoPredictions),
+		},
+	}
+
+	report := Run(context.Background(), analyzer, examples)
+
+	assert.Equal(t, 3, report.Examples)
+	assert.InDelta(t, 2.0/3.0, report.ExactMatchRate, 1e-9)
+	assert.Equal(t, 0, report.ParseFailures)
+	assert.Equal(t, 0, report.Errors)
+
+	ticker := report.Fields[FieldTicker]
+	assert.Equal(t, 2, ticker.TruePositives)
+	assert.Equal(t, 0, ticker.FalsePositives)
+	assert.Equal(t, 1, ticker.FalseNegatives)
+	assert.Equal(t, 1.0, ticker.Precision)
+	assert.InDelta(t, 2.0/3.0, ticker.Recall, 1e-9)
+	assert.InDelta(t, 0.8, ticker.F1, 1e-9)
+
+	direction := report.Fields[FieldDirection]
+	assert.Equal(t, 1, direction.TruePositives)
+	assert.Equal(t, 1, direction.FalsePositives)
+	assert.Equal(t, 1, direction.FalseNegatives)
+
+	targetScore := report.Fields[FieldTarget]
+	assert.Equal(t, 2, targetScore.TruePositives)
+	assert.Equal(t, 0, targetScore.FalseNegatives)
+
+	assert.True(t, report.Results[0].ExactMatch)
+	assert.False(t, report.Results[1].ExactMatch)
+	assert.True(t, report.Results[2].ExactMatch)
+}
+
+// TestRun_ParseFailure проверяет учет сбоев разбора ответа модели
+func TestRun_ParseFailure(t *testing.T) {
+	examples := []Example{{ID: "broken", Text: "SBER", Expected: []ai.FinancialPrediction{{Ticker: "SBER"}}}}
+	analyzer := &fakeAnalyzer{errors: map[string]error{"SBER": fmt.Errorf("%w: invalid JSON response from Ollama", ai.ErrInvalidResponse)}}
+
+	report := Run(context.Background(), analyzer, examples)
+
+	assert.Equal(t, 1, report.ParseFailures)
+	assert.Equal(t, 1.0, report.ParseFailureRate)
+	assert.Equal(t, 1, report.Fields[FieldTicker].FalseNegatives)
+	assert.True(t, report.Results[0].ParseFailure)
+}
+
+// TestBench проверяет сравнение вариантов и расчет согласия между ними
+func TestBench(t *testing.T) {
+	examples := []Example{
+		{ID: "1", Text: "SBER лонг"},
+		{ID: "2", Text: "GAZP шорт, LKOH лонг"},
+	}
+	first := &fakeAnalyzer{results: map[string][]ai.FinancialPrediction{
+		"SBER лонг":            {{Ticker: "SBER", Direction: "Лонг"}},
+		"GAZP шорт, LKOH лонг": {{Ticker: "GAZP", Direction: "Шорт"}, {Ticker: "LKOH", Direction: "Лонг"}},
+	}}
+	second := &fakeAnalyzer{results: map[string][]ai.FinancialPrediction{
+		"SBER лонг":            {{Ticker: "SBER", Direction: "Лонг"}},
+		"GAZP шорт, LKOH лонг": {{Ticker: "GAZP", Direction: "Шорт"}},
+	}}
+
+	bench := Bench(context.Background(), []Variant{
+		{Model: "gemma3:1b", PromptVersion: "v1", Analyzer: first},
+		{Model: "qwen2.5:7b", PromptVersion: "v1", Analyzer: second},
+	}, examples)
+
+	assert.Len(t, bench.Variants, 2)
+	assert.Equal(t, 0, bench.Variants[0].Labeled)
+	assert.Equal(t, "qwen2.5:7b", bench.Variants[1].Model)
+	assert.Len(t, bench.Agreement, 1)
+	assert.Equal(t, "gemma3:1b@v1", bench.Agreement[0].A)
+	assert.InDelta(t, (1.0+0.5)/2, bench.Agreement[0].Score, 1e-9)
+}
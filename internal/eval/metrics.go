@@ -0,0 +1,205 @@
+package eval
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+)
+
+// Поля прогноза, по которым считаются метрики качества.
+const (
+	FieldTicker         = "ticker"
+	FieldDirection      = "direction"
+	FieldRecommendation = "recommendation"
+	FieldTarget         = "target"
+)
+
+// Fields перечисляет оцениваемые поля в порядке вывода отчета.
+var Fields = []string{FieldTicker, FieldDirection, FieldRecommendation, FieldTarget}
+
+// targetTolerance — допустимое относительное расхождение целевой цены.
+const targetTolerance = 0.01
+
+// FieldScore накапливает совпадения по одному полю и хранит итоговые precision/recall/F1.
+type FieldScore struct {
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+}
+
+// Add суммирует счетчики другого результата.
+func (s *FieldScore) Add(other FieldScore) {
+	s.TruePositives += other.TruePositives
+	s.FalsePositives += other.FalsePositives
+	s.FalseNegatives += other.FalseNegatives
+}
+
+// Finalize рассчитывает precision, recall и F1 по накопленным счетчикам.
+func (s *FieldScore) Finalize() {
+	s.Precision = ratio(s.TruePositives, s.TruePositives+s.FalsePositives)
+	s.Recall = ratio(s.TruePositives, s.TruePositives+s.FalseNegatives)
+	if s.Precision+s.Recall > 0 {
+		s.F1 = 2 * s.Precision * s.Recall / (s.Precision + s.Recall)
+	}
+}
+
+// Exact сообщает, что по полю нет ни лишних, ни пропущенных значений.
+func (s FieldScore) Exact() bool {
+	return s.FalsePositives == 0 && s.FalseNegatives == 0
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// fieldItem — значение поля, привязанное к тикеру.
+type fieldItem struct {
+	ticker string
+	value  string
+	low    float64
+	high   float64
+}
+
+// extractItems собирает уникальные значения поля из прогнозов; пустые значения не учитываются.
+func extractItems(predictions []ai.FinancialPrediction, field string) []fieldItem {
+	var items []fieldItem
+	seen := make(map[string]bool)
+	for _, prediction := range predictions {
+		ticker := ai.NormalizeTicker(prediction.Ticker)
+		if ticker == "" {
+			continue
+		}
+
+		item := fieldItem{ticker: ticker}
+		switch field {
+		case FieldTicker:
+		case FieldDirection:
+			item.value = strings.TrimSpace(prediction.Direction)
+		case FieldRecommendation:
+			item.value = strings.TrimSpace(prediction.Recommendation)
+		case FieldTarget:
+			low, high, ok := prediction.TargetPrice.Range()
+			if !ok {
+				continue
+			}
+			item.low, item.high = low, high
+		}
+		if field != FieldTicker && field != FieldTarget && item.value == "" {
+			continue
+		}
+
+		key := item.ticker + "|" + item.value
+		if field == FieldTarget {
+			key += "|" + prediction.TargetPrice.String()
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+// itemsEqual сравнивает значения поля: строки без учета регистра, цены — с допуском targetTolerance.
+func itemsEqual(field string, a, b fieldItem) bool {
+	if a.ticker != b.ticker {
+		return false
+	}
+	if field == FieldTarget {
+		return closeEnough(a.low, b.low) && closeEnough(a.high, b.high)
+	}
+	return strings.EqualFold(a.value, b.value)
+}
+
+func closeEnough(a, b float64) bool {
+	if a == b {
+		return true
+	}
+	return math.Abs(a-b) <= targetTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// ScoreField сравнивает предсказанные и ожидаемые прогнозы по одному полю, сопоставляя значения один к одному.
+func ScoreField(predicted, expected []ai.FinancialPrediction, field string) FieldScore {
+	predictedItems := extractItems(predicted, field)
+	expectedItems := extractItems(expected, field)
+
+	var score FieldScore
+	matched := make([]bool, len(expectedItems))
+	for _, p := range predictedItems {
+		found := false
+		for i, e := range expectedItems {
+			if !matched[i] && itemsEqual(field, p, e) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if found {
+			score.TruePositives++
+		} else {
+			score.FalsePositives++
+		}
+	}
+	for _, m := range matched {
+		if !m {
+			score.FalseNegatives++
+		}
+	}
+	return score
+}
+
+// LatencyStats — сводка по длительности анализа сообщений в миллисекундах.
+type LatencyStats struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// NewLatencyStats рассчитывает среднее и перцентили по списку длительностей.
+func NewLatencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	return LatencyStats{
+		MeanMs: milliseconds(total / time.Duration(len(sorted))),
+		P50Ms:  milliseconds(percentile(sorted, 0.50)),
+		P90Ms:  milliseconds(percentile(sorted, 0.90)),
+		P99Ms:  milliseconds(percentile(sorted, 0.99)),
+		MaxMs:  milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile возвращает значение перцентиля p (0..1) для отсортированного среза методом ближайшего ранга.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
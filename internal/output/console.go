@@ -0,0 +1,79 @@
+package output
+
+import (
+	"context"
+	"log"
+)
+
+// ConsoleSink выводит прогнозы в лог в читаемом виде.
+type ConsoleSink struct {
+	logger         *log.Logger
+	showConfidence bool // Выводить итоговую уверенность; включается вместе с ai.confidence
+	count          int
+}
+
+// NewConsoleSink создает приемник, печатающий прогнозы через logger.
+func NewConsoleSink(logger *log.Logger, showConfidence bool) *ConsoleSink {
+	return &ConsoleSink{logger: logger, showConfidence: showConfidence}
+}
+
+// Write печатает прогнозы сообщения. Пропущенные сообщения не печатаются: причина пропуска уже есть в логе анализа.
+func (c *ConsoleSink) Write(ctx context.Context, result Result) error {
+	c.count++
+	if result.Analysis.Skipped {
+		return nil
+	}
+
+	c.logger.Printf("\n### Message %d ###\n", c.count)
+	if len(result.Analysis.Predictions) == 0 {
+		c.logger.Printf("  No financial predictions available for message %d.\n", c.count)
+		return nil
+	}
+	for i, prediction := range result.Analysis.Predictions {
+		// Проверяем, что Ticker не пустой и PredictionType не 'Неопределенный' перед выводом в консоль
+		if !Reportable(prediction) {
+			c.logger.Printf("Prediction for message %d with ticker '%s' and type '%s' ignored (empty ticker or 'Неопределенный' type). Skipping console output.", prediction.MessageID, prediction.Ticker, prediction.PredictionType)
+			continue
+		}
+		c.logger.Printf("--- Prediction %d ---", i+1)
+		c.logger.Printf("  Message ID: %d", prediction.MessageID)
+		c.logger.Printf("  Prediction Type: %s", prediction.PredictionType)
+		if prediction.Contextual {
+			c.logger.Printf("  Ticker: %s (from reply context)", prediction.Ticker)
+		} else {
+			c.logger.Printf("  Ticker: %s", prediction.Ticker)
+		}
+		c.logger.Printf("  Target Price: %s", prediction.TargetPrice.String())
+		c.logger.Printf("  Target Change Percent: %s", prediction.TargetChangePercent.String())
+		c.logger.Printf("  Entry Price: %s", prediction.EntryPrice.String())
+		c.logger.Printf("  Stop Loss: %s", prediction.StopLoss.String())
+		c.logger.Printf("  Take Profit Levels: %s", prediction.TakeProfitLevels.String())
+		if rr, ok := prediction.RiskReward(); ok {
+			c.logger.Printf("  Risk/Reward: %.2f", rr)
+		}
+		c.logger.Printf("  Period: %s", prediction.Period)
+		c.logger.Printf("  Recommendation: %s", prediction.Recommendation)
+		c.logger.Printf("  Direction: %s", prediction.Direction)
+		if prediction.Evidence != nil {
+			c.logger.Printf("  Evidence: %s (quote score %.2f, ticker found %t, span %d-%d)", prediction.Evidence.Status, prediction.Evidence.QuoteScore, prediction.Evidence.TickerFound, prediction.Evidence.SpanStart, prediction.Evidence.SpanEnd)
+		}
+		if c.showConfidence {
+			c.logger.Printf("  Confidence: %.2f", prediction.Confidence)
+		}
+		if prediction.EnsembleSize > 0 {
+			c.logger.Printf("  Agreement: %.2f (%d members)", prediction.Agreement, prediction.EnsembleSize)
+		}
+		c.logger.Printf("  Justification Text: %s\n", prediction.JustificationText)
+	}
+	return nil
+}
+
+// Flush ничего не делает: вывод в лог не буферизуется.
+func (c *ConsoleSink) Flush() error {
+	return nil
+}
+
+// Close ничего не делает.
+func (c *ConsoleSink) Close() error {
+	return nil
+}
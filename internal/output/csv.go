@@ -0,0 +1,120 @@
+package output
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"rkata-ai/trade-radar/internal/ai"
+)
+
+// csvHeader — колонки CSV-файла: одна строка на прогноз.
+var csvHeader = []string{
+	"channel", "message_id", "skip_reason", "ticker", "contextual", "prediction_type", "direction",
+	"target_price", "target_change_percent", "entry_price", "stop_loss", "take_profit_levels",
+	"period", "recommendation", "evidence_status", "confidence", "agreement", "justification_text",
+}
+
+// CSVSink записывает прогнозы в CSV: одна строка на прогноз. Для пропущенного сообщения пишется строка
+// с причиной пропуска и пустыми полями прогноза, сообщения без прогнозов не записываются.
+type CSVSink struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewCSVSink создает файл path, перезаписывая существующий, и записывает строку заголовка.
+func NewCSVSink(path string) (*CSVSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	c := &CSVSink{file: file, writer: csv.NewWriter(file)}
+	if err := c.writer.Write(csvHeader); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write csv header: %w", err)
+	}
+	return c, nil
+}
+
+// Write добавляет строки прогнозов сообщения.
+func (c *CSVSink) Write(ctx context.Context, result Result) error {
+	channel := result.Channel()
+	messageID := strconv.FormatInt(result.Message.TelegramID, 10)
+
+	if result.Analysis.Skipped {
+		row := make([]string, len(csvHeader))
+		row[0], row[1], row[2] = channel, messageID, result.Analysis.SkipReason
+		if err := c.writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write csv row for message %s: %w", messageID, err)
+		}
+		return nil
+	}
+
+	for _, prediction := range result.Analysis.Predictions {
+		if !Reportable(prediction) {
+			continue
+		}
+		if err := c.writer.Write(csvRow(channel, messageID, prediction)); err != nil {
+			return fmt.Errorf("failed to write csv row for message %s: %w", messageID, err)
+		}
+	}
+	return nil
+}
+
+func csvRow(channel, messageID string, prediction ai.FinancialPrediction) []string {
+	var evidenceStatus string
+	if prediction.Evidence != nil {
+		evidenceStatus = prediction.Evidence.Status
+	}
+	var agreement string
+	if prediction.EnsembleSize > 0 {
+		agreement = strconv.FormatFloat(prediction.Agreement, 'f', 2, 64)
+	}
+	var confidence string
+	if prediction.Confidence > 0 {
+		confidence = strconv.FormatFloat(prediction.Confidence, 'f', 2, 64)
+	}
+	return []string{
+		channel,
+		messageID,
+		"",
+		prediction.Ticker,
+		strconv.FormatBool(prediction.Contextual),
+		prediction.PredictionType,
+		prediction.Direction,
+		prediction.TargetPrice.String(),
+		prediction.TargetChangePercent.String(),
+		prediction.EntryPrice.String(),
+		prediction.StopLoss.String(),
+		prediction.TakeProfitLevels.String(),
+		prediction.Period,
+		prediction.Recommendation,
+		evidenceStatus,
+		confidence,
+		agreement,
+		prediction.JustificationText,
+	}
+}
+
+// Flush сбрасывает буфер CSV и данные файла на диск.
+func (c *CSVSink) Flush() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush csv output: %w", err)
+	}
+	if err := c.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	return nil
+}
+
+// Close сбрасывает данные на диск и закрывает файл.
+func (c *CSVSink) Close() error {
+	flushErr := c.Flush()
+	if err := c.file.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	return flushErr
+}
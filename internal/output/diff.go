@@ -0,0 +1,285 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/review"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// diffFields — поля прогноза, сравниваемые с сохраненными, в порядке вывода.
+var diffFields = []string{
+	review.FieldDirection, review.FieldRecommendation, review.FieldPredictionType, review.FieldPeriod,
+	review.FieldTargetPrice, review.FieldTargetChangePercent, review.FieldTakeProfitLevels,
+	review.FieldEntryPrice, review.FieldStopLoss,
+}
+
+// targetFields — поля, изменение которых считается изменением целей.
+var targetFields = map[string]bool{
+	review.FieldTargetPrice:         true,
+	review.FieldTargetChangePercent: true,
+	review.FieldTakeProfitLevels:    true,
+}
+
+// FieldChange — изменение одного поля прогноза. Пустое значение означает, что поле не заполнено.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// PredictionChange — изменения прогноза по тикеру, который есть и в сохраненных, и в новых прогнозах.
+type PredictionChange struct {
+	Ticker  string        `json:"ticker"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// MessageDiff — отличия нового анализа сообщения от сохраненных прогнозов.
+type MessageDiff struct {
+	ChannelID int64              `json:"channel_id"`
+	MessageID int64              `json:"message_id"`
+	Added     []string           `json:"added,omitempty"`   // Тикеры, которых нет среди сохраненных прогнозов
+	Removed   []string           `json:"removed,omitempty"` // Сохраненные тикеры, которых нет в новом анализе
+	Changed   []PredictionChange `json:"changed,omitempty"`
+}
+
+// Empty сообщает, что новый анализ совпадает с сохраненным.
+func (d MessageDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffReport — итог сравнения нового анализа с сохраненными прогнозами.
+type DiffReport struct {
+	Messages         int           `json:"messages"`
+	Unchanged        int           `json:"unchanged"`
+	AddedTickers     int           `json:"added_tickers"`
+	RemovedTickers   int           `json:"removed_tickers"`
+	ChangedDirection int           `json:"changed_direction"`
+	ChangedTargets   int           `json:"changed_targets"`
+	Diffs            []MessageDiff `json:"diffs"`
+}
+
+// Add учитывает отличия одного сообщения в итогах отчета.
+func (r *DiffReport) Add(diff MessageDiff) {
+	r.Messages++
+	if diff.Empty() {
+		r.Unchanged++
+		return
+	}
+	r.AddedTickers += len(diff.Added)
+	r.RemovedTickers += len(diff.Removed)
+	for _, change := range diff.Changed {
+		targets := false
+		for _, field := range change.Changes {
+			if field.Field == review.FieldDirection {
+				r.ChangedDirection++
+			}
+			targets = targets || targetFields[field.Field]
+		}
+		if targets {
+			r.ChangedTargets++
+		}
+	}
+	r.Diffs = append(r.Diffs, diff)
+}
+
+// CompareMessage сравнивает новые прогнозы сообщения с сохраненными по тикеру и полям diffFields.
+// Учитываются только прогнозы, прошедшие Reportable; тикеры сравниваются без префиксов # и $ и без учета регистра.
+func CompareMessage(message storage.Message, stored []storage.StockPrediction, fresh []ai.FinancialPrediction) MessageDiff {
+	diff := MessageDiff{ChannelID: message.ChannelID, MessageID: message.TelegramID}
+
+	old := make(map[string]map[string]string, len(stored))
+	for _, item := range stored {
+		old[ai.NormalizeTicker(item.Ticker)] = storedValues(item.Prediction)
+	}
+	seen := make(map[string]bool, len(fresh))
+	for _, prediction := range fresh {
+		if !Reportable(prediction) {
+			continue
+		}
+		ticker := ai.NormalizeTicker(prediction.Ticker)
+		if seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+
+		oldValues, ok := old[ticker]
+		if !ok {
+			diff.Added = append(diff.Added, ticker)
+			continue
+		}
+		newValues := freshValues(prediction)
+		var changes []FieldChange
+		for _, field := range diffFields {
+			if oldValues[field] != newValues[field] {
+				changes = append(changes, FieldChange{Field: field, Old: oldValues[field], New: newValues[field]})
+			}
+		}
+		if len(changes) > 0 {
+			diff.Changed = append(diff.Changed, PredictionChange{Ticker: ticker, Changes: changes})
+		}
+	}
+	for ticker := range old {
+		if !seen[ticker] {
+			diff.Removed = append(diff.Removed, ticker)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// storedValues возвращает сравниваемые поля сохраненного прогноза в виде строк.
+func storedValues(p storage.Prediction) map[string]string {
+	return map[string]string{
+		review.FieldDirection:           p.Direction.String,
+		review.FieldRecommendation:      p.Recommendation.String,
+		review.FieldPredictionType:      p.PredictionType.String,
+		review.FieldPeriod:              p.Period.String,
+		review.FieldTargetPrice:         formatNullFloat(p.TargetPrice.Float64, p.TargetPrice.Valid),
+		review.FieldTargetChangePercent: formatNullFloat(p.TargetChangePercent.Float64, p.TargetChangePercent.Valid),
+		review.FieldTakeProfitLevels:    formatLevels(p.TakeProfitLevels),
+		review.FieldEntryPrice:          formatRange(p.EntryPriceMin.Float64, p.EntryPriceMax.Float64, p.EntryPriceMin.Valid),
+		review.FieldStopLoss:            formatNullFloat(p.StopLoss.Float64, p.StopLoss.Valid),
+	}
+}
+
+// freshValues возвращает сравниваемые поля нового прогноза так, как их сохранил бы PostgresSink.
+func freshValues(p ai.FinancialPrediction) map[string]string {
+	entryMin, entryMax, hasEntry := p.EntryPrice.Range()
+	stopLoss, _, hasStopLoss := p.StopLoss.Range()
+	return map[string]string{
+		review.FieldDirection:           p.Direction,
+		review.FieldRecommendation:      p.Recommendation,
+		review.FieldPredictionType:      p.PredictionType,
+		review.FieldPeriod:              p.Period,
+		review.FieldTargetPrice:         formatNullFloat(p.TargetPrice.FloatValue, !p.TargetPrice.IsNull && !p.TargetPrice.IsString),
+		review.FieldTargetChangePercent: formatNullFloat(p.TargetChangePercent.FloatValue, !p.TargetChangePercent.IsNull && !p.TargetChangePercent.IsString),
+		review.FieldTakeProfitLevels:    formatLevels(p.TakeProfitLevels),
+		review.FieldEntryPrice:          formatRange(entryMin, entryMax, hasEntry),
+		review.FieldStopLoss:            formatNullFloat(stopLoss, hasStopLoss),
+	}
+}
+
+func formatNullFloat(value float64, valid bool) string {
+	if !valid {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatRange(low, high float64, valid bool) string {
+	if !valid {
+		return ""
+	}
+	if low == high {
+		return formatNullFloat(low, true)
+	}
+	return formatNullFloat(low, true) + "-" + formatNullFloat(high, true)
+}
+
+func formatLevels(levels []float64) string {
+	values := make([]string, len(levels))
+	for i, level := range levels {
+		values[i] = formatNullFloat(level, true)
+	}
+	return strings.Join(values, "/")
+}
+
+// DiffSink ничего не сохраняет: он сравнивает каждый результат анализа с действующими прогнозами сообщения
+// в базе, печатает отличия в w и при закрытии печатает итоги и, если задан путь, сохраняет отчет в JSON.
+type DiffSink struct {
+	storage    storage.Storage
+	opts       PostgresOptions // Пороги приемника db: с ними новые прогнозы делятся на predictions и raw_predictions
+	w          io.Writer
+	reportPath string
+	report     DiffReport
+}
+
+// NewDiffSink создает приемник сравнения. opts — параметры приемника db, результаты которого сравниваются.
+// Пустой reportPath — отчет только печатается.
+func NewDiffSink(storage storage.Storage, opts PostgresOptions, w io.Writer, reportPath string) *DiffSink {
+	return &DiffSink{storage: storage, opts: opts, w: w, reportPath: reportPath, report: DiffReport{Diffs: []MessageDiff{}}}
+}
+
+// Write сравнивает результат с сохраненными прогнозами сообщения. С ними сравниваются только новые прогнозы,
+// которые приемник db сохранил бы в predictions, под тикером из справочника; прогнозы для ручной проверки
+// не учитываются. Пропущенное сообщение сравнивается как сообщение без прогнозов.
+func (d *DiffSink) Write(ctx context.Context, result Result) error {
+	message := result.Message
+	stored, err := d.storage.GetPredictions(ctx, message.ChannelID, message.TelegramID, storage.LatestRun)
+	if err != nil {
+		return fmt.Errorf("failed to get stored predictions for message %d: %w", message.TelegramID, err)
+	}
+
+	var fresh []ai.FinancialPrediction
+	if !result.Analysis.Skipped {
+		for _, pred := range result.Analysis.Predictions {
+			if !Reportable(pred) {
+				continue
+			}
+			stock, reviewReason, err := classifyPrediction(ctx, d.storage, d.opts, pred)
+			if err != nil {
+				return err
+			}
+			if reviewReason != "" {
+				continue
+			}
+			pred.Ticker = stock.Ticker
+			fresh = append(fresh, pred)
+		}
+	}
+	diff := CompareMessage(message, stored, fresh)
+	d.report.Add(diff)
+	if !diff.Empty() {
+		printDiff(d.w, diff)
+	}
+	return nil
+}
+
+// Report возвращает накопленный отчет.
+func (d *DiffSink) Report() DiffReport {
+	return d.report
+}
+
+func printDiff(w io.Writer, diff MessageDiff) {
+	fmt.Fprintf(w, "Message %d (channel %d):\n", diff.MessageID, diff.ChannelID)
+	for _, ticker := range diff.Added {
+		fmt.Fprintf(w, "  + %s\n", ticker)
+	}
+	for _, ticker := range diff.Removed {
+		fmt.Fprintf(w, "  - %s\n", ticker)
+	}
+	for _, change := range diff.Changed {
+		for _, field := range change.Changes {
+			fmt.Fprintf(w, "  ~ %s %s: %q -> %q\n", change.Ticker, field.Field, field.Old, field.New)
+		}
+	}
+}
+
+// Flush ничего не делает: отчет сохраняется при закрытии.
+func (d *DiffSink) Flush() error {
+	return nil
+}
+
+// Close печатает итоги сравнения и сохраняет отчет, если задан путь.
+func (d *DiffSink) Close() error {
+	r := d.report
+	fmt.Fprintf(d.w, "Compared %d messages: %d unchanged, %d tickers added, %d removed, %d direction changes, %d target changes\n",
+		r.Messages, r.Unchanged, r.AddedTickers, r.RemovedTickers, r.ChangedDirection, r.ChangedTargets)
+	if d.reportPath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal diff report: %w", err)
+	}
+	return writeFileAtomic(d.reportPath, append(data, '\n'))
+}
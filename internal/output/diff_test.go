@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// diffStorage возвращает сохраненные прогнозы по каналу и ID сообщения и акции из справочника.
+type diffStorage struct {
+	storage.Storage
+	stored map[[2]int64][]storage.StockPrediction
+	stocks map[string]int64
+}
+
+func (d *diffStorage) GetPredictions(ctx context.Context, channelID, messageID, runID int64) ([]storage.StockPrediction, error) {
+	return d.stored[[2]int64{channelID, messageID}], nil
+}
+
+func (d *diffStorage) GetStock(ctx context.Context, ticker string) (*storage.Stock, error) {
+	id, ok := d.stocks[ticker]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &storage.Stock{ID: id, Ticker: ticker}, nil
+}
+
+func storedPrediction(ticker, direction string, target float64, levels ...float64) storage.StockPrediction {
+	return storage.StockPrediction{
+		Ticker: ticker,
+		Prediction: storage.Prediction{
+			PredictionType:   sql.NullString{String: "Покупка", Valid: true},
+			Direction:        sql.NullString{String: direction, Valid: true},
+			TargetPrice:      sql.NullFloat64{Float64: target, Valid: true},
+			TakeProfitLevels: pq.Float64Array(levels),
+		},
+	}
+}
+
+// freshPrediction возвращает прогноз модели без уровней входа, стопа и процента изменения.
+func freshPrediction(ticker, direction string, target float64, levels ...float64) ai.FinancialPrediction {
+	null := ai.FlexibleStringOrNumber{IsNull: true}
+	return ai.FinancialPrediction{
+		Ticker:              ticker,
+		PredictionType:      "Покупка",
+		Direction:           direction,
+		TargetPrice:         ai.FlexibleStringOrNumber{FloatValue: target},
+		TargetChangePercent: null,
+		EntryPrice:          null,
+		StopLoss:            null,
+		TakeProfitLevels:    levels,
+	}
+}
+
+// TestCompareMessage проверяет добавленные, удаленные и измененные прогнозы
+func TestCompareMessage(t *testing.T) {
+	stored := []storage.StockPrediction{
+		storedPrediction("SBER", "Лонг", 290, 300, 310),
+		storedPrediction("GAZP", "Лонг", 180),
+		storedPrediction("LKOH", "Шорт", 6500),
+	}
+	fresh := []ai.FinancialPrediction{
+		freshPrediction("$sber", "Лонг", 290, 300, 310),
+		freshPrediction("GAZP", "Шорт", 170),
+		freshPrediction("YNDX", "Лонг", 3000),
+		{Ticker: "MTSS", PredictionType: "Неопределенный"},
+	}
+
+	diff := CompareMessage(message(100, 1), stored, fresh)
+
+	assert.Equal(t, []string{"YNDX"}, diff.Added)
+	assert.Equal(t, []string{"LKOH"}, diff.Removed)
+	assert.Equal(t, []PredictionChange{{Ticker: "GAZP", Changes: []FieldChange{
+		{Field: "direction", Old: "Лонг", New: "Шорт"},
+		{Field: "target_price", Old: "180", New: "170"},
+	}}}, diff.Changed)
+
+	var report DiffReport
+	report.Add(diff)
+	report.Add(CompareMessage(message(100, 2), nil, nil))
+	assert.Equal(t, 2, report.Messages)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 1, report.AddedTickers)
+	assert.Equal(t, 1, report.RemovedTickers)
+	assert.Equal(t, 1, report.ChangedDirection)
+	assert.Equal(t, 1, report.ChangedTargets)
+}
+
+// TestDiffSink проверяет печать отличий и сохранение отчета
+func TestDiffSink(t *testing.T) {
+	db := &diffStorage{
+		stored: map[[2]int64][]storage.StockPrediction{
+			{100, 1}: {storedPrediction("SBER", "Лонг", 290)},
+			{200, 1}: {storedPrediction("GAZP", "Лонг", 180)},
+		},
+		stocks: map[string]int64{"SBER": 1, "GAZP": 2},
+	}
+	path := filepath.Join(t.TempDir(), "diff.json")
+	var out bytes.Buffer
+	sink := NewDiffSink(db, PostgresOptions{ConfidenceEnabled: true, MinConfidence: 0.6}, &out, path)
+
+	assert.NoError(t, sink.Write(context.Background(), Result{Message: message(100, 1), Analysis: &ai.MessageAnalysis{Skipped: true}}))
+
+	// Сообщение с тем же ID в другом канале сравнивается со своими прогнозами. Прогнозы, которые приемник db
+	// отправил бы на проверку (неизвестный тикер, низкая уверенность), добавленными не считаются
+	gazp := freshPrediction("GAZP", "Лонг", 180)
+	gazp.Confidence = 0.9
+	unresolved := freshPrediction("XXXX", "Лонг", 10)
+	unresolved.Confidence = 0.9
+	uncertain := freshPrediction("SBER", "Лонг", 290)
+	uncertain.Confidence = 0.3
+	analysis := &ai.MessageAnalysis{Predictions: []ai.FinancialPrediction{gazp, unresolved, uncertain}}
+	assert.NoError(t, sink.Write(context.Background(), Result{Message: message(200, 1), Analysis: analysis}))
+	assert.NoError(t, sink.Close())
+
+	assert.Contains(t, out.String(), "Message 1 (channel 100):\n  - SBER\n")
+	assert.NotContains(t, out.String(), "channel 200")
+	assert.Contains(t, out.String(), "Compared 2 messages: 1 unchanged")
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var report DiffReport
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, 1, report.RemovedTickers)
+	assert.Equal(t, []string{"SBER"}, report.Diffs[0].Removed)
+}
@@ -0,0 +1,74 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONSink собирает результаты в памяти и сохраняет их в файл одним JSON-массивом записей Record.
+// Файл перезаписывается атомарно через временный файл, поэтому после сбоя в нем остается результат последнего Flush.
+type JSONSink struct {
+	path    string
+	records []Record
+	now     func() time.Time
+}
+
+// NewJSONSink создает приемник, сохраняющий результаты в path.
+func NewJSONSink(path string) *JSONSink {
+	return &JSONSink{path: path, records: []Record{}, now: time.Now}
+}
+
+// Write добавляет результат к накопленным записям.
+func (j *JSONSink) Write(ctx context.Context, result Result) error {
+	j.records = append(j.records, NewRecord(result, j.now()))
+	return nil
+}
+
+// Flush перезаписывает файл всеми накопленными записями.
+func (j *JSONSink) Flush() error {
+	data, err := json.MarshalIndent(j.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+
+	return writeFileAtomic(j.path, append(data, '\n'))
+}
+
+// writeFileAtomic перезаписывает файл через временный файл в том же каталоге, чтобы при сбое в нем осталось
+// прежнее или новое содержимое целиком.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set output file permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace output file: %w", err)
+	}
+	return nil
+}
+
+// Close сохраняет накопленные записи.
+func (j *JSONSink) Close() error {
+	return j.Flush()
+}
@@ -0,0 +1,223 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Record — результат анализа одного сообщения в выходном файле.
+type Record struct {
+	MessageID   int64                    `json:"message_id"`
+	Channel     string                   `json:"channel"`
+	Timestamp   time.Time                `json:"timestamp"`
+	Skipped     bool                     `json:"skipped,omitempty"`
+	SkipReason  string                   `json:"skip_reason,omitempty"`
+	Predictions []ai.FinancialPrediction `json:"predictions"`
+	Usage       ai.Usage                 `json:"usage"`
+}
+
+// JSONLSink дописывает результаты анализа в файл JSONL: одна запись на сообщение.
+// Запись попадает в файл одним вызовом write, поэтому при сбое может оборваться только последняя строка.
+type JSONLSink struct {
+	file      *os.File
+	syncEvery int // Через сколько записей выполнять fsync; 0 — только при Flush и Close
+	pending   int
+	last      *Record
+	now       func() time.Time
+}
+
+// NewJSONLSink открывает файл для записи результатов. Без resume файл перезаписывается. С resume существующие записи
+// сохраняются, оборванная последняя строка отрезается, а последняя целая запись доступна через Last.
+func NewJSONLSink(path string, resume bool, syncEvery int) (*JSONLSink, error) {
+	w := &JSONLSink{syncEvery: syncEvery, now: time.Now}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if resume {
+		last, validSize, err := scanJSONL(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := truncateTo(path, validSize); err != nil {
+			return nil, err
+		}
+		w.last = last
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open output file: %w", err)
+	}
+	w.file = file
+	return w, nil
+}
+
+// scanJSONL читает записи файла и возвращает последнюю целую запись и размер файла до первой битой строки.
+func scanJSONL(path string) (*Record, int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open output file for resume: %w", err)
+	}
+	defer file.Close()
+
+	var last *Record
+	var validSize int64
+	reader := bufio.NewReader(file)
+	for {
+		// Строка без перевода строки в конце — оборванная при сбое запись
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read output file for resume: %w", err)
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record Record
+			if json.Unmarshal(trimmed, &record) != nil {
+				break
+			}
+			last = &record
+		}
+		validSize += int64(len(line))
+	}
+	return last, validSize, nil
+}
+
+func truncateTo(path string, size int64) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if info.Size() == size {
+		return nil
+	}
+	if err := os.Truncate(path, size); err != nil {
+		return fmt.Errorf("failed to truncate incomplete record: %w", err)
+	}
+	return nil
+}
+
+// Last возвращает последнюю запись, найденную в файле при открытии с resume.
+func (w *JSONLSink) Last() (Record, bool) {
+	if w.last == nil {
+		return Record{}, false
+	}
+	return *w.last, true
+}
+
+// Write дописывает результат в файл и выполняет fsync каждые syncEvery записей.
+func (w *JSONLSink) Write(ctx context.Context, result Result) error {
+	record := NewRecord(result, w.now())
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record for message %d: %w", record.MessageID, err)
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write record for message %d: %w", record.MessageID, err)
+	}
+
+	w.last = &record
+	w.pending++
+	if w.syncEvery > 0 && w.pending >= w.syncEvery {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush сбрасывает записанные данные на диск.
+func (w *JSONLSink) Flush() error {
+	w.pending = 0
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	return nil
+}
+
+// Close сбрасывает данные на диск и закрывает файл.
+func (w *JSONLSink) Close() error {
+	syncErr := w.Flush()
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	return syncErr
+}
+
+// NewRecord формирует запись по результату анализа. Записываются только прогнозы, прошедшие Reportable.
+func NewRecord(result Result, now time.Time) Record {
+	analysis := result.Analysis
+	record := Record{
+		MessageID:   result.Message.TelegramID,
+		Channel:     result.Channel(),
+		Timestamp:   now,
+		Skipped:     analysis.Skipped,
+		SkipReason:  analysis.SkipReason,
+		Predictions: []ai.FinancialPrediction{},
+		Usage:       analysis.Usage,
+	}
+	for _, prediction := range analysis.Predictions {
+		if Reportable(prediction) {
+			record.Predictions = append(record.Predictions, prediction)
+		}
+	}
+	return record
+}
+
+// ReadJSONL читает записи из потока в формате JSONLSink и передает их fn по одной. Пустые строки пропускаются.
+func ReadJSONL(r io.Reader, fn func(Record) error) error {
+	reader := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read line %d: %w", lineNum, err)
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var record Record
+			if jsonErr := json.Unmarshal(trimmed, &record); jsonErr != nil {
+				return fmt.Errorf("failed to parse line %d: %w", lineNum, jsonErr)
+			}
+			if fnErr := fn(record); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// Result восстанавливает результат анализа из записи. Текст сообщения в записи не хранится,
+// поэтому у восстановленного сообщения заполнены только канал и идентификатор.
+func (r Record) Result() (Result, error) {
+	channelID, err := strconv.ParseInt(r.Channel, 10, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid channel %q in record for message %d: %w", r.Channel, r.MessageID, err)
+	}
+	return Result{
+		Message: storage.Message{ChannelID: channelID, TelegramID: r.MessageID},
+		Analysis: &ai.MessageAnalysis{
+			Predictions: r.Predictions,
+			Skipped:     r.Skipped,
+			SkipReason:  r.SkipReason,
+			Usage:       r.Usage,
+		},
+	}, nil
+}
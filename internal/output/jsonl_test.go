@@ -0,0 +1,94 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJSONLSink проверяет построчную запись результатов и продолжение после сбоя
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	ctx := context.Background()
+
+	writer, err := NewJSONLSink(path, false, 1)
+	assert.NoError(t, err)
+	_, ok := writer.Last()
+	assert.False(t, ok)
+
+	analysis := &ai.MessageAnalysis{Predictions: []ai.FinancialPrediction{
+		{Ticker: "SBER", PredictionType: "Покупка"},
+		{Ticker: "", PredictionType: "Покупка"},
+		{Ticker: "GAZP", PredictionType: "Неопределенный"},
+	}}
+	assert.NoError(t, writer.Write(ctx, Result{Message: message(100, 1), Analysis: analysis}))
+	assert.NoError(t, writer.Write(ctx, Result{Message: message(100, 2), Analysis: &ai.MessageAnalysis{Skipped: true, SkipReason: ai.SkipReasonAd}}))
+	assert.NoError(t, writer.Close())
+
+	// Имитируем сбой посреди записи третьей строки
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"message_id": 3, "chan`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	writer, err = NewJSONLSink(path, true, 0)
+	assert.NoError(t, err)
+	last, ok := writer.Last()
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), last.MessageID)
+	assert.True(t, last.Skipped)
+	assert.NoError(t, writer.Write(ctx, Result{Message: message(100, 3), Analysis: &ai.MessageAnalysis{}}))
+	assert.NoError(t, writer.Close())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"ticker":"SBER"`)
+	assert.NotContains(t, lines[0], "GAZP")
+	assert.Contains(t, lines[2], `"message_id":3`)
+	assert.Contains(t, lines[2], `"predictions":[]`)
+
+	// Без resume файл перезаписывается
+	writer, err = NewJSONLSink(path, false, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	content, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, content)
+}
+
+func message(channelID, telegramID int64) storage.Message {
+	return storage.Message{ChannelID: channelID, TelegramID: telegramID}
+}
+
+// TestReadJSONL проверяет чтение записей и восстановление результатов анализа
+func TestReadJSONL(t *testing.T) {
+	input := `{"message_id":7,"channel":"100","timestamp":"2024-03-01T10:00:00Z","predictions":[{"ticker":"SBER","prediction_type":"Покупка","target_price":290}],"usage":{}}
+
+{"message_id":8,"channel":"100","timestamp":"2024-03-01T10:01:00Z","skipped":true,"skip_reason":"ad","predictions":[],"usage":{}}
+`
+	var results []Result
+	err := ReadJSONL(strings.NewReader(input), func(record Record) error {
+		result, err := record.Result()
+		results = append(results, result)
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, message(100, 7), results[0].Message)
+	assert.Equal(t, 290.0, results[0].Analysis.Predictions[0].TargetPrice.FloatValue)
+	assert.True(t, results[1].Analysis.Skipped)
+
+	err = ReadJSONL(strings.NewReader(`{"message_id": 9, "chan`), func(Record) error { return nil })
+	assert.ErrorContains(t, err, "line 1")
+}
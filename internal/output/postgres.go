@@ -0,0 +1,237 @@
+package output
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+// PostgresOptions задает пороги, по которым прогнозы отправляются на ручную проверку.
+type PostgresOptions struct {
+	MinAgreement      float64 // Минимальное согласие ансамбля (ai.ensemble.min_agreement)
+	ConfidenceEnabled bool    // Сохранять итоговую уверенность и проверять MinConfidence
+	MinConfidence     float64 // Минимальная уверенность (ai.confidence.min_confidence)
+	RunID             int64   // Запуск анализа, к которому относятся результаты; 0 — без запуска
+	Supersede         bool    // Повторный анализ: прежние результаты сообщения помечаются замененными запуском RunID
+}
+
+// PostgresSink сохраняет результаты анализа в базу данных: пропуски — в message_skips, прогнозы с найденной акцией — в
+// predictions, а прогнозы, требующие ручной проверки, — в raw_predictions. Хранилище не закрывается вместе с приемником.
+type PostgresSink struct {
+	storage storage.Storage
+	opts    PostgresOptions
+	logger  *log.Logger
+	now     func() time.Time
+}
+
+// NewPostgresSink создает приемник, сохраняющий результаты через storage.
+func NewPostgresSink(storage storage.Storage, opts PostgresOptions, logger *log.Logger) *PostgresSink {
+	return &PostgresSink{storage: storage, opts: opts, logger: logger, now: time.Now}
+}
+
+// Write сохраняет пропуск сообщения или его прогнозы вместе с результатом анализа, на который ссылаются прогнозы,
+// если задан запуск анализа. При повторном анализе прежние результаты сообщения помечаются замененными.
+// Все записи сообщения сохраняются одной транзакцией: при ошибке не сохраняется ничего, и прежние результаты
+// остаются действующими.
+func (p *PostgresSink) Write(ctx context.Context, result Result) error {
+	message := result.Message
+	results := storage.MessageResults{ChannelID: message.ChannelID, MessageID: message.TelegramID}
+	if p.opts.Supersede {
+		results.SupersedeRunID = p.opts.RunID
+	}
+	if p.opts.RunID != 0 {
+		analysisResult := newAnalysisResult(p.opts.RunID, result, p.now())
+		results.Result = &analysisResult
+	}
+
+	if result.Analysis.Skipped {
+		results.Skip = &storage.MessageSkip{
+			MessageID: message.TelegramID,
+			ChannelID: message.ChannelID,
+			Reason:    result.Analysis.SkipReason,
+			RunID:     p.runID(),
+			SkippedAt: p.now(),
+		}
+	} else {
+		for _, pred := range result.Analysis.Predictions {
+			// Проверяем, что Ticker не пустой и PredictionType не 'Неопределенный' перед сохранением
+			if !Reportable(pred) {
+				p.logger.Printf("Prediction for message %d with ticker '%s' and type '%s' ignored (empty ticker or 'Неопределенный' type). Skipping.", message.TelegramID, pred.Ticker, pred.PredictionType)
+				continue
+			}
+			prediction, rawPrediction, err := p.newPrediction(ctx, message, pred)
+			if err != nil {
+				return err
+			}
+			if prediction != nil {
+				results.Predictions = append(results.Predictions, *prediction)
+			} else {
+				results.RawPredictions = append(results.RawPredictions, *rawPrediction)
+			}
+		}
+	}
+
+	if err := p.storage.SaveMessageResults(ctx, &results); err != nil {
+		return fmt.Errorf("failed to save results for message %d: %w", message.TelegramID, err)
+	}
+	p.logger.Printf("Results for message %d saved to DB: %d predictions, %d raw predictions.",
+		message.TelegramID, len(results.Predictions), len(results.RawPredictions))
+	return nil
+}
+
+// newPrediction формирует запись прогноза для predictions или, если он требует проверки, для raw_predictions.
+// Возвращается одна из двух записей.
+func (p *PostgresSink) newPrediction(ctx context.Context, message storage.Message, pred ai.FinancialPrediction) (*storage.Prediction, *storage.RawPrediction, error) {
+	entryMin, entryMax, hasEntry := pred.EntryPrice.Range()
+	stopLoss, _, hasStopLoss := pred.StopLoss.Range()
+
+	var evidenceStatus sql.NullString
+	var evidenceScore sql.NullFloat64
+	var evidenceSpanStart, evidenceSpanEnd sql.NullInt64
+	if pred.Evidence != nil {
+		evidenceStatus = sql.NullString{String: pred.Evidence.Status, Valid: true}
+		evidenceScore = sql.NullFloat64{Float64: pred.Evidence.QuoteScore, Valid: true}
+		evidenceSpanStart = sql.NullInt64{Int64: int64(pred.Evidence.SpanStart), Valid: pred.Evidence.SpanStart >= 0}
+		evidenceSpanEnd = sql.NullInt64{Int64: int64(pred.Evidence.SpanEnd), Valid: pred.Evidence.SpanEnd >= 0}
+	}
+	var agreement sql.NullFloat64
+	if pred.EnsembleSize > 0 {
+		agreement = sql.NullFloat64{Float64: pred.Agreement, Valid: true}
+	}
+
+	var confidence sql.NullFloat64
+	if p.opts.ConfidenceEnabled {
+		confidence = sql.NullFloat64{Float64: pred.Confidence, Valid: true}
+	}
+
+	stock, reviewReason, err := classifyPrediction(ctx, p.storage, p.opts, pred)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reviewReason != "" {
+		if reviewReason == storage.ReviewReasonUnresolvedTicker {
+			p.logger.Printf("Stock with ticker '%s' not found. Saving as raw prediction.", pred.Ticker)
+		} else {
+			p.logger.Printf("Prediction for ticker '%s' needs review (%s). Saving as raw prediction.", pred.Ticker, reviewReason)
+		}
+		rawPrediction := &storage.RawPrediction{
+			MessageID:           message.TelegramID,
+			ChannelID:           sql.NullInt64{Int64: message.ChannelID, Valid: true},
+			RawTicker:           sql.NullString{String: pred.Ticker, Valid: true},
+			PredictionType:      sql.NullString{String: pred.PredictionType, Valid: pred.PredictionType != ""},
+			TargetPrice:         sql.NullFloat64{Float64: pred.TargetPrice.FloatValue, Valid: !pred.TargetPrice.IsNull && !pred.TargetPrice.IsString},
+			TargetChangePercent: sql.NullFloat64{Float64: pred.TargetChangePercent.FloatValue, Valid: !pred.TargetChangePercent.IsNull && !pred.TargetChangePercent.IsString},
+			EntryPriceMin:       sql.NullFloat64{Float64: entryMin, Valid: hasEntry},
+			EntryPriceMax:       sql.NullFloat64{Float64: entryMax, Valid: hasEntry},
+			StopLoss:            sql.NullFloat64{Float64: stopLoss, Valid: hasStopLoss},
+			TakeProfitLevels:    pq.Float64Array(pred.TakeProfitLevels),
+			Period:              sql.NullString{String: pred.Period, Valid: pred.Period != ""},
+			Recommendation:      sql.NullString{String: pred.Recommendation, Valid: pred.Recommendation != ""},
+			Direction:           sql.NullString{String: pred.Direction, Valid: pred.Direction != ""},
+			JustificationText:   sql.NullString{String: pred.JustificationText, Valid: pred.JustificationText != ""},
+			EvidenceStatus:      evidenceStatus,
+			EvidenceScore:       evidenceScore,
+			EvidenceSpanStart:   evidenceSpanStart,
+			EvidenceSpanEnd:     evidenceSpanEnd,
+			Agreement:           agreement,
+			Confidence:          confidence,
+			Contextual:          pred.Contextual,
+			ReviewReason:        sql.NullString{String: reviewReason, Valid: true},
+			RunID:               p.runID(),
+			PredictedAt:         p.now(),
+		}
+		return nil, rawPrediction, nil
+	}
+
+	dbPrediction := &storage.Prediction{
+		MessageID:           message.TelegramID,
+		ChannelID:           sql.NullInt64{Int64: message.ChannelID, Valid: true},
+		StockID:             stock.ID,
+		PredictionType:      sql.NullString{String: pred.PredictionType, Valid: pred.PredictionType != ""},
+		TargetPrice:         sql.NullFloat64{Float64: pred.TargetPrice.FloatValue, Valid: !pred.TargetPrice.IsNull && !pred.TargetPrice.IsString},
+		TargetChangePercent: sql.NullFloat64{Float64: pred.TargetChangePercent.FloatValue, Valid: !pred.TargetChangePercent.IsNull && !pred.TargetChangePercent.IsString},
+		EntryPriceMin:       sql.NullFloat64{Float64: entryMin, Valid: hasEntry},
+		EntryPriceMax:       sql.NullFloat64{Float64: entryMax, Valid: hasEntry},
+		StopLoss:            sql.NullFloat64{Float64: stopLoss, Valid: hasStopLoss},
+		TakeProfitLevels:    pq.Float64Array(pred.TakeProfitLevels),
+		Period:              sql.NullString{String: pred.Period, Valid: pred.Period != ""},
+		Recommendation:      sql.NullString{String: pred.Recommendation, Valid: pred.Recommendation != ""},
+		Direction:           sql.NullString{String: pred.Direction, Valid: pred.Direction != ""},
+		JustificationText:   sql.NullString{String: pred.JustificationText, Valid: pred.JustificationText != ""},
+		EvidenceStatus:      evidenceStatus,
+		EvidenceScore:       evidenceScore,
+		EvidenceSpanStart:   evidenceSpanStart,
+		EvidenceSpanEnd:     evidenceSpanEnd,
+		Agreement:           agreement,
+		Confidence:          confidence,
+		Contextual:          pred.Contextual,
+		RunID:               p.runID(),
+		PredictedAt:         p.now(),
+	}
+	return dbPrediction, nil, nil
+}
+
+// classifyPrediction находит акцию прогноза и определяет, требует ли он ручной проверки: прогнозы, цитата или тикер
+// которых не найдены в сообщении, прогнозы с низким согласием ансамбля или низкой уверенностью и прогнозы без акции
+// в справочнике понижаются до raw_predictions. Пустая причина означает, что прогноз сохраняется в predictions.
+func classifyPrediction(ctx context.Context, st storage.Storage, opts PostgresOptions, pred ai.FinancialPrediction) (*storage.Stock, string, error) {
+	var reviewReason string
+	switch {
+	case pred.Evidence != nil && pred.Evidence.Status != ai.EvidenceVerified:
+		reviewReason = storage.ReviewReasonUnverifiedEvidence
+	case pred.EnsembleSize > 0 && pred.Agreement < opts.MinAgreement:
+		reviewReason = storage.ReviewReasonLowAgreement
+	case opts.ConfidenceEnabled && pred.Confidence < opts.MinConfidence:
+		reviewReason = storage.ReviewReasonLowConfidence
+	}
+
+	stock, err := st.GetStock(ctx, pred.Ticker)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ReviewReasonUnresolvedTicker, nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get stock for ticker %s: %w", pred.Ticker, err)
+	}
+	return stock, reviewReason, nil
+}
+
+// newAnalysisResult формирует результат анализа сообщения для запуска runID. Ответы модели разделяются пустой строкой;
+// время анализа неизвестно для результатов, загруженных из файла.
+func newAnalysisResult(runID int64, result Result, now time.Time) storage.AnalysisResult {
+	usage := result.Analysis.Usage
+	rawResponse := strings.Join(usage.Responses, "\n\n")
+	return storage.AnalysisResult{
+		RunID:            runID,
+		ChannelID:        result.Message.ChannelID,
+		MessageID:        result.Message.TelegramID,
+		RawResponse:      sql.NullString{String: rawResponse, Valid: len(usage.Responses) > 0},
+		LatencyMs:        sql.NullInt64{Int64: result.Analysis.Latency.Milliseconds(), Valid: result.Analysis.Latency > 0},
+		Requests:         usage.Requests,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CreatedAt:        now,
+	}
+}
+
+func (p *PostgresSink) runID() sql.NullInt64 {
+	return sql.NullInt64{Int64: p.opts.RunID, Valid: p.opts.RunID != 0}
+}
+
+// Flush ничего не делает: каждый прогноз сохраняется сразу.
+func (p *PostgresSink) Flush() error {
+	return nil
+}
+
+// Close ничего не делает: хранилище закрывает его владелец.
+func (p *PostgresSink) Close() error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package output
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStorage запоминает сохраненные записи. Методы, не нужные приемнику, не реализованы.
+type fakeStorage struct {
+	storage.Storage
+	stocks      map[string]int64
+	predictions []storage.Prediction
+	raw         []storage.RawPrediction
+	skips       []storage.MessageSkip
+	superseded  [][3]int64 // channel_id, message_id, run_id
+	results     []storage.AnalysisResult
+	failTicker  string // GetStock возвращает ошибку базы для этого тикера
+}
+
+// SaveMessageResults запоминает записи сообщения так, как их сохранила бы транзакция.
+func (f *fakeStorage) SaveMessageResults(ctx context.Context, results *storage.MessageResults) error {
+	if results.SupersedeRunID != 0 {
+		f.superseded = append(f.superseded, [3]int64{results.ChannelID, results.MessageID, results.SupersedeRunID})
+	}
+	var resultID sql.NullInt64
+	if results.Result != nil {
+		results.Result.ID = int64(len(f.results) + 1)
+		f.results = append(f.results, *results.Result)
+		resultID = sql.NullInt64{Int64: results.Result.ID, Valid: true}
+	}
+	if results.Skip != nil {
+		f.skips = append(f.skips, *results.Skip)
+	}
+	for _, prediction := range results.Predictions {
+		prediction.ResultID = resultID
+		f.predictions = append(f.predictions, prediction)
+	}
+	for _, rawPrediction := range results.RawPredictions {
+		rawPrediction.ResultID = resultID
+		f.raw = append(f.raw, rawPrediction)
+	}
+	return nil
+}
+
+func (f *fakeStorage) GetStock(ctx context.Context, ticker string) (*storage.Stock, error) {
+	if ticker == f.failTicker {
+		return nil, errors.New("connection reset")
+	}
+	id, ok := f.stocks[ticker]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &storage.Stock{ID: id, Ticker: ticker}, nil
+}
+
+// TestPostgresSink проверяет распределение прогнозов между predictions и raw_predictions
+func TestPostgresSink(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeStorage{stocks: map[string]int64{"SBER": 1, "GAZP": 2}}
+	sink := NewPostgresSink(db, PostgresOptions{MinAgreement: 0.5, ConfidenceEnabled: true, MinConfidence: 0.6}, log.New(io.Discard, "", 0))
+
+	analysis := &ai.MessageAnalysis{Predictions: []ai.FinancialPrediction{
+		{Ticker: "SBER", PredictionType: "Покупка", Confidence: 0.9, Contextual: true},
+		{Ticker: "GAZP", PredictionType: "Продажа", Confidence: 0.3},
+		{Ticker: "XXXX", PredictionType: "Покупка", Confidence: 0.9},
+		{Ticker: "LKOH", PredictionType: "Неопределенный", Confidence: 0.9},
+	}}
+	assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 1), Analysis: analysis}))
+	assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 2), Analysis: &ai.MessageAnalysis{Skipped: true, SkipReason: ai.SkipReasonAd}}))
+
+	assert.Len(t, db.predictions, 1)
+	assert.Equal(t, int64(1), db.predictions[0].StockID)
+	assert.True(t, db.predictions[0].Contextual)
+
+	assert.Len(t, db.raw, 2)
+	assert.Equal(t, storage.ReviewReasonLowConfidence, db.raw[0].ReviewReason.String)
+	assert.Equal(t, storage.ReviewReasonUnresolvedTicker, db.raw[1].ReviewReason.String)
+
+	assert.Len(t, db.skips, 1)
+	assert.Equal(t, int64(2), db.skips[0].MessageID)
+	assert.Equal(t, ai.SkipReasonAd, db.skips[0].Reason)
+}
+
+// TestPostgresSinkReanalysis проверяет, что повторный анализ помечает прежние результаты замененными и ссылается на запуск
+func TestPostgresSinkReanalysis(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeStorage{stocks: map[string]int64{"SBER": 1}}
+	sink := NewPostgresSink(db, PostgresOptions{RunID: 5, Supersede: true}, log.New(io.Discard, "", 0))
+
+	analysis := &ai.MessageAnalysis{
+		Predictions: []ai.FinancialPrediction{{Ticker: "SBER", PredictionType: "Покупка"}},
+		Usage:       ai.Usage{Requests: 2, PromptTokens: 300, CompletionTokens: 40, Responses: []string{"да", `[{"ticker":"SBER"}]`}},
+		Latency:     1500 * time.Millisecond,
+	}
+	assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 1), Analysis: analysis}))
+
+	assert.Equal(t, [][3]int64{{100, 1, 5}}, db.superseded)
+	assert.Len(t, db.predictions, 1)
+	assert.Equal(t, sql.NullInt64{Int64: 5, Valid: true}, db.predictions[0].RunID)
+
+	// Результат анализа сообщения хранит ответы модели, а прогноз ссылается на него
+	assert.Len(t, db.results, 1)
+	assert.Equal(t, int64(5), db.results[0].RunID)
+	assert.Equal(t, "да\n\n[{\"ticker\":\"SBER\"}]", db.results[0].RawResponse.String)
+	assert.Equal(t, int64(1500), db.results[0].LatencyMs.Int64)
+	assert.Equal(t, 300, db.results[0].PromptTokens)
+	assert.Equal(t, sql.NullInt64{Int64: 1, Valid: true}, db.predictions[0].ResultID)
+
+	// Сообщение с тем же Telegram ID в другом канале — другое сообщение: результаты относятся к своему каналу
+	assert.NoError(t, sink.Write(ctx, Result{Message: message(200, 1), Analysis: analysis}))
+	assert.Equal(t, [][3]int64{{100, 1, 5}, {200, 1, 5}}, db.superseded)
+	if assert.Len(t, db.predictions, 2) {
+		assert.Equal(t, sql.NullInt64{Int64: 100, Valid: true}, db.predictions[0].ChannelID)
+		assert.Equal(t, sql.NullInt64{Int64: 200, Valid: true}, db.predictions[1].ChannelID)
+	}
+
+	// Без Supersede прежние результаты не трогаются
+	db.superseded = nil
+	sink = NewPostgresSink(db, PostgresOptions{RunID: 6}, log.New(io.Discard, "", 0))
+	assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 2), Analysis: analysis}))
+	assert.Empty(t, db.superseded)
+}
+
+// TestPostgresSinkAtomic проверяет, что при ошибке на одном прогнозе не сохраняется ничего и прежние результаты
+// не помечаются замененными
+func TestPostgresSinkAtomic(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeStorage{stocks: map[string]int64{"SBER": 1}, failTicker: "GAZP"}
+	sink := NewPostgresSink(db, PostgresOptions{RunID: 5, Supersede: true}, log.New(io.Discard, "", 0))
+
+	analysis := &ai.MessageAnalysis{Predictions: []ai.FinancialPrediction{
+		{Ticker: "SBER", PredictionType: "Покупка"},
+		{Ticker: "GAZP", PredictionType: "Покупка"},
+	}}
+	assert.Error(t, sink.Write(ctx, Result{Message: message(100, 1), Analysis: analysis}))
+
+	assert.Empty(t, db.superseded)
+	assert.Empty(t, db.results)
+	assert.Empty(t, db.predictions)
+}
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Result — результат анализа одного сообщения, который передается приемникам.
+type Result struct {
+	Message  storage.Message
+	Analysis *ai.MessageAnalysis
+}
+
+// Channel возвращает идентификатор канала сообщения в том виде, в котором он передается в конвейер анализа.
+func (r Result) Channel() string {
+	return strconv.FormatInt(r.Message.ChannelID, 10)
+}
+
+// Sink — приемник результатов анализа: консоль, файл или база данных.
+type Sink interface {
+	// Write принимает результат анализа одного сообщения, в том числе пропущенного.
+	Write(ctx context.Context, result Result) error
+	// Flush сохраняет накопленные результаты, не закрывая приемник.
+	Flush() error
+	// Close сохраняет накопленные результаты и освобождает ресурсы.
+	Close() error
+}
+
+// MultiSink передает каждый результат всем приемникам по очереди. Ошибка одного приемника не мешает записи в остальные.
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink объединяет приемники. Один приемник возвращается как есть.
+func NewMultiSink(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return &MultiSink{sinks: sinks}
+}
+
+// Write передает результат всем приемникам и возвращает объединенные ошибки.
+func (m *MultiSink) Write(ctx context.Context, result Result) error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Write(ctx, result); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush сохраняет результаты во всех приемниках.
+func (m *MultiSink) Flush() error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close закрывает все приемники.
+func (m *MultiSink) Close() error {
+	var errs []error
+	for _, sink := range m.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Reportable сообщает, нужно ли выводить прогноз: прогнозы без тикера и с типом "Неопределенный" не сохраняются.
+func Reportable(prediction ai.FinancialPrediction) bool {
+	return prediction.Ticker != "" && prediction.PredictionType != "Неопределенный"
+}
@@ -0,0 +1,100 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rkata-ai/trade-radar/internal/ai"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	written []int64
+	err     error
+	closed  bool
+}
+
+func (r *recordingSink) Write(ctx context.Context, result Result) error {
+	r.written = append(r.written, result.Message.TelegramID)
+	return r.err
+}
+
+func (r *recordingSink) Flush() error { return nil }
+
+func (r *recordingSink) Close() error {
+	r.closed = true
+	return r.err
+}
+
+// TestMultiSink проверяет запись во все приемники, даже если один из них вернул ошибку
+func TestMultiSink(t *testing.T) {
+	failing := &recordingSink{err: errors.New("disk full")}
+	healthy := &recordingSink{}
+	sink := NewMultiSink(failing, healthy)
+
+	err := sink.Write(context.Background(), Result{Message: message(100, 1), Analysis: &ai.MessageAnalysis{}})
+
+	assert.ErrorContains(t, err, "disk full")
+	assert.Equal(t, []int64{1}, healthy.written)
+	assert.Error(t, sink.Close())
+	assert.True(t, failing.closed)
+	assert.True(t, healthy.closed)
+	assert.Same(t, healthy, NewMultiSink(healthy))
+}
+
+// TestFileSinks проверяет форматы JSON и CSV
+func TestFileSinks(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	analysis := &ai.MessageAnalysis{Predictions: []ai.FinancialPrediction{
+		{
+			Ticker: "SBER", PredictionType: "Покупка", Direction: "Лонг",
+			TargetPrice: ai.FlexibleStringOrNumber{FloatValue: 290}, TargetChangePercent: ai.FlexibleStringOrNumber{IsNull: true},
+			EntryPrice: ai.FlexibleStringOrNumber{IsString: true, StringValue: "250-255"}, StopLoss: ai.FlexibleStringOrNumber{IsNull: true},
+			TakeProfitLevels: ai.PriceLevels{290, 310}, JustificationText: "цель 290, потом 310",
+		},
+		{Ticker: "GAZP", PredictionType: "Неопределенный"},
+	}}
+	skipped := &ai.MessageAnalysis{Skipped: true, SkipReason: ai.SkipReasonAd}
+
+	t.Run("JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "results.json")
+		sink := NewJSONSink(path)
+		assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 1), Analysis: analysis}))
+		assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 2), Analysis: skipped}))
+		assert.NoError(t, sink.Close())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		var records []Record
+		assert.NoError(t, json.Unmarshal(content, &records))
+		assert.Len(t, records, 2)
+		assert.Len(t, records[0].Predictions, 1)
+		assert.Equal(t, "SBER", records[0].Predictions[0].Ticker)
+		assert.Equal(t, ai.SkipReasonAd, records[1].SkipReason)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		path := filepath.Join(dir, "results.csv")
+		sink, err := NewCSVSink(path)
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 1), Analysis: analysis}))
+		assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 2), Analysis: skipped}))
+		assert.NoError(t, sink.Write(ctx, Result{Message: message(100, 3), Analysis: &ai.MessageAnalysis{}}))
+		assert.NoError(t, sink.Close())
+
+		content, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		assert.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "channel,message_id,skip_reason,ticker"))
+		assert.Equal(t, `100,1,,SBER,false,Покупка,Лонг,290.00,,250-255,,290.00/310.00,,,,,,"цель 290, потом 310"`, lines[1])
+		assert.True(t, strings.HasPrefix(lines[2], "100,2,"+ai.SkipReasonAd+",,"))
+	})
+}
@@ -0,0 +1,78 @@
+// Package retry классифицирует ошибки анализа сообщений и планирует повторные попытки с экспоненциальной задержкой.
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+)
+
+// Классы ошибок анализа, по которым группируются записи analysis_failures.
+const (
+	ClassTimeout         = "timeout"          // Истекло время ожидания ответа модели
+	ClassRequest         = "request"          // Сетевая ошибка или ответ Ollama не 200
+	ClassTruncated       = "truncated"        // Ответ обрезан по num_predict
+	ClassInvalidResponse = "invalid_response" // Ответ модели не разбирается как JSON с прогнозами
+	ClassNoPredictions   = "no_predictions"   // Модель не вернула ни одного прогноза
+	ClassStorage         = "storage"          // Результат не удалось сохранить
+	ClassOther           = "other"
+)
+
+// Classify определяет класс ошибки анализа сообщения моделью. Ошибки сохранения результатов классифицирует
+// вызывающий код (ClassStorage), так как хранилище не помечает их отдельной ошибкой.
+func Classify(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ClassTimeout
+	case errors.Is(err, ai.ErrTruncatedResponse):
+		return ClassTruncated
+	case errors.Is(err, ai.ErrInvalidResponse):
+		return ClassInvalidResponse
+	case errors.Is(err, ai.ErrNoPredictions):
+		return ClassNoPredictions
+	case errors.Is(err, ai.ErrRequestFailed):
+		return ClassRequest
+	default:
+		return ClassOther
+	}
+}
+
+// Retryable сообщает, имеет ли смысл повторять анализ при ошибке класса class. Отсутствие прогнозов — результат
+// анализа, а не сбой, поэтому не повторяется.
+func Retryable(class string) bool {
+	return class != ClassNoPredictions
+}
+
+// Policy задает число попыток и задержку между ними: BaseDelay после первой неудачи, затем вдвое больше после
+// каждой следующей, но не больше MaxDelay. MaxDelay <= 0 снимает ограничение.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay возвращает задержку перед следующей попыткой после attempts неудачных.
+func (p Policy) Delay(attempts int) time.Duration {
+	capped := p.MaxDelay > 0
+	delay := p.BaseDelay
+	for i := 1; i < attempts && (!capped || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if capped && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// NextAttempt возвращает время следующей попытки после attempts неудачных, последняя из которых была в failedAt.
+// false означает, что попытки исчерпаны.
+func (p Policy) NextAttempt(attempts int, failedAt time.Time) (time.Time, bool) {
+	if attempts >= p.MaxAttempts {
+		return time.Time{}, false
+	}
+	return failedAt.Add(p.Delay(attempts)), true
+}
@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestClassify проверяет классы ошибок, обернутых конвейером анализа
+func TestClassify(t *testing.T) {
+	wrap := func(err error) error {
+		return fmt.Errorf("failed to execute pipeline step *ai.PredictionStep: %w", err)
+	}
+
+	assert.Equal(t, ClassTimeout, Classify(wrap(fmt.Errorf("%w: %w", ai.ErrRequestFailed, context.DeadlineExceeded))))
+	assert.Equal(t, ClassRequest, Classify(wrap(fmt.Errorf("%w: connection refused", ai.ErrRequestFailed))))
+	assert.Equal(t, ClassTruncated, Classify(wrap(fmt.Errorf("%w: generation stopped", ai.ErrTruncatedResponse))))
+	assert.Equal(t, ClassInvalidResponse, Classify(wrap(fmt.Errorf("%w: bad json", ai.ErrInvalidResponse))))
+	assert.Equal(t, ClassNoPredictions, Classify(fmt.Errorf("pipeline %w for message ID 1", ai.ErrNoPredictions)))
+	assert.Equal(t, ClassOther, Classify(errors.New("boom")))
+
+	assert.True(t, Retryable(ClassTimeout))
+	assert.True(t, Retryable(ClassStorage))
+	assert.False(t, Retryable(ClassNoPredictions))
+}
+
+// TestPolicy проверяет экспоненциальную задержку и исчерпание попыток
+func TestPolicy(t *testing.T) {
+	policy := Policy{MaxAttempts: 4, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+	failedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Minute, policy.Delay(1))
+	assert.Equal(t, 2*time.Minute, policy.Delay(2))
+	assert.Equal(t, 4*time.Minute, policy.Delay(3))
+	assert.Equal(t, 5*time.Minute, policy.Delay(10))
+
+	next, ok := policy.NextAttempt(3, failedAt)
+	assert.True(t, ok)
+	assert.Equal(t, failedAt.Add(4*time.Minute), next)
+
+	_, ok = policy.NextAttempt(4, failedAt)
+	assert.False(t, ok)
+
+	// Без MaxDelay задержка удваивается без ограничения
+	uncapped := Policy{MaxAttempts: 10, BaseDelay: time.Minute}
+	assert.Equal(t, time.Minute, uncapped.Delay(1))
+	assert.Equal(t, 8*time.Minute, uncapped.Delay(4))
+	assert.Equal(t, 256*time.Minute, uncapped.Delay(9))
+}
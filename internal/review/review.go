@@ -0,0 +1,225 @@
+package review
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/ai"
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/lib/pq"
+)
+
+// ErrNoStock возвращается при принятии прогноза, тикер которого не сопоставлен с акцией.
+var ErrNoStock = errors.New("prediction is not mapped to a stock")
+
+// Поля прогноза, которые можно исправить при проверке.
+const (
+	FieldTicker              = "ticker"
+	FieldPredictionType      = "prediction_type"
+	FieldPeriod              = "period"
+	FieldRecommendation      = "recommendation"
+	FieldDirection           = "direction"
+	FieldTargetPrice         = "target_price"
+	FieldTargetChangePercent = "target_change_percent"
+	FieldEntryPrice          = "entry_price"
+	FieldStopLoss            = "stop_loss"
+	FieldTakeProfitLevels    = "take_profit_levels"
+	FieldJustificationText   = "justification_text"
+)
+
+// Fields перечисляет исправляемые поля в порядке вывода.
+var Fields = []string{
+	FieldTicker, FieldPredictionType, FieldPeriod, FieldRecommendation, FieldDirection, FieldTargetPrice,
+	FieldTargetChangePercent, FieldEntryPrice, FieldStopLoss, FieldTakeProfitLevels, FieldJustificationText,
+}
+
+// Draft — прогноз в процессе проверки: исправленная копия raw-прогноза, сопоставленная акция и список изменений.
+type Draft struct {
+	Item       storage.ReviewItem
+	Prediction storage.RawPrediction
+	Stock      *storage.Stock
+	Changes    map[string]string
+}
+
+// NewDraft создает черновик проверки для прогноза из очереди.
+func NewDraft(item storage.ReviewItem) *Draft {
+	return &Draft{
+		Item:       item,
+		Prediction: item.RawPrediction,
+		Changes:    make(map[string]string),
+	}
+}
+
+// Set исправляет поле прогноза. Значения "null" и "-" очищают поле.
+// Числовые поля принимают числа и диапазоны ("250-255"), take_profit_levels — список через "/" или ",".
+func (d *Draft) Set(field, value string) error {
+	value = strings.TrimSpace(value)
+	empty := value == "" || value == "null" || value == "-"
+	text := sql.NullString{String: value, Valid: !empty}
+	p := &d.Prediction
+
+	switch field {
+	case FieldTicker:
+		if empty {
+			return fmt.Errorf("ticker cannot be empty")
+		}
+		p.RawTicker = sql.NullString{String: ai.NormalizeTicker(value), Valid: true}
+		// Исправленный тикер нужно заново сопоставить с акцией
+		d.Stock = nil
+	case FieldPredictionType:
+		p.PredictionType = text
+	case FieldPeriod:
+		p.Period = text
+	case FieldRecommendation:
+		p.Recommendation = text
+	case FieldDirection:
+		p.Direction = text
+	case FieldJustificationText:
+		p.JustificationText = text
+	case FieldTargetPrice, FieldTargetChangePercent, FieldStopLoss:
+		var number sql.NullFloat64
+		if !empty {
+			low, _, ok := parseRange(value)
+			if !ok {
+				return fmt.Errorf("invalid number for %s: %q", field, value)
+			}
+			number = sql.NullFloat64{Float64: low, Valid: true}
+		}
+		switch field {
+		case FieldTargetPrice:
+			p.TargetPrice = number
+		case FieldTargetChangePercent:
+			p.TargetChangePercent = number
+		default:
+			p.StopLoss = number
+		}
+	case FieldEntryPrice:
+		p.EntryPriceMin, p.EntryPriceMax = sql.NullFloat64{}, sql.NullFloat64{}
+		if !empty {
+			low, high, ok := parseRange(value)
+			if !ok {
+				return fmt.Errorf("invalid entry price: %q", value)
+			}
+			p.EntryPriceMin = sql.NullFloat64{Float64: low, Valid: true}
+			p.EntryPriceMax = sql.NullFloat64{Float64: high, Valid: true}
+		}
+	case FieldTakeProfitLevels:
+		p.TakeProfitLevels = nil
+		if !empty {
+			var levels ai.PriceLevels
+			if err := json.Unmarshal([]byte(fmt.Sprintf("%q", value)), &levels); err != nil || len(levels) == 0 {
+				return fmt.Errorf("invalid take profit levels: %q", value)
+			}
+			p.TakeProfitLevels = pq.Float64Array(levels)
+		}
+	default:
+		return fmt.Errorf("unknown field %q (editable: %s)", field, strings.Join(Fields, ", "))
+	}
+
+	d.Changes[field] = value
+	return nil
+}
+
+// MapStock сопоставляет прогноз с акцией из справочника. Тикер прогноза заменяется тикером акции.
+func (d *Draft) MapStock(stock *storage.Stock) {
+	d.Stock = stock
+	if !strings.EqualFold(d.Prediction.RawTicker.String, stock.Ticker) {
+		d.Prediction.RawTicker = sql.NullString{String: stock.Ticker, Valid: true}
+		d.Changes[FieldTicker] = stock.Ticker
+	}
+}
+
+// Accept формирует решение о принятии и прогноз для переноса в predictions.
+// Решение считается edited, если в прогноз вносились изменения.
+func (d *Draft) Accept(reviewer string, now time.Time) (*storage.PredictionReview, *storage.Prediction, error) {
+	if d.Stock == nil {
+		return nil, nil, ErrNoStock
+	}
+
+	decision := storage.ReviewDecisionAccepted
+	if len(d.Changes) > 0 {
+		decision = storage.ReviewDecisionEdited
+	}
+	review, err := d.review(decision, reviewer, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	review.StockID = sql.NullInt64{Int64: d.Stock.ID, Valid: true}
+
+	p := d.Prediction
+	prediction := &storage.Prediction{
+		MessageID:           p.MessageID,
+		ChannelID:           p.ChannelID,
+		StockID:             d.Stock.ID,
+		PredictionType:      p.PredictionType,
+		TargetPrice:         p.TargetPrice,
+		TargetChangePercent: p.TargetChangePercent,
+		EntryPriceMin:       p.EntryPriceMin,
+		EntryPriceMax:       p.EntryPriceMax,
+		StopLoss:            p.StopLoss,
+		TakeProfitLevels:    p.TakeProfitLevels,
+		Period:              p.Period,
+		Recommendation:      p.Recommendation,
+		Direction:           p.Direction,
+		JustificationText:   p.JustificationText,
+		EvidenceStatus:      p.EvidenceStatus,
+		EvidenceScore:       p.EvidenceScore,
+		EvidenceSpanStart:   p.EvidenceSpanStart,
+		EvidenceSpanEnd:     p.EvidenceSpanEnd,
+		Agreement:           p.Agreement,
+		Confidence:          p.Confidence,
+		Contextual:          p.Contextual,
+		RunID:               p.RunID,
+		ResultID:            p.ResultID,
+		PredictedAt:         p.PredictedAt,
+	}
+	return review, prediction, nil
+}
+
+// Reject формирует решение об отклонении прогноза.
+func (d *Draft) Reject(reviewer string, now time.Time) (*storage.PredictionReview, error) {
+	return d.review(storage.ReviewDecisionRejected, reviewer, now)
+}
+
+// NoSignal формирует решение об отклонении прогноза с подтверждением, что сигналов в сообщении нет.
+func (d *Draft) NoSignal(reviewer string, now time.Time) (*storage.PredictionReview, error) {
+	return d.review(storage.ReviewDecisionNoSignal, reviewer, now)
+}
+
+func (d *Draft) review(decision, reviewer string, now time.Time) (*storage.PredictionReview, error) {
+	review := &storage.PredictionReview{
+		RawPredictionID: d.Item.RawPrediction.ID,
+		Decision:        decision,
+		Reviewer:        reviewer,
+		ReviewedAt:      now,
+	}
+	if len(d.Changes) > 0 {
+		changes, err := json.Marshal(d.Changes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal review changes: %w", err)
+		}
+		review.Changes = changes
+	}
+	return review, nil
+}
+
+// ChangedFields возвращает отсортированный список исправленных полей.
+func (d *Draft) ChangedFields() []string {
+	fields := make([]string, 0, len(d.Changes))
+	for field := range d.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// parseRange разбирает число или диапазон чисел так же, как значения из ответа модели.
+func parseRange(value string) (low, high float64, ok bool) {
+	return ai.FlexibleStringOrNumber{StringValue: value, IsString: true}.Range()
+}
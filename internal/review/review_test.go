@@ -0,0 +1,79 @@
+package review
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDraft проверяет исправление, сопоставление и принятие прогноза из очереди проверки
+func TestDraft(t *testing.T) {
+	item := storage.ReviewItem{RawPrediction: storage.RawPrediction{
+		ID:             10,
+		MessageID:      42,
+		RawTicker:      sql.NullString{String: "Сбер", Valid: true},
+		Direction:      sql.NullString{String: "Лонг", Valid: true},
+		Recommendation: sql.NullString{String: "Покупать", Valid: true},
+		ReviewReason:   sql.NullString{String: storage.ReviewReasonUnresolvedTicker, Valid: true},
+	}}
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("Принятие без акции невозможно", func(t *testing.T) {
+		draft := NewDraft(item)
+		_, _, err := draft.Accept("alice", now)
+		assert.ErrorIs(t, err, ErrNoStock)
+	})
+
+	t.Run("Принятие с исправлениями", func(t *testing.T) {
+		draft := NewDraft(item)
+		assert.NoError(t, draft.Set(FieldEntryPrice, "250-255"))
+		assert.NoError(t, draft.Set(FieldTakeProfitLevels, "270/290"))
+		assert.NoError(t, draft.Set(FieldDirection, "null"))
+		assert.Error(t, draft.Set(FieldStopLoss, "нет"))
+		assert.Error(t, draft.Set("unknown", "1"))
+		draft.MapStock(&storage.Stock{ID: 7, Ticker: "SBER"})
+
+		review, prediction, err := draft.Accept("alice", now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, storage.ReviewDecisionEdited, review.Decision)
+		assert.Equal(t, int64(10), review.RawPredictionID)
+		assert.Equal(t, "alice", review.Reviewer)
+		assert.Equal(t, int64(7), review.StockID.Int64)
+		assert.JSONEq(t, `{"entry_price":"250-255","take_profit_levels":"270/290","direction":"null","ticker":"SBER"}`, string(review.Changes))
+		assert.Equal(t, []string{FieldDirection, FieldEntryPrice, FieldTakeProfitLevels, FieldTicker}, draft.ChangedFields())
+
+		assert.Equal(t, int64(42), prediction.MessageID)
+		assert.Equal(t, int64(7), prediction.StockID)
+		assert.Equal(t, 250.0, prediction.EntryPriceMin.Float64)
+		assert.Equal(t, 255.0, prediction.EntryPriceMax.Float64)
+		assert.Equal(t, []float64{270, 290}, []float64(prediction.TakeProfitLevels))
+		assert.False(t, prediction.Direction.Valid)
+		assert.Equal(t, "Покупать", prediction.Recommendation.String)
+
+		// Исходный прогноз из очереди не изменяется
+		assert.Equal(t, "Сбер", item.RawPrediction.RawTicker.String)
+	})
+
+	t.Run("Отклонение", func(t *testing.T) {
+		review, err := NewDraft(item).Reject("bob", now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, storage.ReviewDecisionRejected, review.Decision)
+		assert.Nil(t, review.Changes)
+		assert.False(t, review.StockID.Valid)
+	})
+
+	t.Run("Сигналов нет", func(t *testing.T) {
+		review, err := NewDraft(item).NoSignal("bob", now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, storage.ReviewDecisionNoSignal, review.Decision)
+		assert.Equal(t, item.RawPrediction.ID, review.RawPredictionID)
+		assert.False(t, review.StockID.Valid)
+	})
+}
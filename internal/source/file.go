@@ -0,0 +1,227 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Форматы файловых источников.
+const (
+	FormatAuto    = ""        // По имени: "-" — одно сообщение, *.jsonl и *.ndjson — JSONL, иначе строки
+	FormatLines   = "lines"   // Одно сообщение на строку
+	FormatJSONL   = "jsonl"   // Одна JSON-запись jsonlMessage на строку
+	FormatMessage = "message" // Весь ввод — одно сообщение
+)
+
+// Stdin — имя источника, читающего стандартный ввод.
+const Stdin = "-"
+
+// filePageSize — сколько сообщений файлового источника возвращается за один вызов Next.
+const filePageSize = 100
+
+// maxLineSize — максимальная длина строки файла; длинные посты Telegram не помещаются в буфер bufio.Scanner по умолчанию.
+const maxLineSize = 16 * 1024 * 1024
+
+// jsonlMessage — сообщение в JSONL-файле. Обязателен только text; без message_id сообщению присваивается номер строки.
+// Строка целиком сохраняется в raw_data, поэтому в ней можно передать reply_to и данные пересылки.
+type jsonlMessage struct {
+	ChannelID int64     `json:"channel_id"`
+	MessageID int64     `json:"message_id"`
+	Text      string    `json:"text"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// File читает сообщения из файла или стандартного ввода. Сообщениям без идентификаторов присваивается
+// канал 0 и номер строки (для одного сообщения — 1), времени отправки — время чтения.
+type File struct {
+	closer io.Closer // nil для стандартного ввода
+	next   func() (storage.Message, bool, error)
+	limit  int
+	read   int
+	now    func() time.Time
+}
+
+// Open открывает файловый источник: path — путь к файлу или Stdin, format — один из Format*. Не больше limit
+// сообщений (0 — без ограничения).
+func Open(path, format string, limit int) (*File, error) {
+	if format == FormatAuto {
+		format = detectFormat(path)
+	}
+
+	var r io.Reader = os.Stdin
+	var closer io.Closer
+	if path != Stdin {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+		r, closer = file, file
+	}
+
+	f, err := NewFile(r, format, limit)
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, err
+	}
+	f.closer = closer
+	return f, nil
+}
+
+// NewFile создает источник, читающий сообщения формата format из r.
+func NewFile(r io.Reader, format string, limit int) (*File, error) {
+	f := &File{limit: limit, now: time.Now}
+	switch format {
+	case FormatLines:
+		f.next = f.lines(r)
+	case FormatJSONL:
+		f.next = f.jsonl(r)
+	case FormatMessage:
+		f.next = f.message(r)
+	default:
+		return nil, fmt.Errorf("unknown input format %q: use lines, jsonl or message", format)
+	}
+	return f, nil
+}
+
+func detectFormat(path string) string {
+	if path == Stdin {
+		return FormatMessage
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return FormatLines
+	}
+}
+
+// Next читает следующую страницу сообщений. При отмене ctx возвращаются уже прочитанные сообщения.
+func (f *File) Next(ctx context.Context) ([]storage.Message, error) {
+	var messages []storage.Message
+	for len(messages) < filePageSize && (f.limit == 0 || f.read < f.limit) && ctx.Err() == nil {
+		message, ok, err := f.next()
+		if err != nil {
+			return messages, err
+		}
+		if !ok {
+			break
+		}
+		messages = append(messages, message)
+		f.read++
+	}
+	return messages, nil
+}
+
+// Close закрывает файл; стандартный ввод не закрывается.
+func (f *File) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// lines возвращает функцию чтения сообщений по одному на строку. Пустые строки пропускаются.
+func (f *File) lines(r io.Reader) func() (storage.Message, bool, error) {
+	scanner := newScanner(r)
+	lineNum := 0
+	return func() (storage.Message, bool, error) {
+		for scanner.Scan() {
+			lineNum++
+			if text := strings.TrimSpace(scanner.Text()); text != "" {
+				return f.newMessage(0, int64(lineNum), text), true, nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return storage.Message{}, false, fmt.Errorf("failed to read line %d: %w", lineNum+1, err)
+		}
+		return storage.Message{}, false, nil
+	}
+}
+
+// jsonl возвращает функцию чтения сообщений в формате jsonlMessage. Пустые строки и строки, начинающиеся с #,
+// пропускаются.
+func (f *File) jsonl(r io.Reader) func() (storage.Message, bool, error) {
+	scanner := newScanner(r)
+	lineNum := 0
+	return func() (storage.Message, bool, error) {
+		for scanner.Scan() {
+			lineNum++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			var record jsonlMessage
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				return storage.Message{}, false, fmt.Errorf("failed to parse line %d: %w", lineNum, err)
+			}
+			if strings.TrimSpace(record.Text) == "" {
+				return storage.Message{}, false, fmt.Errorf("line %d has no text", lineNum)
+			}
+			if record.MessageID == 0 {
+				record.MessageID = int64(lineNum)
+			}
+
+			message := f.newMessage(record.ChannelID, record.MessageID, record.Text)
+			if !record.SentAt.IsZero() {
+				message.SentAt = record.SentAt
+			}
+			message.RawData = []byte(line)
+			return message, true, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return storage.Message{}, false, fmt.Errorf("failed to read line %d: %w", lineNum+1, err)
+		}
+		return storage.Message{}, false, nil
+	}
+}
+
+// message возвращает функцию, читающую весь ввод как одно сообщение.
+func (f *File) message(r io.Reader) func() (storage.Message, bool, error) {
+	done := false
+	return func() (storage.Message, bool, error) {
+		if done {
+			return storage.Message{}, false, nil
+		}
+		done = true
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return storage.Message{}, false, fmt.Errorf("failed to read message: %w", err)
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			return storage.Message{}, false, fmt.Errorf("input contains no message text")
+		}
+		return f.newMessage(0, 1, text), true, nil
+	}
+}
+
+func (f *File) newMessage(channelID, messageID int64, text string) storage.Message {
+	now := f.now()
+	return storage.Message{
+		TelegramID: messageID,
+		ChannelID:  channelID,
+		Text:       sql.NullString{String: text, Valid: true},
+		SentAt:     now,
+		CreatedAt:  now,
+	}
+}
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return scanner
+}
@@ -0,0 +1,90 @@
+package source
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFile проверяет чтение сообщений в каждом из форматов
+func TestFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("строки", func(t *testing.T) {
+		f, err := NewFile(strings.NewReader("SBER на 300\n\n  GAZP шорт  \n"), FormatLines, 0)
+		assert.NoError(t, err)
+		messages, err := f.Next(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, messages, 2) {
+			assert.Equal(t, "SBER на 300", messages[0].Text.String)
+			assert.Equal(t, int64(1), messages[0].TelegramID)
+			assert.Equal(t, "GAZP шорт", messages[1].Text.String)
+			assert.Equal(t, int64(3), messages[1].TelegramID)
+		}
+		messages, err = f.Next(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("JSONL", func(t *testing.T) {
+		input := `{"channel_id":100,"message_id":42,"text":"SBER на 300","sent_at":"2024-03-01T10:00:00Z","reply_to":{"reply_to_msg_id":41}}
+# комментарий
+{"text":"GAZP шорт"}
+`
+		f, err := NewFile(strings.NewReader(input), FormatJSONL, 0)
+		assert.NoError(t, err)
+		messages, err := f.Next(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, messages, 2) {
+			assert.Equal(t, int64(100), messages[0].ChannelID)
+			assert.Equal(t, int64(42), messages[0].TelegramID)
+			assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), messages[0].SentAt)
+			replyTo, ok := messages[0].ReplyToID()
+			assert.True(t, ok)
+			assert.Equal(t, int64(41), replyTo)
+			assert.Equal(t, int64(3), messages[1].TelegramID)
+		}
+	})
+
+	t.Run("ошибка JSONL с номером строки", func(t *testing.T) {
+		f, err := NewFile(strings.NewReader("{\"text\":\"SBER\"}\n{\"text\":\"\"}\n"), FormatJSONL, 0)
+		assert.NoError(t, err)
+		_, err = f.Next(ctx)
+		assert.ErrorContains(t, err, "line 2 has no text")
+	})
+
+	t.Run("одно сообщение", func(t *testing.T) {
+		f, err := NewFile(strings.NewReader("SBER на 300\nстоп 270\n"), FormatMessage, 0)
+		assert.NoError(t, err)
+		messages, err := f.Next(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, "SBER на 300\nстоп 270", messages[0].Text.String)
+		}
+	})
+
+	t.Run("ограничение числа сообщений", func(t *testing.T) {
+		f, err := NewFile(strings.NewReader("a\nb\nc\n"), FormatLines, 2)
+		assert.NoError(t, err)
+		messages, err := f.Next(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 2)
+	})
+
+	t.Run("неизвестный формат", func(t *testing.T) {
+		_, err := NewFile(strings.NewReader(""), "xml", 0)
+		assert.Error(t, err)
+	})
+}
+
+// TestDetectFormat проверяет выбор формата по имени файла
+func TestDetectFormat(t *testing.T) {
+	assert.Equal(t, FormatMessage, detectFormat(Stdin))
+	assert.Equal(t, FormatJSONL, detectFormat(filepath.Join("data", "messages.JSONL")))
+	assert.Equal(t, FormatJSONL, detectFormat("messages.ndjson"))
+	assert.Equal(t, FormatLines, detectFormat("messages.txt"))
+}
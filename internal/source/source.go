@@ -0,0 +1,67 @@
+// Package source предоставляет источники сообщений для анализа: базу данных, текстовые файлы, JSONL и stdin.
+package source
+
+import (
+	"context"
+
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Source — источник сообщений для анализа. Сообщения читаются страницами, чтобы не держать выборку в памяти целиком.
+type Source interface {
+	// Next возвращает следующую страницу сообщений; пустая страница означает, что сообщения закончились.
+	Next(ctx context.Context) ([]storage.Message, error)
+	// Close освобождает ресурсы источника.
+	Close() error
+}
+
+// Postgres читает сообщения из базы данных постранично по ключу (sent_at, channel_id, telegram_id),
+// начиная после filter.After.
+type Postgres struct {
+	storage  storage.Storage
+	filter   storage.MessageFilter
+	limit    int // Сколько сообщений прочитать всего; 0 — без ограничения
+	pageSize int
+	read     int
+	done     bool
+}
+
+// NewPostgres создает источник выборки filter из storage. Не больше limit сообщений (0 — без ограничения)
+// читаются страницами по pageSize.
+func NewPostgres(storage storage.Storage, filter storage.MessageFilter, limit, pageSize int) *Postgres {
+	return &Postgres{storage: storage, filter: filter, limit: limit, pageSize: pageSize}
+}
+
+// Next читает следующую страницу выборки.
+func (p *Postgres) Next(ctx context.Context) ([]storage.Message, error) {
+	if p.done || (p.limit > 0 && p.read >= p.limit) {
+		return nil, nil
+	}
+
+	page := p.filter
+	page.Limit = p.pageSize
+	if p.limit > 0 && p.limit-p.read < p.pageSize {
+		page.Limit = p.limit - p.read
+	}
+	messages, err := p.storage.GetMessages(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	p.read += len(messages)
+	p.done = len(messages) < page.Limit
+	if len(messages) > 0 {
+		p.filter.After = messages[len(messages)-1].Cursor()
+	}
+	return messages, nil
+}
+
+// Cursor возвращает позицию последнего прочитанного сообщения, с которой можно продолжить выборку.
+func (p *Postgres) Cursor() *storage.MessageCursor {
+	return p.filter.After
+}
+
+// Close ничего не делает: хранилище закрывает его владелец.
+func (p *Postgres) Close() error {
+	return nil
+}
@@ -0,0 +1,59 @@
+package source
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rkata-ai/trade-radar/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedStorage отдает сообщения по курсору выборки, как keyset-запрос к базе.
+type pagedStorage struct {
+	storage.Storage
+	messages []storage.Message
+	queries  []storage.MessageFilter
+}
+
+func (p *pagedStorage) GetMessages(ctx context.Context, filter storage.MessageFilter) ([]storage.Message, error) {
+	p.queries = append(p.queries, filter)
+	start := 0
+	if filter.After != nil {
+		for i, message := range p.messages {
+			if message.TelegramID == filter.After.TelegramID {
+				start = i + 1
+			}
+		}
+	}
+	end := min(start+filter.Limit, len(p.messages))
+	return p.messages[start:end], nil
+}
+
+// TestPostgres проверяет постраничное чтение выборки с ограничением числа сообщений
+func TestPostgres(t *testing.T) {
+	db := &pagedStorage{}
+	sentAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for id := int64(1); id <= 5; id++ {
+		db.messages = append(db.messages, storage.Message{TelegramID: id, ChannelID: 100, SentAt: sentAt.Add(time.Duration(id) * time.Minute)})
+	}
+
+	src := NewPostgres(db, storage.MessageFilter{}, 4, 3)
+	var ids []int64
+	for {
+		messages, err := src.Next(context.Background())
+		assert.NoError(t, err)
+		if len(messages) == 0 {
+			break
+		}
+		for _, message := range messages {
+			ids = append(ids, message.TelegramID)
+		}
+	}
+
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids)
+	assert.Len(t, db.queries, 2)
+	assert.Equal(t, 1, db.queries[1].Limit)
+	assert.Equal(t, int64(4), src.Cursor().TelegramID)
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MessageFilter задает выборку сообщений для анализа. Пустые поля не ограничивают выборку.
+type MessageFilter struct {
+	ChannelIDs         []int64   // Только сообщения этих каналов
+	MessageIDs         []int64   // Только сообщения с этими telegram_id
+	SentFrom           time.Time // sent_at не раньше SentFrom
+	SentTo             time.Time // sent_at раньше SentTo
+	MessageType        string    // Только сообщения с этим message_type
+	WithoutPredictions bool      // Исключить проанализированные сообщения: с прогнозами, прогнозами на проверку, пропусками или результатом анализа
+	WithoutFailures    bool      // Исключить сообщения с нерешенными неудачами: их повторяет retry
+	WithPredictions    bool      // Только сообщения с действующими прогнозами
+	NewestFirst        bool      // Сначала новые сообщения; по умолчанию сначала старые
+	After              *MessageCursor
+	Limit              int // Размер страницы; 0 — без ограничения
+}
+
+// MessageCursor — позиция в выборке для постраничного обхода по ключу (sent_at, channel_id, telegram_id):
+// следующая страница начинается сразу после сообщения, на котором закончилась предыдущая.
+type MessageCursor struct {
+	SentAt     time.Time
+	ChannelID  int64
+	TelegramID int64
+}
+
+// Cursor возвращает позицию сообщения для запроса следующей страницы.
+func (m Message) Cursor() *MessageCursor {
+	return &MessageCursor{SentAt: m.SentAt, ChannelID: m.ChannelID, TelegramID: m.TelegramID}
+}
+
+// buildMessagesQuery строит запрос выборки сообщений по фильтру.
+func buildMessagesQuery(filter MessageFilter) (string, []any) {
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.WithoutPredictions {
+		conditions = append(conditions,
+			"NOT EXISTS (SELECT 1 FROM predictions p WHERE p.channel_id = m.channel_id AND p.message_id = m.telegram_id AND p.superseded_by IS NULL)",
+			"NOT EXISTS (SELECT 1 FROM message_skips s WHERE s.channel_id = m.channel_id AND s.message_id = m.telegram_id AND s.superseded_by IS NULL)",
+			// Сообщение, все прогнозы которого ушли на проверку (или были отклонены при ней), тоже проанализировано
+			"NOT EXISTS (SELECT 1 FROM raw_predictions rp WHERE rp.channel_id = m.channel_id AND rp.message_id = m.telegram_id AND rp.superseded_by IS NULL)",
+			"NOT EXISTS (SELECT 1 FROM analysis_results r WHERE r.channel_id = m.channel_id AND r.message_id = m.telegram_id)")
+	}
+	if filter.WithPredictions {
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM predictions p WHERE p.channel_id = m.channel_id AND p.message_id = m.telegram_id AND p.superseded_by IS NULL)")
+	}
+	if filter.WithoutFailures {
+		conditions = append(conditions,
+			"NOT EXISTS (SELECT 1 FROM analysis_failures f WHERE f.channel_id = m.channel_id AND f.message_id = m.telegram_id AND f.resolved_at IS NULL)")
+	}
+	if len(filter.ChannelIDs) > 0 {
+		conditions = append(conditions, "m.channel_id = ANY("+arg(pq.Int64Array(filter.ChannelIDs))+")")
+	}
+	if len(filter.MessageIDs) > 0 {
+		conditions = append(conditions, "m.telegram_id = ANY("+arg(pq.Int64Array(filter.MessageIDs))+")")
+	}
+	if !filter.SentFrom.IsZero() {
+		conditions = append(conditions, "m.sent_at >= "+arg(filter.SentFrom))
+	}
+	if !filter.SentTo.IsZero() {
+		conditions = append(conditions, "m.sent_at < "+arg(filter.SentTo))
+	}
+	if filter.MessageType != "" {
+		conditions = append(conditions, "m.message_type = "+arg(filter.MessageType))
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.NewestFirst {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(m.sent_at, m.channel_id, m.telegram_id) %s (%s, %s, %s)",
+			comparison, arg(filter.After.SentAt), arg(filter.After.ChannelID), arg(filter.After.TelegramID)))
+	}
+
+	var query strings.Builder
+	query.WriteString(`SELECT m.telegram_id, m.channel_id, m.text, m.sent_at, m.sender_username, m.is_forward, m.message_type, m.raw_data, m.created_at FROM messages m`)
+	if len(conditions) > 0 {
+		query.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+	fmt.Fprintf(&query, " ORDER BY m.sent_at %[1]s, m.channel_id %[1]s, m.telegram_id %[1]s", direction)
+	if filter.Limit > 0 {
+		query.WriteString(" LIMIT " + arg(filter.Limit))
+	}
+	return query.String(), args
+}
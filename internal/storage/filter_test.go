@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBuildMessagesQuery проверяет условия выборки и постраничный обход по ключу
+func TestBuildMessagesQuery(t *testing.T) {
+	t.Run("Без фильтров", func(t *testing.T) {
+		query, args := buildMessagesQuery(MessageFilter{})
+
+		assert.NotContains(t, query, "WHERE")
+		assert.Contains(t, query, "ORDER BY m.sent_at ASC, m.channel_id ASC, m.telegram_id ASC")
+		assert.NotContains(t, query, "LIMIT")
+		assert.Empty(t, args)
+	})
+
+	t.Run("Только сообщения с прогнозами", func(t *testing.T) {
+		query, _ := buildMessagesQuery(MessageFilter{WithPredictions: true})
+
+		assert.Contains(t, query, "WHERE EXISTS (SELECT 1 FROM predictions p WHERE p.channel_id = m.channel_id AND p.message_id = m.telegram_id AND p.superseded_by IS NULL)")
+	})
+
+	t.Run("Сообщение только с прогнозами на проверку не выбирается повторно", func(t *testing.T) {
+		query, _ := buildMessagesQuery(MessageFilter{WithoutPredictions: true})
+
+		assert.Contains(t, query, "NOT EXISTS (SELECT 1 FROM raw_predictions rp WHERE rp.channel_id = m.channel_id AND rp.message_id = m.telegram_id AND rp.superseded_by IS NULL)")
+		assert.Contains(t, query, "NOT EXISTS (SELECT 1 FROM analysis_results r WHERE r.channel_id = m.channel_id AND r.message_id = m.telegram_id)")
+	})
+
+	t.Run("Все фильтры и курсор", func(t *testing.T) {
+		from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+		cursor := &MessageCursor{SentAt: from.Add(time.Hour), ChannelID: 100, TelegramID: 7}
+
+		query, args := buildMessagesQuery(MessageFilter{
+			ChannelIDs:         []int64{100, 200},
+			MessageIDs:         []int64{7, 8},
+			SentFrom:           from,
+			SentTo:             to,
+			MessageType:        "text",
+			WithoutPredictions: true,
+			WithoutFailures:    true,
+			NewestFirst:        true,
+			After:              cursor,
+			Limit:              50,
+		})
+
+		assert.Contains(t, query, "NOT EXISTS (SELECT 1 FROM predictions p")
+		assert.Contains(t, query, "NOT EXISTS (SELECT 1 FROM message_skips s")
+		assert.Contains(t, query, "p.superseded_by IS NULL")
+		assert.Contains(t, query, "s.superseded_by IS NULL")
+		assert.Contains(t, query, "NOT EXISTS (SELECT 1 FROM analysis_failures f")
+		assert.Contains(t, query, "m.channel_id = ANY($1)")
+		assert.Contains(t, query, "m.telegram_id = ANY($2)")
+		assert.Contains(t, query, "m.sent_at >= $3 AND m.sent_at < $4")
+		assert.Contains(t, query, "m.message_type = $5")
+		assert.Contains(t, query, "(m.sent_at, m.channel_id, m.telegram_id) < ($6, $7, $8)")
+		assert.Contains(t, query, "ORDER BY m.sent_at DESC, m.channel_id DESC, m.telegram_id DESC LIMIT $9")
+		assert.Equal(t, []any{
+			pq.Int64Array{100, 200}, pq.Int64Array{7, 8}, from, to, "text",
+			cursor.SentAt, int64(100), int64(7), 50,
+		}, args)
+	})
+}
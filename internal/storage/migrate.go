@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// migrationFiles — SQL-миграции, встроенные в бинарник: команде migrate не нужен доступ к исходникам.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration — одна SQL-миграция; версия — имя файла без расширения, например "0001_prediction_levels".
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+// Migrations возвращает встроенные миграции в порядке применения.
+func Migrations() ([]Migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		content, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+		migrations = append(migrations, Migration{Version: version, SQL: string(content)})
+	}
+	return migrations, nil
+}
+
+// Migrate применяет встроенные миграции, которых еще нет в schema_migrations, и возвращает версии примененных.
+// Каждая миграция выполняется в своей транзакции вместе с записью версии. С dryRun миграции не применяются,
+// а возвращаются версии, ожидающие применения.
+func (p *PostgresStorage) Migrate(ctx context.Context, dryRun bool) ([]string, error) {
+	const op = "storage.Migrate"
+
+	_, err := p.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT        PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to create schema_migrations: %w", op, err)
+	}
+
+	applied := make(map[string]bool)
+	rows, err := p.db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get applied migrations: %w", op, err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: failed to scan migration version: %w", op, err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var versions []string
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if !dryRun {
+			if err := p.applyMigration(ctx, migration); err != nil {
+				return versions, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		versions = append(versions, migration.Version)
+	}
+	return versions, nil
+}
+
+func (p *PostgresStorage) applyMigration(ctx context.Context, migration Migration) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for migration %s: %w", migration.Version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", migration.Version, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, migration.Version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", migration.Version, err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMigrations проверяет, что встроенные миграции читаются по порядку
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(migrations), 8)
+	assert.Equal(t, "0001_prediction_levels", migrations[0].Version)
+	assert.Contains(t, migrations[0].SQL, "ALTER TABLE predictions")
+	for i := 1; i < len(migrations); i++ {
+		assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+	}
+}
@@ -0,0 +1,12 @@
+-- Зона входа, стоп-лосс и цели take-profit для прогнозов.
+ALTER TABLE predictions
+    ADD COLUMN IF NOT EXISTS entry_price_min    DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS entry_price_max    DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS stop_loss          DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS take_profit_levels DOUBLE PRECISION[];
+
+ALTER TABLE raw_predictions
+    ADD COLUMN IF NOT EXISTS entry_price_min    DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS entry_price_max    DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS stop_loss          DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS take_profit_levels DOUBLE PRECISION[];
@@ -0,0 +1,12 @@
+-- Результат проверки цитаты и тикера прогноза по исходному сообщению.
+ALTER TABLE predictions
+    ADD COLUMN IF NOT EXISTS evidence_status     TEXT,
+    ADD COLUMN IF NOT EXISTS evidence_score      DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS evidence_span_start INTEGER,
+    ADD COLUMN IF NOT EXISTS evidence_span_end   INTEGER;
+
+ALTER TABLE raw_predictions
+    ADD COLUMN IF NOT EXISTS evidence_status     TEXT,
+    ADD COLUMN IF NOT EXISTS evidence_score      DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS evidence_span_start INTEGER,
+    ADD COLUMN IF NOT EXISTS evidence_span_end   INTEGER;
@@ -0,0 +1,11 @@
+-- Сообщения, отсеянные предварительным фильтром. Причина сохраняется, чтобы оценивать долю ложных пропусков.
+CREATE TABLE IF NOT EXISTS message_skips (
+    id         BIGSERIAL PRIMARY KEY,
+    message_id BIGINT      NOT NULL,
+    channel_id BIGINT      NOT NULL,
+    reason     TEXT        NOT NULL,
+    skipped_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (channel_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS message_skips_reason_idx ON message_skips (reason);
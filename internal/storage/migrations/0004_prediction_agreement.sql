@@ -0,0 +1,7 @@
+-- Согласие участников ансамбля и причина отправки прогноза на ручную проверку.
+ALTER TABLE predictions
+    ADD COLUMN IF NOT EXISTS agreement DOUBLE PRECISION;
+
+ALTER TABLE raw_predictions
+    ADD COLUMN IF NOT EXISTS agreement     DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS review_reason TEXT;
@@ -0,0 +1,6 @@
+-- Итоговая уверенность прогноза (0..1): самооценка модели, проверка цитаты, сопоставление тикера и согласие ансамбля.
+ALTER TABLE predictions
+    ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION;
+
+ALTER TABLE raw_predictions
+    ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION;
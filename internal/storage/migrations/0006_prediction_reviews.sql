@@ -0,0 +1,12 @@
+-- Решения ручной проверки прогнозов из raw_predictions.
+CREATE TABLE IF NOT EXISTS prediction_reviews (
+    id                BIGSERIAL PRIMARY KEY,
+    raw_prediction_id BIGINT      NOT NULL REFERENCES raw_predictions (id) ON DELETE CASCADE,
+    decision          TEXT        NOT NULL, -- accepted, edited, rejected
+    reviewer          TEXT        NOT NULL,
+    stock_id          BIGINT REFERENCES stocks (id),
+    prediction_id     BIGINT REFERENCES predictions (id),
+    changes           JSONB,
+    reviewed_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (raw_prediction_id)
+);
@@ -0,0 +1,16 @@
+-- Эмбеддинги сообщений и связи повторов с исходными сообщениями.
+-- Векторы хранятся в float8[], близость считается в приложении, поэтому расширение pgvector не требуется.
+CREATE TABLE IF NOT EXISTS message_embeddings (
+    channel_id              BIGINT           NOT NULL,
+    message_id              BIGINT           NOT NULL,
+    model                   TEXT             NOT NULL,
+    embedding               DOUBLE PRECISION[] NOT NULL,
+    duplicate_of_channel_id BIGINT,
+    duplicate_of_message_id BIGINT,
+    similarity              DOUBLE PRECISION,
+    created_at              TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (channel_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS message_embeddings_duplicate_of_idx
+    ON message_embeddings (duplicate_of_channel_id, duplicate_of_message_id);
@@ -0,0 +1,6 @@
+-- Тикер прогноза взят из сообщения, на которое отвечает автор, а не из текста самого сообщения.
+ALTER TABLE predictions
+    ADD COLUMN IF NOT EXISTS contextual BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE raw_predictions
+    ADD COLUMN IF NOT EXISTS contextual BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Индекс для постраничной выборки сообщений по ключу (sent_at, channel_id, telegram_id) в обоих направлениях.
+CREATE INDEX IF NOT EXISTS messages_sent_at_keyset_idx
+    ON messages (sent_at, channel_id, telegram_id);
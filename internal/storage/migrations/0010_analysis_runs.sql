@@ -0,0 +1,49 @@
+-- Запуски анализа. Повторный анализ создает новый запуск, а результаты предыдущих запусков не удаляются,
+-- а помечаются замененными: superseded_by ссылается на запуск, который их заменил.
+CREATE TABLE IF NOT EXISTS analysis_runs (
+    id         BIGSERIAL   PRIMARY KEY,
+    reanalysis BOOLEAN     NOT NULL DEFAULT FALSE,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE predictions
+    ADD COLUMN IF NOT EXISTS run_id        BIGINT REFERENCES analysis_runs (id),
+    ADD COLUMN IF NOT EXISTS superseded_by BIGINT REFERENCES analysis_runs (id);
+
+ALTER TABLE raw_predictions
+    ADD COLUMN IF NOT EXISTS run_id        BIGINT REFERENCES analysis_runs (id),
+    ADD COLUMN IF NOT EXISTS superseded_by BIGINT REFERENCES analysis_runs (id);
+
+ALTER TABLE message_skips
+    ADD COLUMN IF NOT EXISTS run_id        BIGINT REFERENCES analysis_runs (id),
+    ADD COLUMN IF NOT EXISTS superseded_by BIGINT REFERENCES analysis_runs (id);
+
+-- Telegram ID сообщения уникален только в пределах канала, поэтому прогнозы хранят и канал. Для прогнозов,
+-- сохраненных раньше, канал восстанавливается по messages, если telegram_id встречается только в одном канале;
+-- у остальных он остается пустым, и такие прогнозы не относятся ни к одному сообщению.
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS channel_id BIGINT;
+ALTER TABLE raw_predictions ADD COLUMN IF NOT EXISTS channel_id BIGINT;
+
+WITH unique_messages AS (
+    SELECT telegram_id, MIN(channel_id) AS channel_id
+    FROM messages
+    GROUP BY telegram_id
+    HAVING COUNT(DISTINCT channel_id) = 1
+)
+UPDATE predictions p SET channel_id = um.channel_id
+FROM unique_messages um
+WHERE p.channel_id IS NULL AND um.telegram_id = p.message_id;
+
+WITH unique_messages AS (
+    SELECT telegram_id, MIN(channel_id) AS channel_id
+    FROM messages
+    GROUP BY telegram_id
+    HAVING COUNT(DISTINCT channel_id) = 1
+)
+UPDATE raw_predictions rp SET channel_id = um.channel_id
+FROM unique_messages um
+WHERE rp.channel_id IS NULL AND um.telegram_id = rp.message_id;
+
+CREATE INDEX IF NOT EXISTS predictions_current_idx ON predictions (channel_id, message_id) WHERE superseded_by IS NULL;
+CREATE INDEX IF NOT EXISTS predictions_run_idx ON predictions (run_id);
+CREATE INDEX IF NOT EXISTS raw_predictions_current_idx ON raw_predictions (channel_id, message_id) WHERE superseded_by IS NULL;
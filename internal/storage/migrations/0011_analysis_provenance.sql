@@ -0,0 +1,34 @@
+-- Происхождение результатов: какой моделью, промтом и сборкой выполнен запуск анализа и что модель ответила
+-- на каждое сообщение.
+ALTER TABLE analysis_runs
+    ADD COLUMN IF NOT EXISTS model              TEXT,
+    ADD COLUMN IF NOT EXISTS provider           TEXT,
+    ADD COLUMN IF NOT EXISTS prompt_version     TEXT,
+    ADD COLUMN IF NOT EXISTS prompt_hash        TEXT,  -- SHA-256 шаблона промта
+    ADD COLUMN IF NOT EXISTS sampling           JSONB, -- Параметры генерации: температура, top_p, num_predict, ансамбль
+    ADD COLUMN IF NOT EXISTS binary_version     TEXT,
+    ADD COLUMN IF NOT EXISTS finished_at        TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS messages_total     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS messages_failed    INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS messages_skipped   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS predictions_total  INTEGER NOT NULL DEFAULT 0;
+
+-- Результат анализа одного сообщения в запуске: необработанные ответы модели и статистика обращений к ней.
+CREATE TABLE IF NOT EXISTS analysis_results (
+    id                BIGSERIAL   PRIMARY KEY,
+    run_id            BIGINT      NOT NULL REFERENCES analysis_runs (id),
+    channel_id        BIGINT      NOT NULL,
+    message_id        BIGINT      NOT NULL,
+    raw_response      TEXT,
+    latency_ms        BIGINT,
+    requests          INTEGER     NOT NULL DEFAULT 0,
+    prompt_tokens     INTEGER     NOT NULL DEFAULT 0,
+    completion_tokens INTEGER     NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS analysis_results_message_idx ON analysis_results (channel_id, message_id);
+CREATE INDEX IF NOT EXISTS analysis_results_run_idx ON analysis_results (run_id);
+
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS result_id BIGINT REFERENCES analysis_results (id);
+ALTER TABLE raw_predictions ADD COLUMN IF NOT EXISTS result_id BIGINT REFERENCES analysis_results (id);
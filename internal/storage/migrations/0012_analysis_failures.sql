@@ -0,0 +1,19 @@
+-- Сообщения, которые не удалось проанализировать или сохранить. Запись одна на сообщение: повторные неудачи
+-- увеличивают attempts, а успешная попытка заполняет resolved_at. next_retry_at пуст, если попытки исчерпаны.
+CREATE TABLE IF NOT EXISTS analysis_failures (
+    id              BIGSERIAL   PRIMARY KEY,
+    channel_id      BIGINT      NOT NULL,
+    message_id      BIGINT      NOT NULL,
+    stage           TEXT        NOT NULL, -- analyze или save
+    error_class     TEXT        NOT NULL,
+    error_message   TEXT        NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 1,
+    run_id          BIGINT      REFERENCES analysis_runs (id),
+    first_failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_failed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    next_retry_at   TIMESTAMPTZ,
+    resolved_at     TIMESTAMPTZ,
+    UNIQUE (channel_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS analysis_failures_due_idx ON analysis_failures (next_retry_at) WHERE resolved_at IS NULL;
@@ -0,0 +1,5 @@
+-- Уникальность сообщения в канале: импорт экспортов Telegram добавляет сообщения с ON CONFLICT DO NOTHING,
+-- поэтому повторный импорт того же экспорта ничего не дублирует. Если в таблице уже есть дубликаты, их нужно
+-- удалить до применения миграции.
+CREATE UNIQUE INDEX IF NOT EXISTS messages_channel_telegram_id_idx
+    ON messages (channel_id, telegram_id);
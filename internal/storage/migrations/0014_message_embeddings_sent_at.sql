@@ -0,0 +1,10 @@
+-- Время отправки сообщения рядом с его вектором: исходным для повтора может быть только сообщение, отправленное
+-- раньше него, даже если повтор был проанализирован первым. Векторы сообщений, которых нет в messages, остаются
+-- без времени и исходными не считаются.
+ALTER TABLE message_embeddings
+    ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ;
+
+UPDATE message_embeddings e
+SET sent_at = m.sent_at
+FROM messages m
+WHERE e.sent_at IS NULL AND m.channel_id = e.channel_id AND m.telegram_id = e.message_id;
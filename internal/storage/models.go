@@ -2,7 +2,10 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Message struct {
@@ -17,18 +20,62 @@ type Message struct {
 	CreatedAt      time.Time      `db:"created_at"`
 }
 
+// ReplyToID возвращает ID сообщения, на которое отвечает сообщение, из raw_data: reply_to.reply_to_msg_id
+// из Telethon, reply_to_message.message_id из Bot API или reply_to_message_id из экспорта Telegram Desktop.
+func (m Message) ReplyToID() (int64, bool) {
+	if len(m.RawData) == 0 {
+		return 0, false
+	}
+	var raw struct {
+		ReplyTo *struct {
+			ReplyToMsgID int64 `json:"reply_to_msg_id"`
+		} `json:"reply_to"`
+		ReplyToMessage *struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"reply_to_message"`
+		ReplyToMessageID int64 `json:"reply_to_message_id"`
+	}
+	if err := json.Unmarshal(m.RawData, &raw); err != nil {
+		return 0, false
+	}
+	switch {
+	case raw.ReplyTo != nil && raw.ReplyTo.ReplyToMsgID != 0:
+		return raw.ReplyTo.ReplyToMsgID, true
+	case raw.ReplyToMessage != nil && raw.ReplyToMessage.MessageID != 0:
+		return raw.ReplyToMessage.MessageID, true
+	case raw.ReplyToMessageID != 0:
+		return raw.ReplyToMessageID, true
+	}
+	return 0, false
+}
+
 // Prediction представляет структуру для хранения предсказаний в базе данных.
 type Prediction struct {
 	ID                  int64           `db:"id"`
 	MessageID           int64           `db:"message_id"`
+	ChannelID           sql.NullInt64   `db:"channel_id"` // NULL у старых прогнозов, канал которых не удалось восстановить
 	StockID             int64           `db:"stock_id"`
 	PredictionType      sql.NullString  `db:"prediction_type"`
 	TargetPrice         sql.NullFloat64 `db:"target_price"`
 	TargetChangePercent sql.NullFloat64 `db:"target_change_percent"`
+	EntryPriceMin       sql.NullFloat64 `db:"entry_price_min"`
+	EntryPriceMax       sql.NullFloat64 `db:"entry_price_max"`
+	StopLoss            sql.NullFloat64 `db:"stop_loss"`
+	TakeProfitLevels    pq.Float64Array `db:"take_profit_levels"` // Цели в порядке их указания в сообщении
 	Period              sql.NullString  `db:"period"`
 	Recommendation      sql.NullString  `db:"recommendation"`
 	Direction           sql.NullString  `db:"direction"`
 	JustificationText   sql.NullString  `db:"justification_text"`
+	EvidenceStatus      sql.NullString  `db:"evidence_status"`
+	EvidenceScore       sql.NullFloat64 `db:"evidence_score"`
+	EvidenceSpanStart   sql.NullInt64   `db:"evidence_span_start"` // Смещение цитаты в символах исходного сообщения
+	EvidenceSpanEnd     sql.NullInt64   `db:"evidence_span_end"`
+	Agreement           sql.NullFloat64 `db:"agreement"`     // Доля согласных участников ансамбля
+	Confidence          sql.NullFloat64 `db:"confidence"`    // Итоговая уверенность прогноза 0..1
+	Contextual          bool            `db:"contextual"`    // Тикер взят из сообщения, на которое отвечает автор
+	RunID               sql.NullInt64   `db:"run_id"`        // Запуск анализа, создавший прогноз
+	ResultID            sql.NullInt64   `db:"result_id"`     // Результат анализа сообщения с ответом модели
+	SupersededBy        sql.NullInt64   `db:"superseded_by"` // Запуск повторного анализа, заменивший прогноз
 	PredictedAt         time.Time       `db:"predicted_at"`
 }
 
@@ -50,14 +97,190 @@ type Stock struct {
 type RawPrediction struct {
 	ID                  int64
 	MessageID           int64
+	ChannelID           sql.NullInt64
 	RawTicker           sql.NullString
 	PredictionType      sql.NullString
 	TargetPrice         sql.NullFloat64
 	TargetChangePercent sql.NullFloat64
+	EntryPriceMin       sql.NullFloat64
+	EntryPriceMax       sql.NullFloat64
+	StopLoss            sql.NullFloat64
+	TakeProfitLevels    pq.Float64Array
 	Period              sql.NullString
 	Recommendation      sql.NullString
 	Direction           sql.NullString
 	JustificationText   sql.NullString
+	EvidenceStatus      sql.NullString
+	EvidenceScore       sql.NullFloat64
+	EvidenceSpanStart   sql.NullInt64
+	EvidenceSpanEnd     sql.NullInt64
+	Agreement           sql.NullFloat64
+	Confidence          sql.NullFloat64
+	Contextual          bool
+	ReviewReason        sql.NullString // Почему прогноз не попал в predictions: ReviewReason*
+	RunID               sql.NullInt64
+	ResultID            sql.NullInt64
+	SupersededBy        sql.NullInt64
 	PredictedAt         time.Time
 	CreatedAt           time.Time
 }
+
+// Причины, по которым прогноз сохраняется в raw_predictions для ручной проверки.
+const (
+	ReviewReasonUnresolvedTicker   = "unresolved_ticker"
+	ReviewReasonUnverifiedEvidence = "unverified_evidence"
+	ReviewReasonLowAgreement       = "low_agreement"
+	ReviewReasonLowConfidence      = "low_confidence"
+)
+
+// MessageSkip фиксирует сообщение, отсеянное предварительным фильтром, и причину пропуска.
+type MessageSkip struct {
+	ID        int64         `db:"id"`
+	MessageID int64         `db:"message_id"`
+	ChannelID int64         `db:"channel_id"`
+	Reason    string        `db:"reason"`
+	RunID     sql.NullInt64 `db:"run_id"`
+	SkippedAt time.Time     `db:"skipped_at"`
+}
+
+// LatestRun выбирает в запросах действующие результаты: не замененные повторным анализом.
+const LatestRun int64 = 0
+
+// AnalysisRun — один запуск анализа. Результаты запуска ссылаются на него через run_id.
+// Поля модели и промта позволяют установить, чем получен каждый прогноз.
+type AnalysisRun struct {
+	ID               int64          `db:"id"`
+	Reanalysis       bool           `db:"reanalysis"` // Повторный анализ: прежние результаты сообщений помечаются замененными
+	Model            sql.NullString `db:"model"`
+	Provider         sql.NullString `db:"provider"`
+	PromptVersion    sql.NullString `db:"prompt_version"`
+	PromptHash       sql.NullString `db:"prompt_hash"`    // SHA-256 шаблона промта в hex
+	Sampling         []byte         `db:"sampling"`       // JSONB: параметры генерации
+	BinaryVersion    sql.NullString `db:"binary_version"` // Версия сборки trade-radar
+	StartedAt        time.Time      `db:"started_at"`
+	FinishedAt       sql.NullTime   `db:"finished_at"`
+	MessagesTotal    int            `db:"messages_total"`
+	MessagesFailed   int            `db:"messages_failed"`
+	MessagesSkipped  int            `db:"messages_skipped"`
+	PredictionsTotal int            `db:"predictions_total"`
+}
+
+// MessageResults — результаты анализа одного сообщения, которые SaveMessageResults сохраняет одной транзакцией.
+type MessageResults struct {
+	ChannelID      int64
+	MessageID      int64
+	SupersedeRunID int64           // Если не 0, прежние результаты сообщения помечаются замененными этим запуском
+	Result         *AnalysisResult // Результат анализа в рамках запуска; nil — без запуска
+	Skip           *MessageSkip    // Пропуск сообщения; nil — сообщение не пропущено
+	Predictions    []Prediction
+	RawPredictions []RawPrediction
+}
+
+// AnalysisResult — результат анализа одного сообщения в запуске: необработанные ответы модели, время и токены.
+// Прогнозы, полученные из ответа, ссылаются на него через result_id.
+type AnalysisResult struct {
+	ID               int64          `db:"id"`
+	RunID            int64          `db:"run_id"`
+	ChannelID        int64          `db:"channel_id"`
+	MessageID        int64          `db:"message_id"`
+	RawResponse      sql.NullString `db:"raw_response"` // Ответы модели по порядку запросов
+	LatencyMs        sql.NullInt64  `db:"latency_ms"`
+	Requests         int            `db:"requests"`
+	PromptTokens     int            `db:"prompt_tokens"`
+	CompletionTokens int            `db:"completion_tokens"`
+	CreatedAt        time.Time      `db:"created_at"`
+}
+
+// Этапы, на которых анализ сообщения завершился неудачей.
+const (
+	FailureStageAnalyze = "analyze" // Модель не ответила или ответ не разобран
+	FailureStageSave    = "save"    // Результат не удалось записать
+)
+
+// AnalysisFailure — неудачный анализ сообщения, ожидающий повторной попытки.
+type AnalysisFailure struct {
+	ID            int64         `db:"id"`
+	ChannelID     int64         `db:"channel_id"`
+	MessageID     int64         `db:"message_id"`
+	Stage         string        `db:"stage"`         // FailureStage*
+	ErrorClass    string        `db:"error_class"`   // Класс ошибки, см. retry.Class*
+	ErrorMessage  string        `db:"error_message"` // Текст последней ошибки
+	Attempts      int           `db:"attempts"`
+	RunID         sql.NullInt64 `db:"run_id"` // Запуск анализа последней попытки
+	FirstFailedAt time.Time     `db:"first_failed_at"`
+	LastFailedAt  time.Time     `db:"last_failed_at"`
+	NextRetryAt   sql.NullTime  `db:"next_retry_at"` // Пусто, если попытки исчерпаны
+	ResolvedAt    sql.NullTime  `db:"resolved_at"`
+}
+
+// FailureSummary — сводка неудачных анализов по классу ошибки.
+type FailureSummary struct {
+	ErrorClass   string
+	Pending      int       // Ожидают повторной попытки
+	Exhausted    int       // Попытки исчерпаны
+	Resolved     int       // Успешно повторены
+	MaxAttempts  int       // Наибольшее число попыток среди нерешенных
+	LastFailedAt time.Time // Время последней неудачи
+	LastError    string    // Текст последней ошибки
+}
+
+// Решения ручной проверки прогноза.
+const (
+	ReviewDecisionAccepted = "accepted"  // Прогноз принят без изменений
+	ReviewDecisionEdited   = "edited"    // Прогноз принят после исправления полей или тикера
+	ReviewDecisionRejected = "rejected"  // Прогноз отклонен
+	ReviewDecisionNoSignal = "no_signal" // Прогноз отклонен, и проверяющий подтвердил, что сигналов в сообщении нет
+)
+
+// PredictionReview фиксирует решение проверяющего по прогнозу из raw_predictions.
+type PredictionReview struct {
+	ID              int64         `db:"id"`
+	RawPredictionID int64         `db:"raw_prediction_id"`
+	Decision        string        `db:"decision"`
+	Reviewer        string        `db:"reviewer"`
+	StockID         sql.NullInt64 `db:"stock_id"`
+	PredictionID    sql.NullInt64 `db:"prediction_id"` // Прогноз, созданный в predictions при принятии
+	Changes         []byte        `db:"changes"`       // JSONB: исправленные поля и их новые значения
+	ReviewedAt      time.Time     `db:"reviewed_at"`
+}
+
+// ReviewItem — прогноз, ожидающий проверки, вместе с текстом исходного сообщения.
+type ReviewItem struct {
+	RawPrediction RawPrediction
+	ChannelID     sql.NullInt64
+	MessageText   sql.NullString
+	SentAt        sql.NullTime
+}
+
+// ReviewedMessage — сообщение, все прогнозы которого прошли ручную проверку, вместе с прогнозами, которые приняли
+// проверяющие (accepted или edited). NoSignal означает, что проверяющий подтвердил отсутствие сигналов в сообщении.
+type ReviewedMessage struct {
+	Message     Message
+	Predictions []StockPrediction
+	NoSignal    bool
+}
+
+// StockPrediction — прогноз из predictions вместе с тикером акции.
+type StockPrediction struct {
+	Prediction Prediction
+	Ticker     string
+}
+
+// MessageEmbedding хранит вектор сообщения и, если сообщение признано повтором, ссылку на исходное сообщение.
+type MessageEmbedding struct {
+	ChannelID            int64           `db:"channel_id"`
+	MessageID            int64           `db:"message_id"`
+	Model                string          `db:"model"`
+	Embedding            pq.Float64Array `db:"embedding"`
+	DuplicateOfChannelID sql.NullInt64   `db:"duplicate_of_channel_id"`
+	DuplicateOfMessageID sql.NullInt64   `db:"duplicate_of_message_id"`
+	Similarity           sql.NullFloat64 `db:"similarity"`
+	SentAt               sql.NullTime    `db:"sent_at"` // Время отправки сообщения
+	CreatedAt            time.Time       `db:"created_at"`
+}
+
+// ChannelSignal — прогноз из predictions вместе с сообщением, в котором он опубликован.
+type ChannelSignal struct {
+	Message    Message
+	Prediction StockPrediction
+}
@@ -8,7 +8,7 @@ import (
 
 	"rkata-ai/trade-radar/internal/config"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // PostgresStorage реализует интерфейс Storage для PostgreSQL
@@ -41,28 +41,22 @@ func NewPostgresStorage(cfg *config.DatabaseConfig) (Storage, error) {
 }
 
 func (p *PostgresStorage) GetMessagesWithoutPredictions(ctx context.Context, limit int) ([]Message, error) {
-	const op = "storage.GetMessagesWithoutPredictions"
+	return p.GetMessages(ctx, MessageFilter{WithoutPredictions: true, Limit: limit})
+}
 
-	messages := []Message{}
-	query := `
-		SELECT
-			m.telegram_id, m.channel_id, m.text, m.sent_at, m.sender_username, m.is_forward, m.message_type, m.raw_data, m.created_at
-		FROM
-			messages m
-		LEFT JOIN
-			predictions p ON m.telegram_id  = p.message_id
-		WHERE
-			p.id IS NULL
-		ORDER BY
-			m.sent_at ASC
-		LIMIT $1
-	`
-	rows, err := p.db.QueryContext(ctx, query, limit)
+// GetMessages возвращает страницу сообщений по фильтру. Следующая страница запрашивается с After,
+// равным Cursor последнего сообщения страницы; пустая страница означает конец выборки.
+func (p *PostgresStorage) GetMessages(ctx context.Context, filter MessageFilter) ([]Message, error) {
+	const op = "storage.GetMessages"
+
+	query, args := buildMessagesQuery(filter)
+	rows, err := p.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get messages without predictions: %w", err)
+		return nil, fmt.Errorf("%s: failed to get messages: %w", op, err)
 	}
 	defer rows.Close()
 
+	messages := []Message{}
 	for rows.Next() {
 		message := Message{}
 		err := rows.Scan(
@@ -89,35 +83,149 @@ func (p *PostgresStorage) GetMessagesWithoutPredictions(ctx context.Context, lim
 	return messages, nil
 }
 
+// GetMessage возвращает сообщение канала channelID с идентификатором telegramID.
+func (p *PostgresStorage) GetMessage(ctx context.Context, channelID, telegramID int64) (*Message, error) {
+	const op = "storage.GetMessage"
+
+	var message Message
+	query := `
+		SELECT telegram_id, channel_id, text, sent_at, sender_username, is_forward, message_type, raw_data, created_at
+		FROM messages
+		WHERE channel_id = $1 AND telegram_id = $2
+	`
+
+	err := p.db.QueryRowContext(ctx, query, channelID, telegramID).Scan(
+		&message.TelegramID,
+		&message.ChannelID,
+		&message.Text,
+		&message.SentAt,
+		&message.SenderUsername,
+		&message.IsForward,
+		&message.MessageType,
+		&message.RawData,
+		&message.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: message %d in channel %d not found: %w", op, telegramID, channelID, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: failed to get message: %w", op, err)
+	}
+
+	return &message, nil
+}
+
+// SaveMessages добавляет сообщения в одной транзакции. Сообщения, уже сохраненные с тем же (channel_id, telegram_id),
+// не изменяются, поэтому повторный импорт ничего не дублирует. Возвращает число добавленных сообщений.
+func (p *PostgresStorage) SaveMessages(ctx context.Context, messages []Message) (int, error) {
+	const op = "storage.SaveMessages"
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO messages (telegram_id, channel_id, text, sent_at, sender_username, is_forward, message_type, raw_data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (channel_id, telegram_id) DO NOTHING
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to prepare insert: %w", op, err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, message := range messages {
+		result, err := stmt.ExecContext(ctx,
+			message.TelegramID,
+			message.ChannelID,
+			message.Text,
+			message.SentAt,
+			message.SenderUsername,
+			message.IsForward,
+			message.MessageType,
+			message.RawData,
+			message.CreatedAt,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to save message %d: %w", op, message.TelegramID, err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+		}
+		inserted += int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return inserted, nil
+}
+
 func (p *PostgresStorage) SavePrediction(ctx context.Context, prediction *Prediction) error {
 	const op = "storage.SavePrediction"
 
+	if err := insertPrediction(ctx, p.db, prediction); err != nil {
+		return fmt.Errorf("%s: failed to save prediction: %w", op, err)
+	}
+
+	return nil
+}
+
+// queryRower — общая часть *sql.DB и *sql.Tx, позволяющая выполнять вставки как отдельно, так и в транзакции.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertPrediction вставляет прогноз в predictions и записывает присвоенный ID.
+func insertPrediction(ctx context.Context, q queryRower, prediction *Prediction) error {
 	query := `
 		INSERT INTO predictions (
 			message_id, stock_id, prediction_type, target_price, 
-			target_change_percent, period, recommendation, direction, 
-			justification_text, predicted_at
+			target_change_percent, entry_price_min, entry_price_max, stop_loss,
+			take_profit_levels, period, recommendation, direction, 
+			justification_text, evidence_status, evidence_score,
+			evidence_span_start, evidence_span_end, agreement, confidence, contextual, run_id, result_id, predicted_at,
+			channel_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24
 		) RETURNING id
 	`
 
 	var lastInsertID int64
-	err := p.db.QueryRowContext(ctx, query,
+	err := q.QueryRowContext(ctx, query,
 		prediction.MessageID,
 		prediction.StockID,
 		prediction.PredictionType,
 		prediction.TargetPrice,
 		prediction.TargetChangePercent,
+		prediction.EntryPriceMin,
+		prediction.EntryPriceMax,
+		prediction.StopLoss,
+		prediction.TakeProfitLevels,
 		prediction.Period,
 		prediction.Recommendation,
 		prediction.Direction,
 		prediction.JustificationText,
+		prediction.EvidenceStatus,
+		prediction.EvidenceScore,
+		prediction.EvidenceSpanStart,
+		prediction.EvidenceSpanEnd,
+		prediction.Agreement,
+		prediction.Confidence,
+		prediction.Contextual,
+		prediction.RunID,
+		prediction.ResultID,
 		prediction.PredictedAt,
+		prediction.ChannelID,
 	).Scan(&lastInsertID)
 
 	if err != nil {
-		return fmt.Errorf("%s: failed to save prediction: %w", op, err)
+		return err
 	}
 
 	prediction.ID = lastInsertID
@@ -156,32 +264,59 @@ func (p *PostgresStorage) GetStock(ctx context.Context, ticker string) (*Stock,
 func (p *PostgresStorage) SaveRawPrediction(ctx context.Context, rawPrediction *RawPrediction) error {
 	const op = "storage.SaveRawPrediction"
 
+	if err := insertRawPrediction(ctx, p.db, rawPrediction); err != nil {
+		return fmt.Errorf("%s: failed to save raw prediction: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertRawPrediction вставляет прогноз в raw_predictions и записывает присвоенный ID.
+func insertRawPrediction(ctx context.Context, q queryRower, rawPrediction *RawPrediction) error {
 	query := `
 		INSERT INTO raw_predictions (
 			message_id, raw_ticker, prediction_type, target_price,
-			target_change_percent, period, recommendation, direction,
-			justification_text, predicted_at
+			target_change_percent, entry_price_min, entry_price_max, stop_loss,
+			take_profit_levels, period, recommendation, direction,
+			justification_text, evidence_status, evidence_score,
+			evidence_span_start, evidence_span_end, agreement, confidence, contextual, review_reason, run_id, result_id, predicted_at,
+			channel_id
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25
 		) RETURNING id
 	`
 
 	var lastInsertID int64
-	err := p.db.QueryRowContext(ctx, query,
+	err := q.QueryRowContext(ctx, query,
 		rawPrediction.MessageID,
 		rawPrediction.RawTicker,
 		rawPrediction.PredictionType,
 		rawPrediction.TargetPrice,
 		rawPrediction.TargetChangePercent,
+		rawPrediction.EntryPriceMin,
+		rawPrediction.EntryPriceMax,
+		rawPrediction.StopLoss,
+		rawPrediction.TakeProfitLevels,
 		rawPrediction.Period,
 		rawPrediction.Recommendation,
 		rawPrediction.Direction,
 		rawPrediction.JustificationText,
+		rawPrediction.EvidenceStatus,
+		rawPrediction.EvidenceScore,
+		rawPrediction.EvidenceSpanStart,
+		rawPrediction.EvidenceSpanEnd,
+		rawPrediction.Agreement,
+		rawPrediction.Confidence,
+		rawPrediction.Contextual,
+		rawPrediction.ReviewReason,
+		rawPrediction.RunID,
+		rawPrediction.ResultID,
 		rawPrediction.PredictedAt,
+		rawPrediction.ChannelID,
 	).Scan(&lastInsertID)
 
 	if err != nil {
-		return fmt.Errorf("%s: failed to save raw prediction: %w", op, err)
+		return err
 	}
 
 	rawPrediction.ID = lastInsertID
@@ -189,6 +324,786 @@ func (p *PostgresStorage) SaveRawPrediction(ctx context.Context, rawPrediction *
 	return nil
 }
 
+func (p *PostgresStorage) SaveMessageSkip(ctx context.Context, skip *MessageSkip) error {
+	const op = "storage.SaveMessageSkip"
+
+	if err := insertMessageSkip(ctx, p.db, skip); err != nil {
+		return fmt.Errorf("%s: failed to save message skip: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertMessageSkip записывает пропуск сообщения; повторный пропуск заменяет прежний.
+func insertMessageSkip(ctx context.Context, q queryRower, skip *MessageSkip) error {
+	query := `
+		INSERT INTO message_skips (message_id, channel_id, reason, run_id, skipped_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (channel_id, message_id) DO UPDATE SET
+			reason = EXCLUDED.reason,
+			run_id = EXCLUDED.run_id,
+			superseded_by = NULL,
+			skipped_at = EXCLUDED.skipped_at
+		RETURNING id
+	`
+
+	var lastInsertID int64
+	err := q.QueryRowContext(ctx, query,
+		skip.MessageID,
+		skip.ChannelID,
+		skip.Reason,
+		skip.RunID,
+		skip.SkippedAt,
+	).Scan(&lastInsertID)
+
+	if err != nil {
+		return err
+	}
+
+	skip.ID = lastInsertID
+
+	return nil
+}
+
+// GetPendingReviews возвращает действующие прогнозы из raw_predictions, по которым еще нет решения, вместе с текстом
+// сообщения из того же канала. Прогнозы, замененные повторным анализом, не выдаются. Сначала выдаются самые старые прогнозы.
+func (p *PostgresStorage) GetPendingReviews(ctx context.Context, limit int) ([]ReviewItem, error) {
+	const op = "storage.GetPendingReviews"
+
+	items := []ReviewItem{}
+	query := `
+		SELECT
+			rp.id, rp.message_id, rp.raw_ticker, rp.prediction_type, rp.target_price, rp.target_change_percent,
+			rp.entry_price_min, rp.entry_price_max, rp.stop_loss, rp.take_profit_levels, rp.period,
+			rp.recommendation, rp.direction, rp.justification_text, rp.evidence_status, rp.evidence_score,
+			rp.evidence_span_start, rp.evidence_span_end, rp.agreement, rp.confidence, rp.contextual, rp.review_reason,
+			rp.run_id, rp.result_id, rp.predicted_at, rp.channel_id, m.channel_id, m.text, m.sent_at
+		FROM
+			raw_predictions rp
+		LEFT JOIN
+			messages m ON m.channel_id = rp.channel_id AND m.telegram_id = rp.message_id
+		LEFT JOIN
+			prediction_reviews r ON r.raw_prediction_id = rp.id
+		WHERE
+			r.id IS NULL AND rp.superseded_by IS NULL
+		ORDER BY
+			rp.predicted_at ASC
+		LIMIT $1
+	`
+	rows, err := p.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get pending reviews: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := ReviewItem{}
+		raw := &item.RawPrediction
+		err := rows.Scan(
+			&raw.ID,
+			&raw.MessageID,
+			&raw.RawTicker,
+			&raw.PredictionType,
+			&raw.TargetPrice,
+			&raw.TargetChangePercent,
+			&raw.EntryPriceMin,
+			&raw.EntryPriceMax,
+			&raw.StopLoss,
+			&raw.TakeProfitLevels,
+			&raw.Period,
+			&raw.Recommendation,
+			&raw.Direction,
+			&raw.JustificationText,
+			&raw.EvidenceStatus,
+			&raw.EvidenceScore,
+			&raw.EvidenceSpanStart,
+			&raw.EvidenceSpanEnd,
+			&raw.Agreement,
+			&raw.Confidence,
+			&raw.Contextual,
+			&raw.ReviewReason,
+			&raw.RunID,
+			&raw.ResultID,
+			&raw.PredictedAt,
+			&raw.ChannelID,
+			&item.ChannelID,
+			&item.MessageText,
+			&item.SentAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan review row: %w", op, err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return items, nil
+}
+
+// SaveReview сохраняет решение проверяющего. Если передан prediction, он в той же транзакции
+// добавляется в predictions, а его ID записывается в решение.
+func (p *PostgresStorage) SaveReview(ctx context.Context, review *PredictionReview, prediction *Prediction) error {
+	const op = "storage.SaveReview"
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if prediction != nil {
+		if err := insertPrediction(ctx, tx, prediction); err != nil {
+			return fmt.Errorf("%s: failed to promote prediction: %w", op, err)
+		}
+		review.PredictionID = sql.NullInt64{Int64: prediction.ID, Valid: true}
+	}
+
+	query := `
+		INSERT INTO prediction_reviews (raw_prediction_id, decision, reviewer, stock_id, prediction_id, changes, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	var lastInsertID int64
+	err = tx.QueryRowContext(ctx, query,
+		review.RawPredictionID,
+		review.Decision,
+		review.Reviewer,
+		review.StockID,
+		review.PredictionID,
+		review.Changes,
+		review.ReviewedAt,
+	).Scan(&lastInsertID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to save review: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit review: %w", op, err)
+	}
+
+	review.ID = lastInsertID
+
+	return nil
+}
+
+// GetReviewedMessages возвращает сообщения, по которым есть решения проверяющих accepted, edited или no_signal
+// и не осталось непроверенных raw-прогнозов, вместе с прогнозами, которые проверяющие приняли. Прогнозы, попавшие
+// в predictions без проверки, не возвращаются: их никто не подтверждал.
+func (p *PostgresStorage) GetReviewedMessages(ctx context.Context) ([]ReviewedMessage, error) {
+	const op = "storage.GetReviewedMessages"
+
+	query := `
+		SELECT
+			m.telegram_id, m.channel_id, m.text, m.sent_at, bool_or(r.decision = $1) AS no_signal
+		FROM
+			raw_predictions rp
+		JOIN
+			prediction_reviews r ON r.raw_prediction_id = rp.id
+		JOIN
+			messages m ON m.channel_id = rp.channel_id AND m.telegram_id = rp.message_id
+		WHERE rp.superseded_by IS NULL AND r.decision = ANY($2) AND NOT EXISTS (
+			SELECT 1
+			FROM raw_predictions pending
+			LEFT JOIN prediction_reviews pr ON pr.raw_prediction_id = pending.id
+			WHERE pending.channel_id = rp.channel_id AND pending.message_id = rp.message_id
+				AND pending.superseded_by IS NULL AND pr.id IS NULL
+		)
+		GROUP BY
+			m.channel_id, m.telegram_id, m.text, m.sent_at
+		ORDER BY
+			m.channel_id, m.telegram_id
+	`
+	decisions := pq.StringArray{ReviewDecisionAccepted, ReviewDecisionEdited, ReviewDecisionNoSignal}
+	rows, err := p.db.QueryContext(ctx, query, ReviewDecisionNoSignal, decisions)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get reviewed messages: %w", op, err)
+	}
+	defer rows.Close()
+
+	// Сообщения различаются парой (channel_id, telegram_id): Telegram ID повторяются в разных каналах
+	reviewed := []ReviewedMessage{}
+	index := make(map[[2]int64]int)
+	var channelIDs, messageIDs []int64
+	for rows.Next() {
+		var message Message
+		var noSignal bool
+		if err := rows.Scan(&message.TelegramID, &message.ChannelID, &message.Text, &message.SentAt, &noSignal); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan message row: %w", op, err)
+		}
+		index[[2]int64{message.ChannelID, message.TelegramID}] = len(reviewed)
+		channelIDs = append(channelIDs, message.ChannelID)
+		messageIDs = append(messageIDs, message.TelegramID)
+		reviewed = append(reviewed, ReviewedMessage{Message: message, NoSignal: noSignal})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+	if len(messageIDs) == 0 {
+		return reviewed, nil
+	}
+
+	// Ответом служат только прогнозы, созданные решениями проверяющих
+	predictionsQuery := `
+		SELECT
+			p.id, p.message_id, rp.channel_id, p.stock_id, s.ticker, p.prediction_type, p.target_price, p.target_change_percent,
+			p.entry_price_min, p.entry_price_max, p.stop_loss, p.take_profit_levels, p.period,
+			p.recommendation, p.direction, p.justification_text, p.predicted_at
+		FROM
+			prediction_reviews r
+		JOIN
+			raw_predictions rp ON rp.id = r.raw_prediction_id
+		JOIN
+			predictions p ON p.id = r.prediction_id
+		JOIN
+			stocks s ON s.id = p.stock_id
+		WHERE
+			(rp.channel_id, rp.message_id) IN (SELECT * FROM unnest($1::BIGINT[], $2::BIGINT[]))
+			AND rp.superseded_by IS NULL AND p.superseded_by IS NULL
+			AND r.decision = ANY($3)
+		ORDER BY
+			p.id
+	`
+	predictionRows, err := p.db.QueryContext(ctx, predictionsQuery, pq.Int64Array(channelIDs), pq.Int64Array(messageIDs),
+		pq.StringArray{ReviewDecisionAccepted, ReviewDecisionEdited})
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get predictions: %w", op, err)
+	}
+	defer predictionRows.Close()
+
+	for predictionRows.Next() {
+		var item StockPrediction
+		prediction := &item.Prediction
+		err := predictionRows.Scan(
+			&prediction.ID,
+			&prediction.MessageID,
+			&prediction.ChannelID,
+			&prediction.StockID,
+			&item.Ticker,
+			&prediction.PredictionType,
+			&prediction.TargetPrice,
+			&prediction.TargetChangePercent,
+			&prediction.EntryPriceMin,
+			&prediction.EntryPriceMax,
+			&prediction.StopLoss,
+			&prediction.TakeProfitLevels,
+			&prediction.Period,
+			&prediction.Recommendation,
+			&prediction.Direction,
+			&prediction.JustificationText,
+			&prediction.PredictedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan prediction row: %w", op, err)
+		}
+		idx := index[[2]int64{prediction.ChannelID.Int64, prediction.MessageID}]
+		reviewed[idx].Predictions = append(reviewed[idx].Predictions, item)
+	}
+	if err = predictionRows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return reviewed, nil
+}
+
+// SaveMessageEmbedding сохраняет вектор сообщения; повторный расчет перезаписывает вектор и ссылку на исходное сообщение.
+func (p *PostgresStorage) SaveMessageEmbedding(ctx context.Context, embedding *MessageEmbedding) error {
+	const op = "storage.SaveMessageEmbedding"
+
+	query := `
+		INSERT INTO message_embeddings (
+			channel_id, message_id, model, embedding, duplicate_of_channel_id, duplicate_of_message_id, similarity, sent_at, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (channel_id, message_id) DO UPDATE SET
+			model = EXCLUDED.model,
+			embedding = EXCLUDED.embedding,
+			duplicate_of_channel_id = EXCLUDED.duplicate_of_channel_id,
+			duplicate_of_message_id = EXCLUDED.duplicate_of_message_id,
+			similarity = EXCLUDED.similarity,
+			sent_at = EXCLUDED.sent_at,
+			created_at = EXCLUDED.created_at
+	`
+
+	_, err := p.db.ExecContext(ctx, query,
+		embedding.ChannelID,
+		embedding.MessageID,
+		embedding.Model,
+		embedding.Embedding,
+		embedding.DuplicateOfChannelID,
+		embedding.DuplicateOfMessageID,
+		embedding.Similarity,
+		embedding.SentAt,
+		embedding.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: failed to save message embedding: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetRecentEmbeddings возвращает векторы исходных (не повторных) сообщений модели model, отправленных не раньше since.
+// Векторы без времени отправки не возвращаются: исходным сообщением они стать не могут.
+func (p *PostgresStorage) GetRecentEmbeddings(ctx context.Context, model string, since time.Time) ([]MessageEmbedding, error) {
+	const op = "storage.GetRecentEmbeddings"
+
+	query := `
+		SELECT channel_id, message_id, model, embedding, sent_at, created_at
+		FROM message_embeddings
+		WHERE model = $1 AND sent_at >= $2 AND duplicate_of_message_id IS NULL
+		ORDER BY sent_at ASC
+	`
+	rows, err := p.db.QueryContext(ctx, query, model, since)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get embeddings: %w", op, err)
+	}
+	defer rows.Close()
+
+	embeddings := []MessageEmbedding{}
+	for rows.Next() {
+		var embedding MessageEmbedding
+		if err := rows.Scan(&embedding.ChannelID, &embedding.MessageID, &embedding.Model, &embedding.Embedding, &embedding.SentAt, &embedding.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan embedding row: %w", op, err)
+		}
+		embeddings = append(embeddings, embedding)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return embeddings, nil
+}
+
+// GetSignals возвращает прогнозы из сообщений, опубликованных после since, в порядке публикации.
+// Сообщение прогноза определяется парой (channel_id, telegram_id): Telegram ID повторяются в разных каналах.
+// runID выбирает прогнозы одного запуска анализа; LatestRun — действующие прогнозы.
+func (p *PostgresStorage) GetSignals(ctx context.Context, since time.Time, runID int64) ([]ChannelSignal, error) {
+	const op = "storage.GetSignals"
+
+	query := `
+		SELECT
+			m.telegram_id, m.channel_id, m.sent_at, m.is_forward, m.raw_data,
+			p.id, p.stock_id, s.ticker, p.target_price, p.take_profit_levels, p.direction
+		FROM
+			predictions p
+		JOIN
+			messages m ON m.channel_id = p.channel_id AND m.telegram_id = p.message_id
+		JOIN
+			stocks s ON s.id = p.stock_id
+		WHERE
+			m.sent_at >= $1 AND ` + runCondition("p", 2) + `
+		ORDER BY
+			m.sent_at ASC, p.id ASC
+	`
+	rows, err := p.db.QueryContext(ctx, query, since, runID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get signals: %w", op, err)
+	}
+	defer rows.Close()
+
+	signals := []ChannelSignal{}
+	for rows.Next() {
+		var signal ChannelSignal
+		message := &signal.Message
+		prediction := &signal.Prediction.Prediction
+		err := rows.Scan(
+			&message.TelegramID,
+			&message.ChannelID,
+			&message.SentAt,
+			&message.IsForward,
+			&message.RawData,
+			&prediction.ID,
+			&prediction.StockID,
+			&signal.Prediction.Ticker,
+			&prediction.TargetPrice,
+			&prediction.TakeProfitLevels,
+			&prediction.Direction,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan signal row: %w", op, err)
+		}
+		prediction.MessageID = message.TelegramID
+		signals = append(signals, signal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return signals, nil
+}
+
+// runCondition возвращает условие выбора результатов запуска из параметра $param для таблицы с псевдонимом alias:
+// при значении LatestRun выбираются действующие результаты, иначе — результаты указанного запуска.
+func runCondition(alias string, param int) string {
+	return fmt.Sprintf("(($%[2]d::BIGINT = 0 AND %[1]s.superseded_by IS NULL) OR %[1]s.run_id = $%[2]d::BIGINT)", alias, param)
+}
+
+// CreateAnalysisRun создает запись о запуске анализа и записывает присвоенный ID.
+func (p *PostgresStorage) CreateAnalysisRun(ctx context.Context, run *AnalysisRun) error {
+	const op = "storage.CreateAnalysisRun"
+
+	query := `
+		INSERT INTO analysis_runs (
+			reanalysis, model, provider, prompt_version, prompt_hash, sampling, binary_version, started_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+	err := p.db.QueryRowContext(ctx, query,
+		run.Reanalysis,
+		run.Model,
+		run.Provider,
+		run.PromptVersion,
+		run.PromptHash,
+		run.Sampling,
+		run.BinaryVersion,
+		run.StartedAt,
+	).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to create analysis run: %w", op, err)
+	}
+
+	return nil
+}
+
+// FinishAnalysisRun записывает время окончания запуска и итоговые счетчики сообщений и прогнозов.
+func (p *PostgresStorage) FinishAnalysisRun(ctx context.Context, run *AnalysisRun) error {
+	const op = "storage.FinishAnalysisRun"
+
+	query := `
+		UPDATE analysis_runs
+		SET finished_at = $2, messages_total = $3, messages_failed = $4, messages_skipped = $5, predictions_total = $6
+		WHERE id = $1
+	`
+	_, err := p.db.ExecContext(ctx, query,
+		run.ID,
+		run.FinishedAt,
+		run.MessagesTotal,
+		run.MessagesFailed,
+		run.MessagesSkipped,
+		run.PredictionsTotal,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: failed to finish analysis run: %w", op, err)
+	}
+
+	return nil
+}
+
+// insertAnalysisResult вставляет результат анализа сообщения и записывает присвоенный ID.
+func insertAnalysisResult(ctx context.Context, q queryRower, result *AnalysisResult) error {
+	query := `
+		INSERT INTO analysis_results (
+			run_id, channel_id, message_id, raw_response, latency_ms, requests, prompt_tokens, completion_tokens, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+	return q.QueryRowContext(ctx, query,
+		result.RunID,
+		result.ChannelID,
+		result.MessageID,
+		result.RawResponse,
+		result.LatencyMs,
+		result.Requests,
+		result.PromptTokens,
+		result.CompletionTokens,
+		result.CreatedAt,
+	).Scan(&result.ID)
+}
+
+// supersedeQueries помечают результаты сообщения $2 канала $1, созданные не запуском $3, замененными этим запуском.
+// Telegram ID уникален только в пределах канала, поэтому каждый запрос ограничен и каналом.
+var supersedeQueries = []string{
+	`UPDATE predictions SET superseded_by = $3
+	 WHERE channel_id = $1 AND message_id = $2 AND superseded_by IS NULL AND run_id IS DISTINCT FROM $3`,
+	`UPDATE raw_predictions SET superseded_by = $3
+	 WHERE channel_id = $1 AND message_id = $2 AND superseded_by IS NULL AND run_id IS DISTINCT FROM $3`,
+	`UPDATE message_skips SET superseded_by = $3
+	 WHERE channel_id = $1 AND message_id = $2 AND superseded_by IS NULL AND run_id IS DISTINCT FROM $3`,
+}
+
+// SaveMessageResults сохраняет результаты анализа сообщения одной транзакцией: при повторном анализе помечает прежние
+// результаты замененными, затем записывает результат анализа, пропуск, прогнозы и raw-прогнозы. Прогнозы ссылаются
+// на записанный результат анализа. Если какая-то запись не удалась, не сохраняется ничего и прежние результаты
+// остаются действующими. Присвоенные ID записываются в results.
+func (p *PostgresStorage) SaveMessageResults(ctx context.Context, results *MessageResults) error {
+	const op = "storage.SaveMessageResults"
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if results.SupersedeRunID != 0 {
+		for _, query := range supersedeQueries {
+			if _, err := tx.ExecContext(ctx, query, results.ChannelID, results.MessageID, results.SupersedeRunID); err != nil {
+				return fmt.Errorf("%s: failed to supersede results: %w", op, err)
+			}
+		}
+	}
+
+	var resultID sql.NullInt64
+	if results.Result != nil {
+		if err := insertAnalysisResult(ctx, tx, results.Result); err != nil {
+			return fmt.Errorf("%s: failed to save analysis result: %w", op, err)
+		}
+		resultID = sql.NullInt64{Int64: results.Result.ID, Valid: true}
+	}
+	if results.Skip != nil {
+		if err := insertMessageSkip(ctx, tx, results.Skip); err != nil {
+			return fmt.Errorf("%s: failed to save message skip: %w", op, err)
+		}
+	}
+	for i := range results.Predictions {
+		results.Predictions[i].ResultID = resultID
+		if err := insertPrediction(ctx, tx, &results.Predictions[i]); err != nil {
+			return fmt.Errorf("%s: failed to save prediction: %w", op, err)
+		}
+	}
+	for i := range results.RawPredictions {
+		results.RawPredictions[i].ResultID = resultID
+		if err := insertRawPrediction(ctx, tx, &results.RawPredictions[i]); err != nil {
+			return fmt.Errorf("%s: failed to save raw prediction: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetPredictions возвращает прогнозы сообщения messageID канала channelID вместе с тикерами. runID выбирает прогнозы одного запуска анализа;
+// LatestRun — действующие прогнозы.
+func (p *PostgresStorage) GetPredictions(ctx context.Context, channelID, messageID, runID int64) ([]StockPrediction, error) {
+	const op = "storage.GetPredictions"
+
+	query := `
+		SELECT
+			p.id, p.message_id, p.stock_id, s.ticker, p.prediction_type, p.target_price, p.target_change_percent,
+			p.entry_price_min, p.entry_price_max, p.stop_loss, p.take_profit_levels, p.period,
+			p.recommendation, p.direction, p.justification_text, p.confidence, p.contextual,
+			p.run_id, p.result_id, p.superseded_by, p.predicted_at
+		FROM
+			predictions p
+		JOIN
+			stocks s ON s.id = p.stock_id
+		WHERE
+			p.channel_id = $1 AND p.message_id = $2 AND ` + runCondition("p", 3) + `
+		ORDER BY
+			p.id
+	`
+	rows, err := p.db.QueryContext(ctx, query, channelID, messageID, runID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get predictions: %w", op, err)
+	}
+	defer rows.Close()
+
+	predictions := []StockPrediction{}
+	for rows.Next() {
+		var item StockPrediction
+		prediction := &item.Prediction
+		err := rows.Scan(
+			&prediction.ID,
+			&prediction.MessageID,
+			&prediction.StockID,
+			&item.Ticker,
+			&prediction.PredictionType,
+			&prediction.TargetPrice,
+			&prediction.TargetChangePercent,
+			&prediction.EntryPriceMin,
+			&prediction.EntryPriceMax,
+			&prediction.StopLoss,
+			&prediction.TakeProfitLevels,
+			&prediction.Period,
+			&prediction.Recommendation,
+			&prediction.Direction,
+			&prediction.JustificationText,
+			&prediction.Confidence,
+			&prediction.Contextual,
+			&prediction.RunID,
+			&prediction.ResultID,
+			&prediction.SupersededBy,
+			&prediction.PredictedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan prediction row: %w", op, err)
+		}
+		predictions = append(predictions, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return predictions, nil
+}
+
 func (p *PostgresStorage) Close() error {
 	return p.db.Close()
 }
+
+// SaveAnalysisFailure записывает неудачный анализ сообщения. Для сообщения хранится одна запись: повторная неудача
+// обновляет ее и снимает отметку об успешном повторе, а время первой неудачи сохраняется, пока запись не решена.
+func (p *PostgresStorage) SaveAnalysisFailure(ctx context.Context, failure *AnalysisFailure) error {
+	const op = "storage.SaveAnalysisFailure"
+
+	query := `
+		INSERT INTO analysis_failures (
+			channel_id, message_id, stage, error_class, error_message, attempts, run_id,
+			first_failed_at, last_failed_at, next_retry_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9)
+		ON CONFLICT (channel_id, message_id) DO UPDATE SET
+			stage = EXCLUDED.stage,
+			error_class = EXCLUDED.error_class,
+			error_message = EXCLUDED.error_message,
+			attempts = EXCLUDED.attempts,
+			run_id = EXCLUDED.run_id,
+			first_failed_at = CASE
+				WHEN analysis_failures.resolved_at IS NULL THEN analysis_failures.first_failed_at
+				ELSE EXCLUDED.first_failed_at
+			END,
+			last_failed_at = EXCLUDED.last_failed_at,
+			next_retry_at = EXCLUDED.next_retry_at,
+			resolved_at = NULL
+		RETURNING id
+	`
+	err := p.db.QueryRowContext(ctx, query,
+		failure.ChannelID,
+		failure.MessageID,
+		failure.Stage,
+		failure.ErrorClass,
+		failure.ErrorMessage,
+		failure.Attempts,
+		failure.RunID,
+		failure.LastFailedAt,
+		failure.NextRetryAt,
+	).Scan(&failure.ID)
+	if err != nil {
+		return fmt.Errorf("%s: failed to save analysis failure: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetDueFailures возвращает нерешенные неудачные анализы, время повторной попытки которых наступило к now,
+// начиная с самых давних.
+func (p *PostgresStorage) GetDueFailures(ctx context.Context, now time.Time, limit int) ([]AnalysisFailure, error) {
+	const op = "storage.GetDueFailures"
+
+	query := `
+		SELECT
+			id, channel_id, message_id, stage, error_class, error_message, attempts, run_id,
+			first_failed_at, last_failed_at, next_retry_at, resolved_at
+		FROM
+			analysis_failures
+		WHERE
+			resolved_at IS NULL AND next_retry_at <= $1
+		ORDER BY
+			next_retry_at ASC
+		LIMIT $2
+	`
+	rows, err := p.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get due failures: %w", op, err)
+	}
+	defer rows.Close()
+
+	failures := []AnalysisFailure{}
+	for rows.Next() {
+		var failure AnalysisFailure
+		err := rows.Scan(
+			&failure.ID,
+			&failure.ChannelID,
+			&failure.MessageID,
+			&failure.Stage,
+			&failure.ErrorClass,
+			&failure.ErrorMessage,
+			&failure.Attempts,
+			&failure.RunID,
+			&failure.FirstFailedAt,
+			&failure.LastFailedAt,
+			&failure.NextRetryAt,
+			&failure.ResolvedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan failure row: %w", op, err)
+		}
+		failures = append(failures, failure)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return failures, nil
+}
+
+// ResolveFailure отмечает неудачный анализ сообщения успешно повторенным.
+func (p *PostgresStorage) ResolveFailure(ctx context.Context, channelID, messageID int64, resolvedAt time.Time) error {
+	const op = "storage.ResolveFailure"
+
+	query := `
+		UPDATE analysis_failures
+		SET resolved_at = $3, next_retry_at = NULL
+		WHERE channel_id = $1 AND message_id = $2 AND resolved_at IS NULL
+	`
+	if _, err := p.db.ExecContext(ctx, query, channelID, messageID, resolvedAt); err != nil {
+		return fmt.Errorf("%s: failed to resolve failure: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetFailureSummary возвращает сводку неудачных анализов по классам ошибок, начиная с классов
+// с наибольшим числом нерешенных записей.
+func (p *PostgresStorage) GetFailureSummary(ctx context.Context) ([]FailureSummary, error) {
+	const op = "storage.GetFailureSummary"
+
+	query := `
+		SELECT
+			error_class,
+			COUNT(*) FILTER (WHERE resolved_at IS NULL AND next_retry_at IS NOT NULL),
+			COUNT(*) FILTER (WHERE resolved_at IS NULL AND next_retry_at IS NULL),
+			COUNT(*) FILTER (WHERE resolved_at IS NOT NULL),
+			COALESCE(MAX(attempts) FILTER (WHERE resolved_at IS NULL), 0),
+			MAX(last_failed_at),
+			(ARRAY_AGG(error_message ORDER BY last_failed_at DESC))[1]
+		FROM
+			analysis_failures
+		GROUP BY
+			error_class
+		ORDER BY
+			COUNT(*) FILTER (WHERE resolved_at IS NULL) DESC, error_class
+	`
+	rows, err := p.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get failure summary: %w", op, err)
+	}
+	defer rows.Close()
+
+	summary := []FailureSummary{}
+	for rows.Next() {
+		var item FailureSummary
+		err := rows.Scan(
+			&item.ErrorClass,
+			&item.Pending,
+			&item.Exhausted,
+			&item.Resolved,
+			&item.MaxAttempts,
+			&item.LastFailedAt,
+			&item.LastError,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to scan summary row: %w", op, err)
+		}
+		summary = append(summary, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: error during rows iteration: %w", op, err)
+	}
+
+	return summary, nil
+}
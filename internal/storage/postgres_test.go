@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSupersedeQueries проверяет, что повторный анализ сообщения не затрагивает сообщение с тем же Telegram ID
+// в другом канале: каждый запрос ограничен и каналом, и сообщением
+func TestSupersedeQueries(t *testing.T) {
+	assert.Len(t, supersedeQueries, 3)
+	for _, query := range supersedeQueries {
+		assert.Contains(t, query, "WHERE channel_id = $1 AND message_id = $2")
+	}
+}
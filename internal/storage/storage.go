@@ -2,13 +2,33 @@ package storage
 
 import (
 	"context"
+	"time"
 )
 
 // Storage определяет интерфейс для взаимодействия с базой данных
 type Storage interface {
 	GetMessagesWithoutPredictions(ctx context.Context, limit int) ([]Message, error)
+	GetMessages(ctx context.Context, filter MessageFilter) ([]Message, error)
+	GetMessage(ctx context.Context, channelID, telegramID int64) (*Message, error)
+	SaveMessages(ctx context.Context, messages []Message) (int, error)
 	SavePrediction(ctx context.Context, prediction *Prediction) error
 	GetStock(ctx context.Context, ticker string) (*Stock, error)
 	SaveRawPrediction(ctx context.Context, rawPrediction *RawPrediction) error
+	SaveMessageSkip(ctx context.Context, skip *MessageSkip) error
+	GetPendingReviews(ctx context.Context, limit int) ([]ReviewItem, error)
+	SaveReview(ctx context.Context, review *PredictionReview, prediction *Prediction) error
+	GetReviewedMessages(ctx context.Context) ([]ReviewedMessage, error)
+	SaveMessageEmbedding(ctx context.Context, embedding *MessageEmbedding) error
+	GetRecentEmbeddings(ctx context.Context, model string, since time.Time) ([]MessageEmbedding, error)
+	GetSignals(ctx context.Context, since time.Time, runID int64) ([]ChannelSignal, error)
+	CreateAnalysisRun(ctx context.Context, run *AnalysisRun) error
+	FinishAnalysisRun(ctx context.Context, run *AnalysisRun) error
+	SaveMessageResults(ctx context.Context, results *MessageResults) error
+	GetPredictions(ctx context.Context, channelID, messageID, runID int64) ([]StockPrediction, error)
+	SaveAnalysisFailure(ctx context.Context, failure *AnalysisFailure) error
+	GetDueFailures(ctx context.Context, now time.Time, limit int) ([]AnalysisFailure, error)
+	ResolveFailure(ctx context.Context, channelID, messageID int64, resolvedAt time.Time) error
+	GetFailureSummary(ctx context.Context) ([]FailureSummary, error)
+	Migrate(ctx context.Context, dryRun bool) ([]string, error)
 	Close() error
 }
@@ -0,0 +1,198 @@
+// Package telegram читает экспорт истории чатов Telegram Desktop (result.json) и преобразует его сообщения
+// в записи таблицы messages.
+package telegram
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"rkata-ai/trade-radar/internal/storage"
+)
+
+// Типы каналов в экспорте. Из полного экспорта аккаунта импортируются только каналы, чтобы личные переписки
+// не попадали в базу.
+const (
+	ChatPublicChannel  = "public_channel"
+	ChatPrivateChannel = "private_channel"
+)
+
+// Типы сообщений, которые экспорт присваивает записям message.type. Служебные сообщения (создание канала,
+// закрепление и т.п.) не импортируются.
+const (
+	entryMessage = "message"
+	entryService = "service"
+)
+
+// Значения message_type импортированных сообщений, кроме media_type экспорта, который сохраняется как есть
+// (video_file, voice_message, sticker, animation, audio_file, video_message).
+const (
+	TypeText     = "text"
+	TypePhoto    = "photo"
+	TypeDocument = "document"
+	TypePoll     = "poll"
+)
+
+// Chat — чат экспорта с необработанными сообщениями.
+type Chat struct {
+	ID       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Messages []json.RawMessage `json:"messages"`
+}
+
+// IsChannel сообщает, что чат — канал.
+func (c Chat) IsChannel() bool {
+	return c.Type == ChatPublicChannel || c.Type == ChatPrivateChannel
+}
+
+// export — корень result.json: либо экспорт одного чата, либо полный экспорт аккаунта со списком чатов.
+type export struct {
+	Chat
+	Chats *struct {
+		List []Chat `json:"list"`
+	} `json:"chats"`
+}
+
+// Parse читает result.json. Экспорт одного чата возвращается как есть, из полного экспорта аккаунта
+// возвращаются все чаты из chats.list.
+func Parse(r io.Reader) ([]Chat, error) {
+	var root export
+	if err := json.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to parse telegram export: %w", err)
+	}
+	if root.Messages != nil {
+		return []Chat{root.Chat}, nil
+	}
+	if root.Chats != nil {
+		return root.Chats.List, nil
+	}
+	return nil, fmt.Errorf("telegram export has neither messages nor chats")
+}
+
+// exportMessage — поля сообщения экспорта, нужные для таблицы messages.
+type exportMessage struct {
+	ID               int64           `json:"id"`
+	Type             string          `json:"type"`
+	Date             string          `json:"date"`
+	DateUnixtime     string          `json:"date_unixtime"`
+	ForwardedFrom    json.RawMessage `json:"forwarded_from"`
+	ReplyToMessageID int64           `json:"reply_to_message_id"`
+	Text             json.RawMessage `json:"text"`
+	Photo            string          `json:"photo"`
+	File             string          `json:"file"`
+	MediaType        string          `json:"media_type"`
+	Poll             json.RawMessage `json:"poll"`
+}
+
+// Convert преобразует сообщения чата в записи таблицы messages канала channelID. Форматированный текст
+// сводится к обычному, подпись медиа становится текстом сообщения, пересылка отмечается is_forward,
+// а исходный объект сообщения сохраняется в raw_data: по reply_to_message_id из него восстанавливаются ответы.
+// Возвращает сообщения и число пропущенных служебных записей.
+func (c Chat) Convert(channelID int64, importedAt time.Time) ([]storage.Message, int, error) {
+	messages := make([]storage.Message, 0, len(c.Messages))
+	skipped := 0
+	for idx, raw := range c.Messages {
+		var item exportMessage
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse message #%d: %w", idx+1, err)
+		}
+		if item.Type != entryMessage {
+			skipped++
+			continue
+		}
+
+		sentAt, err := item.sentAt()
+		if err != nil {
+			return nil, 0, fmt.Errorf("message %d: %w", item.ID, err)
+		}
+		text, err := FlattenText(item.Text)
+		if err != nil {
+			return nil, 0, fmt.Errorf("message %d: %w", item.ID, err)
+		}
+
+		messages = append(messages, storage.Message{
+			TelegramID:  item.ID,
+			ChannelID:   channelID,
+			Text:        sql.NullString{String: text, Valid: text != ""},
+			SentAt:      sentAt,
+			IsForward:   sql.NullBool{Bool: len(item.ForwardedFrom) > 0, Valid: true},
+			MessageType: sql.NullString{String: item.messageType(), Valid: true},
+			RawData:     raw,
+			CreatedAt:   importedAt,
+		})
+	}
+	return messages, skipped, nil
+}
+
+// sentAt возвращает время отправки: date_unixtime в новых экспортах или date в местном времени экспорта.
+func (m exportMessage) sentAt() (time.Time, error) {
+	if m.DateUnixtime != "" {
+		seconds, err := strconv.ParseInt(m.DateUnixtime, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date_unixtime %q", m.DateUnixtime)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	sentAt, err := time.ParseInLocation("2006-01-02T15:04:05", m.Date, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", m.Date)
+	}
+	return sentAt, nil
+}
+
+func (m exportMessage) messageType() string {
+	switch {
+	case m.Photo != "":
+		return TypePhoto
+	case m.MediaType != "":
+		return m.MediaType
+	case m.File != "":
+		return TypeDocument
+	case len(m.Poll) > 0:
+		return TypePoll
+	default:
+		return TypeText
+	}
+}
+
+// FlattenText сводит поле text экспорта к обычному тексту. Поле — либо строка, либо массив из строк и объектов
+// форматирования {"type": "bold", "text": "..."}, текст которых склеивается по порядку.
+func FlattenText(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+
+	var text string
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return "", fmt.Errorf("failed to parse text: %w", err)
+		}
+		return strings.TrimSpace(text), nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("failed to parse text entities: %w", err)
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(part, &text); err == nil {
+			b.WriteString(text)
+		} else if err := json.Unmarshal(part, &entity); err == nil {
+			b.WriteString(entity.Text)
+		} else {
+			return "", fmt.Errorf("failed to parse text entity: %w", err)
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const channelExport = `{
+ "name": "Сигналы",
+ "type": "public_channel",
+ "id": 1234567890,
+ "messages": [
+  {"id": 1, "type": "service", "date": "2024-03-01T09:00:00", "date_unixtime": "1709283600", "action": "create_channel", "text": ""},
+  {"id": 2, "type": "message", "date": "2024-03-01T10:00:00", "date_unixtime": "1709287200", "from": "Сигналы",
+   "text": ["Покупаем ", {"type": "hashtag", "text": "#SBER"}, ", цель ", {"type": "bold", "text": "300"}]},
+  {"id": 3, "type": "message", "date": "2024-03-01T11:00:00", "date_unixtime": "1709290800", "forwarded_from": "Другой канал",
+   "reply_to_message_id": 2, "photo": "photos/photo_1.jpg", "text": "GAZP шорт"},
+  {"id": 4, "type": "message", "date": "2024-03-01T12:00:00", "date_unixtime": "1709294400", "forwarded_from": null,
+   "file": "files/report.pdf", "media_type": "video_file", "text": ""}
+ ]
+}`
+
+// TestConvert проверяет преобразование сообщений экспорта канала
+func TestConvert(t *testing.T) {
+	chats, err := Parse(strings.NewReader(channelExport))
+	assert.NoError(t, err)
+	if !assert.Len(t, chats, 1) {
+		return
+	}
+	assert.True(t, chats[0].IsChannel())
+	assert.Equal(t, int64(1234567890), chats[0].ID)
+
+	importedAt := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	messages, skipped, err := chats[0].Convert(100, importedAt)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+	if !assert.Len(t, messages, 3) {
+		return
+	}
+
+	t.Run("форматированный текст", func(t *testing.T) {
+		assert.Equal(t, "Покупаем #SBER, цель 300", messages[0].Text.String)
+		assert.Equal(t, int64(100), messages[0].ChannelID)
+		assert.Equal(t, int64(2), messages[0].TelegramID)
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), messages[0].SentAt)
+		assert.Equal(t, TypeText, messages[0].MessageType.String)
+		assert.False(t, messages[0].IsForward.Bool)
+		assert.Equal(t, importedAt, messages[0].CreatedAt)
+	})
+
+	t.Run("пересылка, ответ и подпись к фото", func(t *testing.T) {
+		assert.Equal(t, "GAZP шорт", messages[1].Text.String)
+		assert.True(t, messages[1].IsForward.Bool)
+		assert.Equal(t, TypePhoto, messages[1].MessageType.String)
+		replyTo, ok := messages[1].ReplyToID()
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), replyTo)
+
+		var raw map[string]any
+		assert.NoError(t, json.Unmarshal(messages[1].RawData, &raw))
+		assert.Equal(t, "Другой канал", raw["forwarded_from"])
+	})
+
+	t.Run("медиа без подписи", func(t *testing.T) {
+		assert.False(t, messages[2].Text.Valid)
+		assert.Equal(t, "video_file", messages[2].MessageType.String)
+		// forwarded_from: null — пересылка от скрытого отправителя
+		assert.True(t, messages[2].IsForward.Bool)
+	})
+}
+
+// TestParseAccountExport проверяет чтение полного экспорта аккаунта
+func TestParseAccountExport(t *testing.T) {
+	input := `{"about": "...", "chats": {"about": "...", "list": [
+		{"name": "Друг", "type": "personal_chat", "id": 1, "messages": []},
+		{"name": "Сигналы", "type": "private_channel", "id": 2, "messages": []}
+	]}}`
+
+	chats, err := Parse(strings.NewReader(input))
+	assert.NoError(t, err)
+	if assert.Len(t, chats, 2) {
+		assert.False(t, chats[0].IsChannel())
+		assert.True(t, chats[1].IsChannel())
+	}
+
+	_, err = Parse(strings.NewReader(`{"about": "..."}`))
+	assert.Error(t, err)
+}